@@ -1,27 +1,202 @@
 package config
 
-const (
-	AuthenticatorURL               = "http://localhost:8081"  // The address to make calls to get a JWT. Values: Your authenticator address (OSI authentication only)
-	AppHost                        = "localhost"              // The host where the app will run. 0.0.0.0 to all addresses. If 0.0.0.0 is specified, the local IP is used for requests
-	AppPort                        = 8881                     // The port where the app will run
-	AppSessionSecret               = "my-supersecret-session" // A secret for the cookie used for encrypt sessions
-	AppOpenOnceRunned              = true                     // An option to open the browser at the application address when the application is launched. Values: true/false
-	AppCertificateUsage            = false                    // If the HTTPs protocol will be used or not via certificate/key. Values: true/false
-	AppCertificateLocation         = ""                       // The location of the .crt/.pem file
-	AppCertificateKeyLocation      = ""                       // The location of the .key/.pem file
-	AppCSRFTokenUsage              = true                     // If the app will use CSRF tokens, to avoid CSRF attacks. Values: true/false
-	AppCSRFTokenSecret             = "my-supersecret-token"   // A secret for the token used for CSRF Token verification
-	AppCORSUsage                   = true                     // If the app will use CORS. If true, all requests will pass through CORS verification. Values: true/false)
-	AppCORSAllowOrigins            = "http://localhost:8881"  // A list with all the origins allowed.
-	GoogleOAuth2RedirectURL        = ""                       // The redirect URL. Usually it will be https://yourdomain.com/auth/google/callback. It need to be configured in your Google OAuth2 Client
-	GoogleOAuth2ClientID           = ""                       // The Google OAuth2 Client ID
-	GoogleOAuth2ClientSecret       = ""                       // The Google OAuth2 Client Secret
-	MicrosoftOAuth2RedirectURL     = ""                       // The redirect URL. Usually it will be https://yourdomain.com/auth/microsoft/callback. It need to be configured in your Google OAuth2 Client
-	MicrosoftOAuth2ClientID        = ""                       // The Microsoft OAuth2 Client ID
-	MicrosoftOAuth2ClientSecret    = ""                       // The Microsoft OAuth2 Client Secret
-	MicrosoftOAuth2AzureADEndpoint = ""                       // The Microsoft Azure tenant ID
-)
+import (
+	"encoding/json"
+	"fmt"
+	"time"
 
-var (
-	AuthenticationMethods = []string{"OAUTH2GOOGLE", "OAUTH2MICROSOFT", "OSI"} // A list with all the authentication methods allowed. Accepts: "OSI", "OAUTH2MICROSOFT", "OAUTH2GOOGLE"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
 )
+
+// Config is the runtime configuration of the application. It is built by Load, which applies, in order of precedence (lowest to highest):
+// the default values (Default), the configuration file (YAML, TOML or JSON) and the MAESTRO_* environment variables.
+type Config struct {
+	App       AppConfig       `json:"app"`
+	Auth      AuthConfig      `json:"auth"`
+	Database  DatabaseConfig  `json:"database"`
+	Jobs      JobsConfig      `json:"jobs"`
+	Backup    BackupConfig    `json:"backup"`
+	Schedules SchedulesConfig `json:"schedules"`
+	Storage   StorageConfig   `json:"storage"`
+}
+
+// AppConfig holds the HTTP server and web security settings
+type AppConfig struct {
+	Host                   string   `json:"host" env:"MAESTRO_APP_HOST"`                                       // The host where the app will run. 0.0.0.0 to all addresses. If 0.0.0.0 is specified, the local IP is used for requests
+	Port                   int      `json:"port" env:"MAESTRO_APP_PORT"`                                       // The port where the app will run
+	SessionSecret          string   `json:"sessionSecret" env:"MAESTRO_APP_SESSION_SECRET"`                    // A secret for the cookie used for encrypt sessions
+	SessionExpiration      Duration `json:"sessionExpiration" env:"MAESTRO_APP_SESSION_EXPIRATION"`            // How long a session (and the database connections owned by it) lives. Values: Go durations, like 24h
+	OpenOnceRunned         bool     `json:"openOnceRunned" env:"MAESTRO_APP_OPEN_ONCE_RUNNED"`                 // An option to open the browser at the application address when the application is launched. Values: true/false
+	CertificateUsage       bool     `json:"certificateUsage" env:"MAESTRO_APP_CERTIFICATE_USAGE"`              // If the HTTPs protocol will be used or not via certificate/key. Values: true/false
+	CertificateLocation    string   `json:"certificateLocation" env:"MAESTRO_APP_CERTIFICATE_LOCATION"`        // The location of the .crt/.pem file
+	CertificateKeyLocation string   `json:"certificateKeyLocation" env:"MAESTRO_APP_CERTIFICATE_KEY_LOCATION"` // The location of the .key/.pem file
+	CSRFTokenUsage         bool     `json:"csrfTokenUsage" env:"MAESTRO_APP_CSRF_TOKEN_USAGE"`                 // If the app will use CSRF tokens, to avoid CSRF attacks. Values: true/false
+	CSRFTokenSecret        string   `json:"csrfTokenSecret" env:"MAESTRO_APP_CSRF_TOKEN_SECRET"`               // A secret for the token used for CSRF Token verification
+	CORSUsage              bool     `json:"corsUsage" env:"MAESTRO_APP_CORS_USAGE"`                            // If the app will use CORS. If true, all requests will pass through CORS verification. Values: true/false
+	CORSAllowOrigins       string   `json:"corsAllowOrigins" env:"MAESTRO_APP_CORS_ALLOW_ORIGINS"`             // A list with all the origins allowed, separated by comma
+}
+
+// AuthConfig holds the authentication methods and their settings
+type AuthConfig struct {
+	Methods          []string              `json:"methods" env:"MAESTRO_AUTH_METHODS"`                    // A list with all the authentication methods allowed. Accepts: "OSI", "OAUTH2MICROSOFT", "OAUTH2GOOGLE". Via environment, separated by comma
+	AuthenticatorURL string                `json:"authenticatorURL" env:"MAESTRO_AUTH_AUTHENTICATOR_URL"` // The address to make calls to get a JWT. Values: Your authenticator address (OSI authentication only)
+	Google           OAuth2Config          `json:"google" envPrefix:"MAESTRO_AUTH_GOOGLE_"`
+	Microsoft        MicrosoftOAuth2Config `json:"microsoft" envPrefix:"MAESTRO_AUTH_MICROSOFT_"`
+}
+
+// OAuth2Config holds the client settings of an OAuth2 provider
+type OAuth2Config struct {
+	RedirectURL  string `json:"redirectURL" env:"REDIRECT_URL"`   // The redirect URL. Usually it will be https://yourdomain.com/auth/<provider>/callback. It need to be configured in your OAuth2 Client
+	ClientID     string `json:"clientID" env:"CLIENT_ID"`         // The OAuth2 Client ID
+	ClientSecret string `json:"clientSecret" env:"CLIENT_SECRET"` // The OAuth2 Client Secret
+}
+
+// MicrosoftOAuth2Config holds the client settings of the Microsoft OAuth2 provider, which also requires the Azure tenant
+type MicrosoftOAuth2Config struct {
+	OAuth2Config
+	AzureADEndpoint string `json:"azureADEndpoint" env:"AZURE_AD_ENDPOINT"` // The Microsoft Azure tenant ID
+}
+
+// DatabaseConfig holds the defaults used when connecting to a SQL Server instance
+type DatabaseConfig struct {
+	DefaultEncryption             string `json:"defaultEncryption" env:"MAESTRO_DATABASE_DEFAULT_ENCRYPTION"`                           // The encryption used when the /connect request does not set one. Values: mandatory/optional/strict/disable
+	DefaultTrustServerCertificate bool   `json:"defaultTrustServerCertificate" env:"MAESTRO_DATABASE_DEFAULT_TRUST_SERVER_CERTIFICATE"` // If the server certificate is trusted when the /connect request does not set it. Values: true/false
+	MaxOpenConns                  int    `json:"maxOpenConns" env:"MAESTRO_DATABASE_MAX_OPEN_CONNS"`                                    // The maximum open connections per connection pool. 0 means unlimited
+}
+
+// JobsConfig holds the settings of the backup/restore job engine
+type JobsConfig struct {
+	Retention        Duration `json:"retention" env:"MAESTRO_JOBS_RETENTION"`                // How long a finished job can still be queried through /api/jobs/{id}
+	BackupTimeout    Duration `json:"backupTimeout" env:"MAESTRO_JOBS_BACKUP_TIMEOUT"`       // The maximum time of each BACKUP DATABASE statement
+	RestoreTimeout   Duration `json:"restoreTimeout" env:"MAESTRO_JOBS_RESTORE_TIMEOUT"`     // The maximum time of each RESTORE DATABASE statement
+	ProgressInterval Duration `json:"progressInterval" env:"MAESTRO_JOBS_PROGRESS_INTERVAL"` // How often the progress of the running databases is sampled from sys.dm_exec_requests
+	VerifyTimeout    Duration `json:"verifyTimeout" env:"MAESTRO_JOBS_VERIFY_TIMEOUT"`       // The maximum time of each RESTORE VERIFYONLY statement
+	HistoryPath      string   `json:"historyPath" env:"MAESTRO_JOBS_HISTORY_PATH"`           // The file of the operation history (a bbolt database), which keeps every job after the retention and across restarts
+}
+
+// BackupConfig holds the default WITH options of the BACKUP statement, used when the /api/backup request does not set them. They are shown pre-selected in the UI
+type BackupConfig struct {
+	Compression        bool `json:"compression" env:"MAESTRO_BACKUP_COMPRESSION"`                 // COMPRESSION (true) or NO_COMPRESSION (false)
+	Checksum           bool `json:"checksum" env:"MAESTRO_BACKUP_CHECKSUM"`                       // CHECKSUM (true) or NO_CHECKSUM (false)
+	CopyOnly           bool `json:"copyOnly" env:"MAESTRO_BACKUP_COPY_ONLY"`                      // COPY_ONLY, which keeps the differential base and the log chain untouched. Ignored by differential backups
+	ContinueAfterError bool `json:"continueAfterError" env:"MAESTRO_BACKUP_CONTINUE_AFTER_ERROR"` // CONTINUE_AFTER_ERROR (true) or STOP_ON_ERROR (false)
+	Stats              int  `json:"stats" env:"MAESTRO_BACKUP_STATS"`                             // STATS percentage. 0 omits it
+	BufferCount        int  `json:"bufferCount" env:"MAESTRO_BACKUP_BUFFER_COUNT"`                // BUFFERCOUNT. 0 lets SQL Server choose
+	MaxTransferSize    int  `json:"maxTransferSize" env:"MAESTRO_BACKUP_MAX_TRANSFER_SIZE"`       // MAXTRANSFERSIZE in bytes. 0 lets SQL Server choose
+	BlockSize          int  `json:"blockSize" env:"MAESTRO_BACKUP_BLOCK_SIZE"`                    // BLOCKSIZE in bytes. 0 lets SQL Server choose
+	Verify             bool `json:"verify" env:"MAESTRO_BACKUP_VERIFY"`                           // Runs RESTORE VERIFYONLY after each successful backup
+}
+
+// SchedulesConfig holds the settings of the backup schedules
+type SchedulesConfig struct {
+	Secret string `json:"secret" env:"MAESTRO_SCHEDULES_SECRET"` // The secret which encrypts the passwords of the schedule connections, kept in the jobs.historyPath file. Schedules cannot be created without it
+}
+
+// StorageConfig holds the keys MaestroSQL uses to list the backups of the object storages (/api/list-backups with a backup URL), and to ship the backup files
+// to a secondary location (shipTo). SQL Server writes and reads the backups with its own credentials (/api/credentials), whose secrets cannot be read back
+type StorageConfig struct {
+	S3    S3StorageConfig    `json:"s3" envPrefix:"MAESTRO_STORAGE_S3_"`
+	Azure AzureStorageConfig `json:"azure" envPrefix:"MAESTRO_STORAGE_AZURE_"`
+	SFTP  SFTPStorageConfig  `json:"sftp" envPrefix:"MAESTRO_STORAGE_SFTP_"`
+}
+
+// S3StorageConfig holds the access keys of the S3-compatible endpoints (s3:// backup URLs)
+type S3StorageConfig struct {
+	AccessKey string `json:"accessKey" env:"ACCESS_KEY"` // The access key ID
+	SecretKey string `json:"secretKey" env:"SECRET_KEY"` // The secret access key
+	Region    string `json:"region" env:"REGION"`        // The region of the requests signature. S3-compatible endpoints usually accept us-east-1
+	PlainHTTP bool   `json:"plainHTTP" env:"PLAIN_HTTP"` // Lists the objects through HTTP instead of HTTPS, for local stand-ins. SQL Server always uses HTTPS
+}
+
+// AzureStorageConfig holds the shared access signature of the Azure Blob Storage containers (https:// backup URLs)
+type AzureStorageConfig struct {
+	SASToken string `json:"sasToken" env:"SAS_TOKEN"` // A SAS token with the list permission, without the leading "?"
+}
+
+// SFTPStorageConfig holds the authentication of the SFTP servers where the backup files are shipped (sftp:// destinations). The user is set in the destination
+type SFTPStorageConfig struct {
+	Password       string `json:"password" env:"PASSWORD"`               // The password of the user. Not used when privateKeyPath is set
+	PrivateKeyPath string `json:"privateKeyPath" env:"PRIVATE_KEY_PATH"` // The file of the private key of the user (OpenSSH or PEM format, without passphrase)
+	KnownHostsPath string `json:"knownHostsPath" env:"KNOWN_HOSTS_PATH"` // The known_hosts file which has the host keys of the servers. Required: unknown servers are refused
+}
+
+// Options returns the defaults as BackupOptions, with every option set
+func (bc BackupConfig) Options() model.BackupOptions {
+	return model.BackupOptions{
+		Compression:        &bc.Compression,
+		Checksum:           &bc.Checksum,
+		CopyOnly:           &bc.CopyOnly,
+		ContinueAfterError: &bc.ContinueAfterError,
+		Stats:              bc.Stats,
+		BufferCount:        bc.BufferCount,
+		MaxTransferSize:    bc.MaxTransferSize,
+		BlockSize:          bc.BlockSize,
+		Verify:             &bc.Verify,
+	}
+}
+
+// Default returns the configuration used when no file or environment variable overrides a value
+func Default() Config {
+	return Config{
+		App: AppConfig{
+			Host:              "localhost",
+			Port:              8881,
+			SessionExpiration: Duration(24 * time.Hour),
+			OpenOnceRunned:    true,
+			CSRFTokenUsage:    true,
+			CORSUsage:         true,
+			CORSAllowOrigins:  "http://localhost:8881",
+		},
+		Auth: AuthConfig{
+			Methods:          []string{},
+			AuthenticatorURL: "http://localhost:8081",
+		},
+		Database: DatabaseConfig{
+			DefaultEncryption: "mandatory",
+		},
+		Jobs: JobsConfig{
+			Retention:        Duration(24 * time.Hour),
+			BackupTimeout:    Duration(10 * time.Minute),
+			RestoreTimeout:   Duration(15 * time.Minute),
+			ProgressInterval: Duration(5 * time.Second),
+			VerifyTimeout:    Duration(15 * time.Minute),
+			HistoryPath:      "maestro-history.db",
+		},
+		Backup: BackupConfig{
+			Checksum: true,
+		},
+		Storage: StorageConfig{
+			S3: S3StorageConfig{
+				Region: "us-east-1",
+			},
+		},
+	}
+}
+
+// Duration is a time.Duration which is read from files and environment variables as a Go duration string, like "24h" or "90m"
+type Duration time.Duration
+
+// Std returns the value as a time.Duration
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return fmt.Errorf("duration must be a string like \"24h\": %w", err)
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
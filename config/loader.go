@@ -0,0 +1,273 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigPathEnv is the environment variable read when no --config flag is provided
+const ConfigPathEnv = "MAESTRO_CONFIG"
+
+// AuthenticationMethods accepted by AuthConfig.Methods
+var AuthenticationMethods = []string{"OSI", "OAUTH2GOOGLE", "OAUTH2MICROSOFT"}
+
+// Load builds the configuration: the defaults are overwritten by the file in path (if it is not empty) and then by the MAESTRO_* environment variables.
+// The result is validated before being returned.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		err := loadFile(path, &cfg)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+
+	err := applyEnv(reflect.ValueOf(&cfg).Elem(), "")
+	if err != nil {
+		return Config{}, err
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// Reads the configuration file. The format is chosen by the file extension (.yaml, .yml, .toml or .json).
+// YAML and TOML files are converted to JSON first, so the field names are the same for all formats. Unknown fields are rejected.
+func loadFile(path string, cfg *Config) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Cannot read the configuration file %v: %w", path, err)
+	}
+
+	var raw map[string]any
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &raw)
+	case ".toml":
+		err = toml.Unmarshal(content, &raw)
+	case ".json":
+		err = json.Unmarshal(content, &raw)
+	default:
+		return fmt.Errorf("Unsupported configuration file extension %q. Use .yaml, .yml, .toml or .json", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("Cannot parse the configuration file %v: %w", path, err)
+	}
+
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("Cannot parse the configuration file %v: %w", path, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(normalized))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(cfg)
+	if err != nil {
+		return fmt.Errorf("Invalid configuration file %v: %w", path, err)
+	}
+
+	return nil
+}
+
+// Walks through the struct fields, overwriting the ones which have an "env" tag with the environment variable value, when it is set.
+// Nested structs may declare an "envPrefix" tag, which is prepended to the names of their fields.
+func applyEnv(v reflect.Value, prefix string) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i)
+
+		if field.Type.Kind() == reflect.Struct {
+			err := applyEnv(value, prefix+field.Tag.Get("envPrefix"))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := field.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		name = prefix + name
+
+		envValue, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		err := setValue(value, envValue)
+		if err != nil {
+			return fmt.Errorf("Invalid value for the environment variable %v: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Converts the environment variable string to the field type
+func setValue(value reflect.Value, envValue string) error {
+	if value.Type() == reflect.TypeOf(Duration(0)) {
+		parsed, err := time.ParseDuration(envValue)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(parsed))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(envValue)
+	case reflect.Int, reflect.Int64:
+		parsed, err := strconv.ParseInt(envValue, 10, 64)
+		if err != nil {
+			return err
+		}
+		value.SetInt(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(envValue)
+		if err != nil {
+			return err
+		}
+		value.SetBool(parsed)
+	case reflect.Slice:
+		items := []string{}
+		for _, item := range strings.Split(envValue, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %v", value.Kind())
+	}
+
+	return nil
+}
+
+// Validate checks the configuration, returning all the problems found at once
+func (cfg Config) Validate() error {
+	var errs []error
+
+	if cfg.App.Host == "" {
+		errs = append(errs, errors.New("app.host: cannot be empty"))
+	}
+	if cfg.App.Port < 1 || cfg.App.Port > 65535 {
+		errs = append(errs, fmt.Errorf("app.port: must be between 1 and 65535, got %v", cfg.App.Port))
+	}
+	if cfg.App.SessionExpiration <= 0 {
+		errs = append(errs, errors.New("app.sessionExpiration: must be greater than zero"))
+	}
+	if cfg.App.CertificateUsage {
+		if cfg.App.CertificateLocation == "" || cfg.App.CertificateKeyLocation == "" {
+			errs = append(errs, errors.New("app.certificateLocation/app.certificateKeyLocation: both are required when app.certificateUsage is true"))
+		}
+	}
+	if cfg.App.CORSUsage && cfg.App.CORSAllowOrigins == "" {
+		errs = append(errs, errors.New("app.corsAllowOrigins: cannot be empty when app.corsUsage is true"))
+	}
+
+	for _, method := range cfg.Auth.Methods {
+		if !slices.Contains(AuthenticationMethods, method) {
+			errs = append(errs, fmt.Errorf("auth.methods: unknown method %q. Accepts: %v", method, strings.Join(AuthenticationMethods, ", ")))
+		}
+	}
+	if slices.Contains(cfg.Auth.Methods, "OSI") {
+		if _, err := url.ParseRequestURI(cfg.Auth.AuthenticatorURL); err != nil {
+			errs = append(errs, fmt.Errorf("auth.authenticatorURL: must be a valid URL when OSI is enabled: %w", err))
+		}
+	}
+	if slices.Contains(cfg.Auth.Methods, "OAUTH2GOOGLE") {
+		errs = append(errs, cfg.Auth.Google.validate("auth.google")...)
+	}
+	if slices.Contains(cfg.Auth.Methods, "OAUTH2MICROSOFT") {
+		errs = append(errs, cfg.Auth.Microsoft.validate("auth.microsoft")...)
+		if cfg.Auth.Microsoft.AzureADEndpoint == "" {
+			errs = append(errs, errors.New("auth.microsoft.azureADEndpoint: required when OAUTH2MICROSOFT is enabled"))
+		}
+	}
+
+	if !slices.Contains([]string{"mandatory", "optional", "strict", "disable", "true", "false"}, cfg.Database.DefaultEncryption) {
+		errs = append(errs, fmt.Errorf("database.defaultEncryption: unknown value %q. Accepts: mandatory, optional, strict, disable", cfg.Database.DefaultEncryption))
+	}
+	if cfg.Database.MaxOpenConns < 0 {
+		errs = append(errs, errors.New("database.maxOpenConns: cannot be negative"))
+	}
+
+	if cfg.Jobs.Retention <= 0 {
+		errs = append(errs, errors.New("jobs.retention: must be greater than zero"))
+	}
+	if cfg.Jobs.BackupTimeout <= 0 {
+		errs = append(errs, errors.New("jobs.backupTimeout: must be greater than zero"))
+	}
+	if cfg.Jobs.RestoreTimeout <= 0 {
+		errs = append(errs, errors.New("jobs.restoreTimeout: must be greater than zero"))
+	}
+	if cfg.Jobs.VerifyTimeout <= 0 {
+		errs = append(errs, errors.New("jobs.verifyTimeout: must be greater than zero"))
+	}
+	if cfg.Jobs.ProgressInterval.Std() < time.Second {
+		errs = append(errs, errors.New("jobs.progressInterval: must be at least 1s"))
+	}
+	if cfg.Jobs.HistoryPath == "" {
+		errs = append(errs, errors.New("jobs.historyPath: cannot be empty"))
+	}
+
+	if cfg.Schedules.Secret != "" && len(cfg.Schedules.Secret) < 16 {
+		errs = append(errs, errors.New("schedules.secret: must have at least 16 characters"))
+	}
+
+	if (cfg.Storage.S3.AccessKey == "") != (cfg.Storage.S3.SecretKey == "") {
+		errs = append(errs, errors.New("storage.s3.accessKey/storage.s3.secretKey: both are required to list the S3 backups"))
+	}
+	if cfg.Storage.S3.Region == "" {
+		errs = append(errs, errors.New("storage.s3.region: cannot be empty"))
+	}
+	if strings.HasPrefix(cfg.Storage.Azure.SASToken, "?") {
+		errs = append(errs, errors.New("storage.azure.sasToken: cannot start with \"?\""))
+	}
+
+	if err := cfg.Backup.Options().Validate(model.BackupFull); err != nil {
+		errs = append(errs, fmt.Errorf("backup: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (oc OAuth2Config) validate(section string) []error {
+	var errs []error
+
+	if oc.ClientID == "" {
+		errs = append(errs, fmt.Errorf("%v.clientID: required when the method is enabled", section))
+	}
+	if oc.ClientSecret == "" {
+		errs = append(errs, fmt.Errorf("%v.clientSecret: required when the method is enabled", section))
+	}
+	if _, err := url.ParseRequestURI(oc.RedirectURL); err != nil {
+		errs = append(errs, fmt.Errorf("%v.redirectURL: must be a valid URL when the method is enabled", section))
+	}
+
+	return errs
+}
@@ -20,35 +20,39 @@ import (
 )
 
 type AuthController struct {
-	service service.AuthService
+	service              service.AuthService
+	cfg                  config.AuthConfig
+	googleOAuthConfig    *oauth2.Config
+	microsoftOAuthConfig *oauth2.Config
 }
 
-func NewAuthController(sv service.AuthService) AuthController {
-	return AuthController{service: sv}
-}
-
-var googleOAuthConfig = &oauth2.Config{
-	RedirectURL:  config.GoogleOAuth2RedirectURL,
-	ClientID:     config.GoogleOAuth2ClientID,
-	ClientSecret: config.GoogleOAuth2ClientSecret,
-	Scopes: []string{
-		"https://www.googleapis.com/auth/userinfo.email",
-		"https://www.googleapis.com/auth/userinfo.profile",
-	},
-	Endpoint: google.Endpoint,
-}
-
-var microsoftOAuthConfig = &oauth2.Config{
-	RedirectURL:  config.MicrosoftOAuth2RedirectURL,
-	ClientID:     config.MicrosoftOAuth2ClientID,
-	ClientSecret: config.MicrosoftOAuth2ClientSecret,
-	Scopes: []string{
-		"openid",
-		"profile",
-		"User.Read",
-		"email",
-	},
-	Endpoint: microsoft.AzureADEndpoint(config.MicrosoftOAuth2AzureADEndpoint),
+func NewAuthController(sv service.AuthService, cfg config.AuthConfig) AuthController {
+	return AuthController{
+		service: sv,
+		cfg:     cfg,
+		googleOAuthConfig: &oauth2.Config{
+			RedirectURL:  cfg.Google.RedirectURL,
+			ClientID:     cfg.Google.ClientID,
+			ClientSecret: cfg.Google.ClientSecret,
+			Scopes: []string{
+				"https://www.googleapis.com/auth/userinfo.email",
+				"https://www.googleapis.com/auth/userinfo.profile",
+			},
+			Endpoint: google.Endpoint,
+		},
+		microsoftOAuthConfig: &oauth2.Config{
+			RedirectURL:  cfg.Microsoft.RedirectURL,
+			ClientID:     cfg.Microsoft.ClientID,
+			ClientSecret: cfg.Microsoft.ClientSecret,
+			Scopes: []string{
+				"openid",
+				"profile",
+				"User.Read",
+				"email",
+			},
+			Endpoint: microsoft.AzureADEndpoint(cfg.Microsoft.AzureADEndpoint),
+		},
+	}
 }
 
 // Handles the user login. Allowed methods: OSI, Microsoft OAuth2 and Google OAuth2
@@ -66,7 +70,7 @@ func (ac *AuthController) LoginHandler(ctx *fiber.Ctx) error {
 		sess.Save()
 		slog.Info("oauth_state set into the session", "Origin", ctx.IP())
 
-		url = googleOAuthConfig.AuthCodeURL(state)
+		url = ac.googleOAuthConfig.AuthCodeURL(state)
 	} else if authMethod == "microsoft" {
 		state := ac.service.GenerateStateOAuthCookie()
 
@@ -78,13 +82,13 @@ func (ac *AuthController) LoginHandler(ctx *fiber.Ctx) error {
 		sess.Save()
 		slog.Info("oauth_state set into the session", "Origin", ctx.IP())
 
-		url = microsoftOAuthConfig.AuthCodeURL(state)
+		url = ac.microsoftOAuthConfig.AuthCodeURL(state)
 	} else if authMethod == "osi" {
 		if ctx.Method() != "POST" {
 			slog.Error("OSI login requires a POST request", "Origin", ctx.IP(), "Error", "OSI login requires a POST request")
 			return ctx.Status(http.StatusMethodNotAllowed).JSON(model.APIResponse{Status: "error", Code: http.StatusMethodNotAllowed, Message: "OSI login requires a POST request", Errors: map[string]any{"methodNotAllowed": ctx.Method()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
 		}
-		url = config.AuthenticatorURL + "/login"
+		url = ac.cfg.AuthenticatorURL + "/login"
 
 		type osiLoginResponse struct {
 			JWT      *string `json:"JWT"`
@@ -170,7 +174,7 @@ func (ac *AuthController) LoginHandler(ctx *fiber.Ctx) error {
 	return ctx.Redirect(url, http.StatusTemporaryRedirect)
 }
 
-// Deletes the user session
+// Deletes the user session and closes the database connections owned by it
 func (ac *AuthController) LogoutHandler(ctx *fiber.Ctx) error {
 	sess, ok := ctx.Locals("session").(*session.Session)
 	if !ok {
@@ -189,6 +193,8 @@ func (ac *AuthController) LogoutHandler(ctx *fiber.Ctx) error {
 		sess.Delete("oauth_state")
 	}
 
+	ac.service.CloseSessionConnections(sess.ID())
+
 	sess.Save()
 
 	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Logout done successfully", Data: map[string]any{"user": user}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
@@ -210,7 +216,7 @@ func (ac *AuthController) GoogleCallBackHandler(ctx *fiber.Ctx) error {
 
 	// Gets the authorization code passed through the callback route, and convert it into an authorization token. It needs to be validated to prevent from CSRF attacks.
 	code := ctx.Query("code")
-	token, err := googleOAuthConfig.Exchange(context.Background(), code)
+	token, err := ac.googleOAuthConfig.Exchange(context.Background(), code)
 	if err != nil {
 		slog.Error("Cannot convert the authorization code into a token", "Origin", ctx.IP(), "Error", err.Error())
 		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Cannot convert the authorization code into a token.", Errors: map[string]any{"exchange": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
@@ -247,7 +253,7 @@ func (ac *AuthController) MicrosoftCallBackHandler(ctx *fiber.Ctx) error {
 
 	// Gets the authorization code passed through the callback route, and convert it into an authorization token. It needs to be validated to prevent from CSRF attacks.
 	code := ctx.Query("code")
-	token, err := microsoftOAuthConfig.Exchange(context.Background(), code)
+	token, err := ac.microsoftOAuthConfig.Exchange(context.Background(), code)
 	if err != nil {
 		slog.Error("Cannot convert the authorization code into a token", "Origin", ctx.IP(), "Error", err.Error())
 		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Cannot convert the authorization code into a token.", Errors: map[string]any{"exchange": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
@@ -2,11 +2,16 @@ package controller
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
+	"github.com/RenanMonteiroS/MaestroSQLWeb/db"
 	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/repository"
 	"github.com/RenanMonteiroS/MaestroSQLWeb/service"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/session"
@@ -23,8 +28,22 @@ func NewDatabaseController(sv service.DatabaseService) DatabaseController {
 	return DatabaseController{service: sv}
 }
 
+// Gets the connection registry key of the request: the session ID plus the optional "connectionId" query parameter
+func connKey(ctx *fiber.Ctx, sess *session.Session) db.ConnKey {
+	return db.NewConnKey(sess.ID(), ctx.Query("connectionId"))
+}
+
+// Gets the e-mail of the authenticated user, or an empty string when no authentication method is used
+func sessionUser(sess *session.Session) string {
+	if userEmail, ok := sess.Get("userEmail").(string); ok {
+		return userEmail
+	}
+
+	return ""
+}
+
 // Handles the POST /connect endpoint.
-// Starts a connection pool for a database server instance. For each request, it checks if the user is authenticated.
+// Starts a connection pool for a database server instance, owned by the user session. For each request, it checks if the user is authenticated.
 func (dc *DatabaseController) ConnectDatabase(ctx *fiber.Ctx) error {
 	var connInfo model.ConnInfo
 
@@ -39,7 +58,8 @@ func (dc *DatabaseController) ConnectDatabase(ctx *fiber.Ctx) error {
 		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot bind JSON from request body", Errors: map[string]any{"bindJson": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
 	}
 
-	_, err = dc.service.ConnectDatabase(connInfo)
+	key := db.NewConnKey(sess.ID(), connInfo.ConnectionID)
+	_, err = dc.service.ConnectDatabase(key, connInfo)
 	if err != nil {
 		if errors.Is(err, service.ErrPortAndInstanceEmpty) {
 			slog.Error("Cannot connect to database", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
@@ -50,8 +70,16 @@ func (dc *DatabaseController) ConnectDatabase(ctx *fiber.Ctx) error {
 		}
 	}
 
-	slog.Info("Connection done successfully", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Host", connInfo.Host)
-	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Connection done successfully", Data: map[string]any{"server": connInfo.Host}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	slog.Info("Connection done successfully", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Host", connInfo.Host, "Connection", key.ConnectionID)
+
+	// Saves the session, so the session cookie is set even when no authentication method is used. Without it, the next request would get another session ID
+	err = sess.Save()
+	if err != nil {
+		slog.Error("Cannot save the session", "Origin", ctx.IP(), "Error", err.Error())
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot save the session", Errors: map[string]any{"session": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Connection done successfully", Data: map[string]any{"server": connInfo.Host, "connectionId": key.ConnectionID}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
 }
 
 // Handles the GET /databases endpoint.
@@ -62,7 +90,13 @@ func (dc *DatabaseController) GetDatabases(ctx *fiber.Ctx) error {
 		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
 	}
 
-	databases, err := dc.service.GetDatabases()
+	filter, err := databaseFilter(ctx)
+	if err != nil {
+		slog.Error("Invalid database filter", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid database filter", Errors: map[string]any{"filter": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	databases, err := dc.service.GetDatabases(connKey(ctx, sess), filter)
 	if err != nil {
 		slog.Error("Cannot get databases", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
 		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot get databases", Errors: map[string]any{"databases": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
@@ -72,16 +106,116 @@ func (dc *DatabaseController) GetDatabases(ctx *fiber.Ctx) error {
 	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: 200, Message: "Databases collected successfully", Data: map[string]any{"databases": databases}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
 }
 
+// Reads the database filter of the GET /databases query parameters: name, state, recoveryModel, compatibilityLevel, readOnly, system, snapshot, owner, minSize, maxSize (in bytes) and backupType
+func databaseFilter(ctx *fiber.Ctx) (model.DatabaseFilter, error) {
+	filter := model.DatabaseFilter{
+		Name:          ctx.Query("name"),
+		State:         ctx.Query("state"),
+		RecoveryModel: ctx.Query("recoveryModel"),
+		Owner:         ctx.Query("owner"),
+	}
+
+	var errs []error
+	parseInt := func(name string) int64 {
+		value := ctx.Query(name)
+		if value == "" {
+			return 0
+		}
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			errs = append(errs, fmt.Errorf("%v: must be a positive integer, got %q", name, value))
+		}
+		return parsed
+	}
+	parseBool := func(name string) *bool {
+		value := ctx.Query(name)
+		if value == "" {
+			return nil
+		}
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: must be true or false, got %q", name, value))
+			return nil
+		}
+		return &parsed
+	}
+
+	filter.CompatibilityLevel = int(parseInt("compatibilityLevel"))
+	filter.MinSizeBytes = parseInt("minSize")
+	filter.MaxSizeBytes = parseInt("maxSize")
+	filter.ReadOnly = parseBool("readOnly")
+	filter.System = parseBool("system")
+	filter.Snapshot = parseBool("snapshot")
+
+	if value := ctx.Query("backupType"); value != "" {
+		backupType, err := model.ParseBackupType(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("backupType: %w", err))
+		}
+		filter.BackupType = backupType
+	}
+
+	return filter, errors.Join(errs...)
+}
+
 // Handles the POST /backup endpoint.
-// Calls the backup functions. For each request, it checks if the user is authenticated.
+// Starts a backup job and returns its ID immediately. The job state is available at GET /jobs/{id}. For each request, it checks if the user is authenticated.
 func (dc *DatabaseController) BackupDatabase(ctx *fiber.Ctx) error {
-	type BackupPostRequired struct {
-		Databases     []model.Database `json:"databases" binding:"required"`
-		Path          string           `json:"path" binding:"required"`
-		ConcurrentOpe *int             `json:"concurrentOpe,omitempty"`
+	var postData model.BackupPostRequired
+
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
 	}
 
-	var postData BackupPostRequired
+	err := ctx.BodyParser(&postData)
+	if err != nil {
+		slog.Error("Cannot bind JSON from request body", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot bind JSON from request body", Errors: map[string]any{"bindJSON": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	backupType, err := model.ParseBackupType(postData.BackupType)
+	if err != nil {
+		slog.Error("Invalid backup type", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid backup type", Errors: map[string]any{"backupType": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	job, err := dc.service.BackupDatabase(connKey(ctx, sess), sessionUser(sess), postData.Databases, postData.Path, postData.Target, postData.ShipTo, backupType, postData.Options, postData.ConcurrentOpe)
+	if errors.Is(err, service.ErrInvalidBackupOptions) {
+		slog.Error("No backup was started", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err)
+		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid backup options", Errors: map[string]any{"options": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+	if errors.Is(err, service.ErrInvalidBackupTarget) || errors.Is(err, service.ErrBackupCredentialNotFound) {
+		slog.Error("No backup was started", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err)
+		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid backup target", Errors: map[string]any{"target": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+	if errors.Is(err, service.ErrBackupEncryptorNotFound) {
+		slog.Error("No backup was started", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err)
+		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid backup encryption", Errors: map[string]any{"encryption": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+	if errors.Is(err, service.ErrInvalidShipDestination) {
+		slog.Error("No backup was started", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err)
+		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid ship destination", Errors: map[string]any{"shipTo": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+	if err != nil {
+		slog.Error("No backup was started", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err)
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "No backup was started", Errors: map[string]any{"connect": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	data := map[string]any{"jobId": job.ID, "job": job, "backupPath": postData.Path, "backupType": backupType}
+	// The ship destination which cannot be shipped to does not stop the backup, and is reported apart from the errors
+	if len(job.Warnings) > 0 {
+		data["warnings"] = job.Warnings
+	}
+
+	slog.Info("Backup job started.", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Job", job.ID)
+	return ctx.Status(http.StatusAccepted).JSON(model.APIResponse{Status: "success", Code: http.StatusAccepted, Message: "Backup job started.", Data: data, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the POST /backup/plan endpoint.
+// Plans the backup of the databases, like POST /backup, and returns the plan for preview, without executing it. For each request, it checks if the user is authenticated.
+func (dc *DatabaseController) PlanBackup(ctx *fiber.Ctx) error {
+	var postData model.BackupPostRequired
 
 	sess, ok := ctx.Locals("session").(*session.Session)
 	if !ok {
@@ -94,31 +228,43 @@ func (dc *DatabaseController) BackupDatabase(ctx *fiber.Ctx) error {
 		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot bind JSON from request body", Errors: map[string]any{"bindJSON": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
 	}
 
-	databaseBackupList, errBackup, err, totalTime := dc.service.BackupDatabase(postData.Databases, postData.Path, postData.ConcurrentOpe)
+	backupType, err := model.ParseBackupType(postData.BackupType)
 	if err != nil {
-		slog.Error("No backup was completed", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err)
-		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "No backup was completed", Errors: map[string]any{"connect": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+		slog.Error("Invalid backup type", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid backup type", Errors: map[string]any{"backupType": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
 	}
 
-	if errBackup != nil && len(databaseBackupList) != 0 {
-		slog.Error("Backup completed with errors.", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", errBackup)
-		return ctx.Status(http.StatusMultiStatus).JSON(model.APIResponse{Status: "error", Code: http.StatusMultiStatus, Message: "Backup completed with errors.", Data: map[string]any{"backupDone": databaseBackupList, "totalTime": totalTime, "backupPath": postData.Path, "totalBackup": len(databaseBackupList)}, Errors: map[string]any{"backupErrors": errBackup, "totalBackupErrors": len(errBackup)}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
-	} else if errBackup != nil && len(databaseBackupList) == 0 {
-		var errStringList []string
-		for _, value := range errBackup {
-			errStringList = append(errStringList, value.Error())
-		}
+	plan, err := dc.service.PlanBackup(connKey(ctx, sess), postData.Databases, postData.Path, postData.Target, backupType, postData.Options)
+	if errors.Is(err, service.ErrInvalidBackupOptions) {
+		slog.Error("Cannot plan the backup", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err)
+		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid backup options", Errors: map[string]any{"options": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+	if errors.Is(err, service.ErrInvalidBackupTarget) {
+		slog.Error("Cannot plan the backup", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err)
+		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid backup target", Errors: map[string]any{"target": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+	if err != nil {
+		slog.Error("Cannot plan the backup", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err)
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot plan the backup", Errors: map[string]any{"backup": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
 
-		slog.Error("No backup was completed.", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", errBackup)
-		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "No backup was completed.", Errors: map[string]any{"backupErrors": errBackup, "totalBackupErrors": len(errBackup)}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	slog.Info("Backup planned.", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Databases", len(plan.Databases), "Rejected", len(plan.Rejected))
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Backup planned.", Data: map[string]any{"plan": plan}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the GET /backup/options endpoint.
+// Gets the default backup options, which are used when the /backup request does not set them. For each request, it checks if the user is authenticated.
+func (dc *DatabaseController) GetBackupOptions(ctx *fiber.Ctx) error {
+	_, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
 	}
 
-	slog.Info("Backup done successfully.", "Origin", ctx.IP(), "User", sess.Get("userEmail"))
-	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Backup done successfully.", Data: map[string]any{"backupDone": databaseBackupList, "backupPath": postData.Path, "totalBackup": len(databaseBackupList), "totalTime": totalTime}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Default backup options", Data: map[string]any{"options": dc.service.DefaultBackupOptions(), "backupTypes": model.BackupTypes}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
 }
 
 // Handles the POST /restore endpoint.
-// Calls the restore functions. For each request, it checks if the user is authenticated.
+// Starts a restore job and returns its ID immediately. The job state is available at GET /jobs/{id}. For each request, it checks if the user is authenticated.
 func (dc *DatabaseController) RestoreDatabase(ctx *fiber.Ctx) error {
 	var postData model.RestorePostRequired
 
@@ -133,22 +279,180 @@ func (dc *DatabaseController) RestoreDatabase(ctx *fiber.Ctx) error {
 		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot bind JSON from request body", Errors: map[string]any{"bindJSON": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
 	}
 
-	restoredDatabases, errRestore, err, totalTime := dc.service.RestoreDatabase(postData.Databases, postData.ConcurrentOpe)
+	job, err := dc.service.RestoreDatabase(connKey(ctx, sess), sessionUser(sess), postData.Databases, postData.ConcurrentOpe)
+	if errors.Is(err, service.ErrInvalidRestoreOptions) {
+		slog.Error("No restore was started", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err)
+		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid restore options", Errors: map[string]any{"options": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+	if err != nil {
+		slog.Error("No restore was started", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Restore operation error", Errors: map[string]any{"restore": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	slog.Info("Restore job started.", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Job", job.ID)
+	return ctx.Status(http.StatusAccepted).JSON(model.APIResponse{Status: "success", Code: http.StatusAccepted, Message: "Restore job started.", Data: map[string]any{"jobId": job.ID, "job": job}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the POST /restore/system endpoint.
+// Starts the restore job of one system database (master, model or msdb), confirmed by its name, and returns its ID immediately. For each request, it checks if the user is authenticated.
+func (dc *DatabaseController) RestoreSystemDatabase(ctx *fiber.Ctx) error {
+	var postData model.RestoreSystemPostRequired
+
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	err := ctx.BodyParser(&postData)
+	if err != nil {
+		slog.Error("Cannot bind JSON from request body", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot bind JSON from request body", Errors: map[string]any{"bindJSON": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	job, err := dc.service.RestoreSystemDatabase(connKey(ctx, sess), sessionUser(sess), postData)
+	if errors.Is(err, service.ErrInvalidSystemRestore) {
+		slog.Error("No system database restore was started", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err)
+		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid system database restore", Errors: map[string]any{"system": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+	if errors.Is(err, service.ErrInvalidRestoreOptions) {
+		slog.Error("No system database restore was started", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err)
+		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid restore options", Errors: map[string]any{"options": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
 	if err != nil {
-		slog.Error("No restore was completed", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		slog.Error("No system database restore was started", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
 		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Restore operation error", Errors: map[string]any{"restore": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
 	}
 
-	if errRestore != nil && len(restoredDatabases) > 0 {
-		slog.Error("Restore operation completed with errors", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", errRestore)
-		return ctx.Status(http.StatusMultiStatus).JSON(model.APIResponse{Status: "error", Code: http.StatusMultiStatus, Message: "Restore operation completed with errors", Data: map[string]any{"restoreDone": restoredDatabases, "totalRestore": len(restoredDatabases), "totalTime": totalTime}, Errors: map[string]any{"restoreErrors": errRestore, "totalRestoreErrors": len(errRestore)}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
-	} else if errRestore != nil && len(restoredDatabases) == 0 {
-		slog.Error("No restore was completed.", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", errRestore)
-		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "No restore was completed.", Errors: map[string]any{"restoreErrors": errRestore, "totalRestoreErrors": len(errRestore)}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	slog.Warn("System database restore job started.", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Job", job.ID, "Database", postData.Database.Name)
+	return ctx.Status(http.StatusAccepted).JSON(model.APIResponse{Status: "success", Code: http.StatusAccepted, Message: "System database restore job started.", Data: map[string]any{"jobId": job.ID, "job": job}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the POST /restore/plan endpoint.
+// Plans the restore of the databases, like POST /restore, and returns the plan for preview, without executing it. For each request, it checks if the user is authenticated.
+func (dc *DatabaseController) PlanRestore(ctx *fiber.Ctx) error {
+	var postData model.RestorePostRequired
+
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	err := ctx.BodyParser(&postData)
+	if err != nil {
+		slog.Error("Cannot bind JSON from request body", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot bind JSON from request body", Errors: map[string]any{"bindJSON": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	plans, errRestoreList, err := dc.service.PlanRestore(connKey(ctx, sess), postData.Databases)
+	if errors.Is(err, service.ErrInvalidRestoreOptions) {
+		slog.Error("Cannot plan the restore", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err)
+		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid restore options", Errors: map[string]any{"options": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+	if err != nil {
+		slog.Error("Cannot plan the restore", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot plan the restore", Errors: map[string]any{"restore": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
 	}
 
-	slog.Info("Restore done successfully.", "Origin", ctx.IP(), "User", sess.Get("userEmail"))
-	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Restore done successfully.", Data: map[string]any{"restoreDone": restoredDatabases, "totalRestore": len(restoredDatabases), "totalTime": totalTime}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	slog.Info("Restore planned.", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Databases", len(plans), "Errors", len(errRestoreList))
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Restore planned.", Data: map[string]any{"plans": plans, "errors": errRestoreList}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the POST /restore/chain/plan endpoint.
+// Plans the point-in-time restore of a database from the full, differential and log backups of a folder, and returns the chain for preview, without executing it. For each request, it checks if the user is authenticated.
+func (dc *DatabaseController) PlanRestoreChain(ctx *fiber.Ctx) error {
+	var postData model.RestoreChainPostRequired
+
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	err := ctx.BodyParser(&postData)
+	if err != nil {
+		slog.Error("Cannot bind JSON from request body", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot bind JSON from request body", Errors: map[string]any{"bindJSON": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	chain, err := dc.service.PlanRestoreChain(connKey(ctx, sess), postData)
+	if err != nil {
+		slog.Error("Cannot plan the restore chain", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(restoreChainErrorStatus(err)).JSON(model.APIResponse{Status: "error", Code: restoreChainErrorStatus(err), Message: "Cannot plan the restore chain", Errors: map[string]any{"restoreChain": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	slog.Info("Restore chain planned.", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Database", chain.Database, "Steps", len(chain.Steps))
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Restore chain planned.", Data: map[string]any{"chain": chain}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the POST /restore/chain endpoint.
+// Plans the point-in-time restore of a database, like POST /restore/chain/plan, and starts its job. Returns the job ID and the chain immediately. For each request, it checks if the user is authenticated.
+func (dc *DatabaseController) RestoreChain(ctx *fiber.Ctx) error {
+	var postData model.RestoreChainPostRequired
+
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	err := ctx.BodyParser(&postData)
+	if err != nil {
+		slog.Error("Cannot bind JSON from request body", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot bind JSON from request body", Errors: map[string]any{"bindJSON": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	job, chain, err := dc.service.RestoreChain(connKey(ctx, sess), sessionUser(sess), postData)
+	if err != nil {
+		slog.Error("No point-in-time restore was started", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(restoreChainErrorStatus(err)).JSON(model.APIResponse{Status: "error", Code: restoreChainErrorStatus(err), Message: "Cannot plan the restore chain", Errors: map[string]any{"restoreChain": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	slog.Info("Point-in-time restore job started.", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Job", job.ID)
+	return ctx.Status(http.StatusAccepted).JSON(model.APIResponse{Status: "success", Code: http.StatusAccepted, Message: "Point-in-time restore job started.", Data: map[string]any{"jobId": job.ID, "job": job, "chain": chain}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Maps the restore chain errors to the HTTP status: invalid requests are 400, chains which cannot be built from the backups are 422
+func restoreChainErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidRestoreChain):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrNoFullBackup), errors.Is(err, service.ErrRestoreChainGap), errors.Is(err, service.ErrStopAtNotCovered), errors.Is(err, service.ErrBackupEncryptorNotFound),
+		errors.Is(err, service.ErrTDECertificateNotFound):
+		return http.StatusUnprocessableEntity
+	}
+
+	return http.StatusInternalServerError
+}
+
+// Handles the POST /verify endpoint.
+// Starts a verify job, which checks existing backup files with RESTORE VERIFYONLY, and returns its ID immediately. The job state is available at GET /jobs/{id}. For each request, it checks if the user is authenticated.
+func (dc *DatabaseController) VerifyBackups(ctx *fiber.Ctx) error {
+	var postData model.VerifyPostRequired
+
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	err := ctx.BodyParser(&postData)
+	if err != nil {
+		slog.Error("Cannot bind JSON from request body", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot bind JSON from request body", Errors: map[string]any{"bindJSON": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	if len(postData.Files) == 0 {
+		slog.Error("No backup file to verify", "Origin", ctx.IP(), "User", sess.Get("userEmail"))
+		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "No backup file to verify", Errors: map[string]any{"files": "At least one backup file is required"}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	checksum := postData.Checksum == nil || *postData.Checksum
+
+	job, err := dc.service.VerifyBackups(connKey(ctx, sess), sessionUser(sess), postData.Files, checksum, postData.ConcurrentOpe)
+	if err != nil {
+		slog.Error("No verification was started", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Verify operation error", Errors: map[string]any{"verify": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	slog.Info("Verify job started.", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Job", job.ID)
+	return ctx.Status(http.StatusAccepted).JSON(model.APIResponse{Status: "success", Code: http.StatusAccepted, Message: "Verify job started.", Data: map[string]any{"jobId": job.ID, "job": job, "checksum": checksum}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
 }
 
 func (dc *DatabaseController) ListBackups(ctx *fiber.Ctx) error {
@@ -180,3 +484,271 @@ func (dc *DatabaseController) ListBackups(ctx *fiber.Ctx) error {
 	slog.Info("Backup files listed successfully", "Origin", ctx.IP(), "User", sess.Get("userEmail"))
 	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Backup files listed successfully", Data: map[string]any{"backupFiles": backupFiles}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
 }
+
+// Handles the POST /backup-files/inspect endpoint.
+// Reads the media, the backup sets and the database files of a backup file with RESTORE LABELONLY, HEADERONLY and FILELISTONLY, without restoring it. For each request, it checks if the user is authenticated.
+func (dc *DatabaseController) InspectBackupFile(ctx *fiber.Ctx) error {
+	var postData model.BackupInspectPostRequired
+
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	err := ctx.BodyParser(&postData)
+	if err != nil {
+		slog.Error("Cannot bind JSON from request body", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot bind JSON from request body", Errors: map[string]any{"bindJSON": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	inspection, err := dc.service.InspectBackupFile(connKey(ctx, sess), postData)
+	if errors.Is(err, service.ErrInvalidBackupFile) {
+		slog.Error("Invalid backup file", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid backup file", Errors: map[string]any{"backupPath": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+	if err != nil {
+		slog.Error("Cannot inspect the backup file", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot inspect the backup file", Errors: map[string]any{"inspect": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	slog.Info("Backup file inspected.", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Path", postData.BackupPath, "Database", inspection.DatabaseName)
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Backup file inspected.", Data: map[string]any{"inspection": inspection}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the POST /retention endpoint.
+// Deletes the old backups of a backup folder by the retention policy, or lists the backups which would be deleted when dryRun is true. For each request, it checks if the user is authenticated.
+func (dc *DatabaseController) ApplyRetention(ctx *fiber.Ctx) error {
+	var postData model.RetentionPostRequired
+
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	err := ctx.BodyParser(&postData)
+	if err != nil {
+		slog.Error("Cannot bind JSON from request body", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot bind JSON from request body", Errors: map[string]any{"bindJSON": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	requestedBy := sessionUser(sess)
+	if requestedBy == "" {
+		requestedBy = ctx.IP()
+	}
+
+	retention, err := dc.service.ApplyRetention(connKey(ctx, sess), requestedBy, postData)
+	if err != nil {
+		slog.Error("Cannot apply the retention", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		if errors.Is(err, service.ErrInvalidRetention) {
+			return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid retention request", Errors: map[string]any{"retention": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+		}
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot apply the retention", Errors: map[string]any{"retention": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	message := "Retention applied"
+	if retention.DryRun {
+		message = "Retention planned. Nothing was deleted"
+	}
+
+	slog.Info(message, "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Path", retention.Path, "Deleted", len(retention.Deleted))
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: message, Data: map[string]any{"retention": retention}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the GET /backup/encryptors endpoint.
+// Lists the certificates and asymmetric keys of the master database which can encrypt the backups, with their warnings. For each request, it checks if the user is authenticated.
+func (dc *DatabaseController) ListEncryptors(ctx *fiber.Ctx) error {
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	encryptors, err := dc.service.ListEncryptors(connKey(ctx, sess))
+	if err != nil {
+		slog.Error("Cannot list the encryptors", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot list the encryptors", Errors: map[string]any{"encryptors": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Encryptors listed successfully", Data: map[string]any{"encryptors": encryptors, "totalEncryptors": len(encryptors)}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the GET /credentials endpoint.
+// Lists the credentials of the connected server, used by the backups to URL. Their secrets are never returned. For each request, it checks if the user is authenticated.
+func (dc *DatabaseController) ListCredentials(ctx *fiber.Ctx) error {
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	credentials, err := dc.service.ListCredentials(connKey(ctx, sess))
+	if err != nil {
+		slog.Error("Cannot list the credentials", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot list the credentials", Errors: map[string]any{"credentials": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Credentials listed successfully", Data: map[string]any{"credentials": credentials, "totalCredentials": len(credentials)}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the POST /credentials endpoint.
+// Creates the credential of a backup URL in the connected server, or replaces the identity and secret of an existing one. For each request, it checks if the user is authenticated.
+func (dc *DatabaseController) SaveCredential(ctx *fiber.Ctx) error {
+	var postData model.CredentialPostRequired
+
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	err := ctx.BodyParser(&postData)
+	if err != nil {
+		slog.Error("Cannot bind JSON from request body", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot bind JSON from request body", Errors: map[string]any{"bindJSON": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	created, err := dc.service.SaveCredential(connKey(ctx, sess), postData)
+	if err != nil {
+		slog.Error("Cannot save the credential", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Credential", postData.Name, "Error", err.Error())
+		if errors.Is(err, service.ErrInvalidCredential) {
+			return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid credential", Errors: map[string]any{"credential": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+		}
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot save the credential", Errors: map[string]any{"credential": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	code, message := http.StatusOK, "Credential updated"
+	if created {
+		code, message = http.StatusCreated, "Credential created"
+	}
+
+	slog.Info(message, "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Credential", postData.Name, "Type", postData.Type)
+	return ctx.Status(code).JSON(model.APIResponse{Status: "success", Code: code, Message: message, Data: map[string]any{"name": postData.Name, "type": postData.Type}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the DELETE /credentials/{name} endpoint. The name is URL encoded, since the credentials of the backup URLs are named after them.
+// Drops the credential from the connected server. For each request, it checks if the user is authenticated.
+func (dc *DatabaseController) DeleteCredential(ctx *fiber.Ctx) error {
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	name, err := url.PathUnescape(ctx.Params("name"))
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid credential name", Errors: map[string]any{"name": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	err = dc.service.DropCredential(connKey(ctx, sess), name)
+	if err != nil {
+		slog.Error("Cannot drop the credential", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Credential", name, "Error", err.Error())
+		if errors.Is(err, repository.ErrCredentialNotFound) {
+			return ctx.Status(http.StatusNotFound).JSON(model.APIResponse{Status: "error", Code: http.StatusNotFound, Message: "Credential not found", Errors: map[string]any{"credential": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+		}
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot drop the credential", Errors: map[string]any{"credential": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	slog.Info("Credential dropped", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Credential", name)
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Credential dropped", Data: map[string]any{"name": name}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the GET /history/backups endpoint.
+// Gets the backup history recorded in msdb, filtered by the query parameters, and the last backups of each database. For each request, it checks if the user is authenticated.
+func (dc *DatabaseController) GetBackupHistory(ctx *fiber.Ctx) error {
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	filter, err := historyFilter(ctx)
+	if err == nil {
+		filter.BackupType, err = historyBackupType(ctx.Query("type"))
+	}
+	if err != nil {
+		slog.Error("Invalid history filter", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid history filter", Errors: map[string]any{"filter": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	history, err := dc.service.GetBackupHistory(connKey(ctx, sess), filter)
+	if err != nil {
+		slog.Error("Cannot get the backup history", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		if errors.Is(err, service.ErrInvalidHistoryFilter) {
+			return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid history filter", Errors: map[string]any{"filter": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+		}
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot get the backup history", Errors: map[string]any{"history": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	slog.Info("Backup history collected successfully", "Origin", ctx.IP(), "User", sess.Get("userEmail"))
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Backup history collected successfully", Data: map[string]any{"history": history}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the GET /history/restores endpoint.
+// Gets the restore history recorded in msdb, filtered by the query parameters. For each request, it checks if the user is authenticated.
+func (dc *DatabaseController) GetRestoreHistory(ctx *fiber.Ctx) error {
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	filter, err := historyFilter(ctx)
+	if err != nil {
+		slog.Error("Invalid history filter", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid history filter", Errors: map[string]any{"filter": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+	filter.UserName = ctx.Query("user")
+
+	history, err := dc.service.GetRestoreHistory(connKey(ctx, sess), filter)
+	if err != nil {
+		slog.Error("Cannot get the restore history", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		if errors.Is(err, service.ErrInvalidHistoryFilter) {
+			return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid history filter", Errors: map[string]any{"filter": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+		}
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot get the restore history", Errors: map[string]any{"history": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	slog.Info("Restore history collected successfully", "Origin", ctx.IP(), "User", sess.Get("userEmail"))
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Restore history collected successfully", Data: map[string]any{"history": history}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Reads the history filter of the query parameters: database, from and to (server local time, like 2025-07-18 or 2025-07-18T14:30:00), page and pageSize
+func historyFilter(ctx *fiber.Ctx) (model.HistoryFilter, error) {
+	filter := model.HistoryFilter{Database: ctx.Query("database")}
+
+	var errs []error
+	parseInt := func(name string) int {
+		value := ctx.Query(name)
+		if value == "" {
+			return 0
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			errs = append(errs, fmt.Errorf("%v: must be a positive integer, got %q", name, value))
+		}
+		return parsed
+	}
+
+	filter.Page = parseInt("page")
+	filter.PageSize = parseInt("pageSize")
+
+	var err error
+	filter.From, err = model.ParseHistoryDate(ctx.Query("from"), false)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("from: %w", err))
+	}
+	filter.To, err = model.ParseHistoryDate(ctx.Query("to"), true)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("to: %w", err))
+	}
+
+	return filter, errors.Join(errs...)
+}
+
+// Reads the type of the backup history filter. Unlike a backup request, an empty type means every type
+func historyBackupType(value string) (model.BackupType, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	backupType, err := model.ParseBackupType(value)
+	if err != nil {
+		return "", fmt.Errorf("type: %w", err)
+	}
+
+	return backupType, nil
+}
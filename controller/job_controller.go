@@ -0,0 +1,259 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/repository"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+)
+
+// Struct responsible for handle the HTTP requests related to the backup/restore jobs. Requires a JobService.
+type JobController struct {
+	service *service.JobService
+}
+
+// Creates an instance of JobController struct
+func NewJobController(sv *service.JobService) JobController {
+	return JobController{service: sv}
+}
+
+// Handles the GET /jobs endpoint.
+// Lists the jobs of the user session, from the newest to the oldest. For each request, it checks if the user is authenticated.
+func (jc *JobController) ListJobs(ctx *fiber.Ctx) error {
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	jobs := jc.service.List(sess.ID())
+
+	slog.Info("Jobs listed successfully", "Origin", ctx.IP(), "User", sess.Get("userEmail"))
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Jobs listed successfully", Data: map[string]any{"jobs": jobs, "totalJobs": len(jobs)}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the GET /jobs/{id} endpoint.
+// Gets the state of a job and of each one of its databases. For each request, it checks if the user is authenticated.
+func (jc *JobController) GetJob(ctx *fiber.Ctx) error {
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	job, err := jc.service.Get(ctx.Params("id"), sess.ID())
+	if err != nil {
+		slog.Error("Cannot get job", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Job", ctx.Params("id"), "Error", err.Error())
+		return ctx.Status(http.StatusNotFound).JSON(model.APIResponse{Status: "error", Code: http.StatusNotFound, Message: "Job not found", Errors: map[string]any{"job": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Job found", Data: map[string]any{"job": job}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the POST /jobs/{id}/cancel endpoint.
+// Cancels the whole job or, if the request body has a "databases" list, only those databases. The running statements are aborted
+// (or their sessions killed) in background, so the returned job may still have running databases. For each request, it checks if the user is authenticated.
+func (jc *JobController) CancelJob(ctx *fiber.Ctx) error {
+	var cancelRequest struct {
+		Databases []string `json:"databases"`
+	}
+
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	if len(ctx.Body()) > 0 {
+		err := ctx.BodyParser(&cancelRequest)
+		if err != nil {
+			slog.Error("Cannot bind JSON from request body", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+			return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot bind JSON from request body", Errors: map[string]any{"bindJson": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+		}
+	}
+
+	cancelledBy := sessionUser(sess)
+	if cancelledBy == "" {
+		cancelledBy = ctx.IP()
+	}
+
+	job, err := jc.service.Cancel(ctx.Params("id"), sess.ID(), cancelledBy, cancelRequest.Databases)
+	if err != nil {
+		slog.Error("Cannot cancel job", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Job", ctx.Params("id"), "Databases", cancelRequest.Databases, "Error", err.Error())
+		switch {
+		case errors.Is(err, service.ErrJobNotFound):
+			return ctx.Status(http.StatusNotFound).JSON(model.APIResponse{Status: "error", Code: http.StatusNotFound, Message: "Job not found", Errors: map[string]any{"job": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+		case errors.Is(err, service.ErrJobFinished):
+			return ctx.Status(http.StatusConflict).JSON(model.APIResponse{Status: "error", Code: http.StatusConflict, Message: "The job is already finished", Errors: map[string]any{"job": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+		default:
+			return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Cannot cancel the databases", Errors: map[string]any{"databases": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+		}
+	}
+
+	slog.Info("Job cancellation requested", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Job", job.ID, "Databases", cancelRequest.Databases)
+	return ctx.Status(http.StatusAccepted).JSON(model.APIResponse{Status: "success", Code: http.StatusAccepted, Message: "Cancellation requested", Data: map[string]any{"jobId": job.ID, "job": job}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the GET /jobs/{id}/events endpoint.
+// Streams the job state as Server-Sent Events: a "job" event is sent every time the job changes (state, per-database progress, errors),
+// and the stream is closed once the job finishes. For each request, it checks if the user is authenticated.
+func (jc *JobController) JobEvents(ctx *fiber.Ctx) error {
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	// The values are copied, because the request buffers are reused once the handler returns and the stream keeps running after it
+	jobID := strings.Clone(ctx.Params("id"))
+	sessionID := strings.Clone(sess.ID())
+
+	_, err := jc.service.Get(jobID, sessionID)
+	if err != nil {
+		slog.Error("Cannot stream job events", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Job", jobID, "Error", err.Error())
+		return ctx.Status(http.StatusNotFound).JSON(model.APIResponse{Status: "error", Code: http.StatusNotFound, Message: "Job not found", Errors: map[string]any{"job": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	ctx.Set("Content-Type", "text/event-stream")
+	ctx.Set("Cache-Control", "no-cache")
+	ctx.Set("Connection", "keep-alive")
+	ctx.Set("X-Accel-Buffering", "no")
+
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		var lastPayload []byte
+		lastWrite := time.Now()
+
+		for {
+			job, err := jc.service.Get(jobID, sessionID)
+			if err != nil {
+				fmt.Fprintf(w, "event: error\ndata: %q\n\n", err.Error())
+				w.Flush()
+				return
+			}
+
+			payload, err := json.Marshal(job)
+			if err != nil {
+				slog.Error("Cannot marshal job event", "Job", jobID, "Error", err)
+				return
+			}
+
+			if !bytes.Equal(payload, lastPayload) {
+				fmt.Fprintf(w, "event: job\ndata: %s\n\n", payload)
+				lastPayload = payload
+				lastWrite = time.Now()
+			} else if time.Since(lastWrite) > 15*time.Second {
+				// Comment line, which keeps proxies from closing an idle connection
+				fmt.Fprint(w, ": keep-alive\n\n")
+				lastWrite = time.Now()
+			}
+
+			// Flush fails once the client disconnects
+			if err := w.Flush(); err != nil {
+				return
+			}
+
+			if job.Finished() {
+				return
+			}
+
+			time.Sleep(time.Second)
+		}
+	})
+
+	return nil
+}
+
+// Handles the GET /operations endpoint.
+// Lists the operation history of every session, filtered by the query parameters: type, state, database, createdBy, server, scheduleId, from and to (the creation date,
+// like 2025-07-18 or 2025-07-18T14:30:00, in the application local time), page and pageSize. For each request, it checks if the user is authenticated.
+func (jc *JobController) ListOperations(ctx *fiber.Ctx) error {
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	filter, err := operationFilter(ctx)
+	if err != nil {
+		slog.Error("Invalid operation filter", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid operation filter", Errors: map[string]any{"filter": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	operations, err := jc.service.Operations(filter)
+	if err != nil {
+		slog.Error("Cannot list operations", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		if errors.Is(err, service.ErrInvalidOperationFilter) {
+			return ctx.Status(http.StatusBadRequest).JSON(model.APIResponse{Status: "error", Code: http.StatusBadRequest, Message: "Invalid operation filter", Errors: map[string]any{"filter": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+		}
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot list operations", Errors: map[string]any{"operations": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Operations listed successfully", Data: map[string]any{"operations": operations}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the GET /operations/{id} endpoint.
+// Gets one operation of the history, with the result of each one of its databases. For each request, it checks if the user is authenticated.
+func (jc *JobController) GetOperation(ctx *fiber.Ctx) error {
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	operation, err := jc.service.Operation(ctx.Params("id"))
+	if err != nil {
+		slog.Error("Cannot get operation", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Operation", ctx.Params("id"), "Error", err.Error())
+		if errors.Is(err, repository.ErrOperationNotFound) {
+			return ctx.Status(http.StatusNotFound).JSON(model.APIResponse{Status: "error", Code: http.StatusNotFound, Message: "Operation not found", Errors: map[string]any{"operation": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+		}
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot get operation", Errors: map[string]any{"operation": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Operation found", Data: map[string]any{"operation": operation}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Reads the operation filter of the GET /operations query parameters
+func operationFilter(ctx *fiber.Ctx) (model.OperationFilter, error) {
+	filter := model.OperationFilter{
+		Type:       model.JobType(ctx.Query("type")),
+		State:      model.JobState(ctx.Query("state")),
+		Database:   ctx.Query("database"),
+		CreatedBy:  ctx.Query("createdBy"),
+		Server:     ctx.Query("server"),
+		ScheduleID: ctx.Query("scheduleId"),
+	}
+
+	var errs []error
+	parseInt := func(name string) int {
+		value := ctx.Query(name)
+		if value == "" {
+			return 0
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			errs = append(errs, fmt.Errorf("%v: must be a positive integer, got %q", name, value))
+		}
+		return parsed
+	}
+
+	filter.Page = parseInt("page")
+	filter.PageSize = parseInt("pageSize")
+
+	var err error
+	filter.From, err = model.ParseOperationDate(ctx.Query("from"), false)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("from: %w", err))
+	}
+	filter.To, err = model.ParseOperationDate(ctx.Query("to"), true)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("to: %w", err))
+	}
+
+	return filter, errors.Join(errs...)
+}
@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/repository"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+)
+
+// Struct responsible for handle the HTTP requests related to the backup schedules. Requires a ScheduleService.
+type ScheduleController struct {
+	service *service.ScheduleService
+}
+
+// Creates an instance of ScheduleController struct
+func NewScheduleController(sv *service.ScheduleService) ScheduleController {
+	return ScheduleController{service: sv}
+}
+
+// Handles the GET /schedules endpoint.
+// Lists the backup schedules of every session, with their next run and their last run. For each request, it checks if the user is authenticated.
+func (sc *ScheduleController) ListSchedules(ctx *fiber.Ctx) error {
+	schedules := sc.service.List()
+
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Schedules listed successfully", Data: map[string]any{"schedules": schedules, "totalSchedules": len(schedules)}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the GET /schedules/{id} endpoint.
+// Gets the backup schedule. For each request, it checks if the user is authenticated.
+func (sc *ScheduleController) GetSchedule(ctx *fiber.Ctx) error {
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	schedule, err := sc.service.Get(ctx.Params("id"))
+	if err != nil {
+		return scheduleError(ctx, sess, "Cannot get the schedule", err)
+	}
+
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Schedule found", Data: map[string]any{"schedule": schedule}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the POST /schedules endpoint.
+// Creates a backup schedule, which runs the backup of the request on its cron expressions. For each request, it checks if the user is authenticated.
+func (sc *ScheduleController) CreateSchedule(ctx *fiber.Ctx) error {
+	var postData model.SchedulePostRequired
+
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	err := ctx.BodyParser(&postData)
+	if err != nil {
+		slog.Error("Cannot bind JSON from request body", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot bind JSON from request body", Errors: map[string]any{"bindJSON": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	createdBy := sessionUser(sess)
+	if createdBy == "" {
+		createdBy = ctx.IP()
+	}
+
+	schedule, err := sc.service.Create(createdBy, postData)
+	if err != nil {
+		return scheduleError(ctx, sess, "Cannot create the schedule", err)
+	}
+
+	slog.Info("Schedule created", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Schedule", schedule.ID)
+	return ctx.Status(http.StatusCreated).JSON(model.APIResponse{Status: "success", Code: http.StatusCreated, Message: "Schedule created", Data: map[string]any{"schedule": schedule}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the PUT /schedules/{id} endpoint.
+// Replaces the plan of the backup schedule. An empty connection password keeps the password of the schedule. For each request, it checks if the user is authenticated.
+func (sc *ScheduleController) UpdateSchedule(ctx *fiber.Ctx) error {
+	var postData model.SchedulePostRequired
+
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	err := ctx.BodyParser(&postData)
+	if err != nil {
+		slog.Error("Cannot bind JSON from request body", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Error", err.Error())
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Cannot bind JSON from request body", Errors: map[string]any{"bindJSON": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	schedule, err := sc.service.Update(ctx.Params("id"), postData)
+	if err != nil {
+		return scheduleError(ctx, sess, "Cannot update the schedule", err)
+	}
+
+	slog.Info("Schedule updated", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Schedule", schedule.ID)
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Schedule updated", Data: map[string]any{"schedule": schedule}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the DELETE /schedules/{id} endpoint.
+// Deletes the backup schedule. A run which is going is not cancelled. For each request, it checks if the user is authenticated.
+func (sc *ScheduleController) DeleteSchedule(ctx *fiber.Ctx) error {
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	err := sc.service.Delete(ctx.Params("id"))
+	if err != nil {
+		return scheduleError(ctx, sess, "Cannot delete the schedule", err)
+	}
+
+	slog.Info("Schedule deleted", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Schedule", ctx.Params("id"))
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Schedule deleted", Data: map[string]any{"scheduleId": ctx.Params("id")}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the POST /schedules/{id}/pause endpoint.
+// Pauses the backup schedule until it is resumed. For each request, it checks if the user is authenticated.
+func (sc *ScheduleController) PauseSchedule(ctx *fiber.Ctx) error {
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	schedule, err := sc.service.Pause(ctx.Params("id"))
+	if err != nil {
+		return scheduleError(ctx, sess, "Cannot pause the schedule", err)
+	}
+
+	slog.Info("Schedule paused", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Schedule", schedule.ID)
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Schedule paused", Data: map[string]any{"schedule": schedule}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the POST /schedules/{id}/resume endpoint.
+// Resumes the backup schedule, from the next time of its cron expressions. For each request, it checks if the user is authenticated.
+func (sc *ScheduleController) ResumeSchedule(ctx *fiber.Ctx) error {
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	schedule, err := sc.service.Resume(ctx.Params("id"))
+	if err != nil {
+		return scheduleError(ctx, sess, "Cannot resume the schedule", err)
+	}
+
+	slog.Info("Schedule resumed", "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Schedule", schedule.ID)
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Schedule resumed", Data: map[string]any{"schedule": schedule}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Handles the GET /schedules/{id}/retention endpoint.
+// Lists the backups which the retention policy of the schedule would delete from its path now, without deleting them. For each request, it checks if the user is authenticated.
+func (sc *ScheduleController) PlanScheduleRetention(ctx *fiber.Ctx) error {
+	sess, ok := ctx.Locals("session").(*session.Session)
+	if !ok {
+		return ctx.Status(http.StatusInternalServerError).JSON(model.APIResponse{Status: "error", Code: http.StatusInternalServerError, Message: "Internal server error: session not found", Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+	}
+
+	retention, err := sc.service.PlanRetention(ctx.Params("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRetention) {
+			err = fmt.Errorf("%w: %w", service.ErrInvalidSchedule, err)
+		}
+		return scheduleError(ctx, sess, "Cannot plan the retention of the schedule", err)
+	}
+
+	return ctx.Status(http.StatusOK).JSON(model.APIResponse{Status: "success", Code: http.StatusOK, Message: "Retention planned. Nothing was deleted", Data: map[string]any{"retention": retention}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
+
+// Logs the error of a schedule request and returns its response: 404 when the schedule does not exist, 400 when the request is not valid,
+// 503 when the schedules.secret configuration is not set, and 500 otherwise
+func scheduleError(ctx *fiber.Ctx, sess *session.Session, message string, err error) error {
+	slog.Error(message, "Origin", ctx.IP(), "User", sess.Get("userEmail"), "Schedule", ctx.Params("id"), "Error", err.Error())
+
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, repository.ErrScheduleNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidSchedule):
+		code = http.StatusBadRequest
+	case errors.Is(err, service.ErrSchedulesSecretNotSet):
+		code = http.StatusServiceUnavailable
+	}
+
+	return ctx.Status(code).JSON(model.APIResponse{Status: "error", Code: code, Message: message, Errors: map[string]any{"schedule": err.Error()}, Timestamp: time.Now().Format(time.RFC3339), Path: ctx.Path()})
+}
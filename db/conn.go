@@ -7,23 +7,25 @@ import (
 	"net/url"
 	"strconv"
 
+	"github.com/RenanMonteiroS/MaestroSQLWeb/config"
 	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
 	_ "github.com/microsoft/go-mssqldb"
 )
 
-// Creates a connection pool using the provided connection information. It don't uses encryption and connects by default to the [master] database
-func ConnDb(connInfo model.ConnInfo) (*sql.DB, error) {
+// Creates a connection pool using the provided connection information. It connects by default to the [master] database.
+// When the encryption or the trustServerCertificate are not set in connInfo, the defaults from the database configuration are used
+func ConnDb(connInfo model.ConnInfo, cfg config.DatabaseConfig) (*sql.DB, error) {
 	queryParams := url.Values{}
 	var u *url.URL
 
 	if connInfo.Encryption == "" {
-		queryParams.Add("encrypt", "mandatory")
+		queryParams.Add("encrypt", cfg.DefaultEncryption)
 	} else {
 		queryParams.Add("encrypt", connInfo.Encryption)
 	}
 
 	if connInfo.TrustServerCertificate == nil {
-		queryParams.Add("trustServerCertificate", "false")
+		queryParams.Add("trustServerCertificate", strconv.FormatBool(cfg.DefaultTrustServerCertificate))
 	} else {
 		queryParams.Add("trustServerCertificate", strconv.FormatBool(*connInfo.TrustServerCertificate))
 	}
@@ -50,9 +52,9 @@ func ConnDb(connInfo model.ConnInfo) (*sql.DB, error) {
 
 	slog.Info("Trying to connect to the database: ", "ConnInfo", connInfo)
 
-	/* if connInfo.MaxConnections != 0 {
-		db.SetMaxOpenConns(connInfo.MaxConnections)
-	} */
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
 
 	// Checks the database connection
 	err = db.Ping()
@@ -0,0 +1,213 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+)
+
+// DefaultConnectionID is used when the caller does not name the connection
+const DefaultConnectionID = "default"
+
+// ErrConnNotFound is returned when there is no connection registered for the session/connection ID pair.
+var ErrConnNotFound = errors.New("Connection was not set. Try to call /connect with the connection parameters")
+
+// ConnKey identifies a connection pool inside the registry. A session can own several named connections, identified by ConnectionID.
+type ConnKey struct {
+	SessionID    string
+	ConnectionID string
+}
+
+// NewConnKey creates a ConnKey. If the connection ID is empty, DefaultConnectionID is used.
+func NewConnKey(sessionID string, connectionID string) ConnKey {
+	if connectionID == "" {
+		connectionID = DefaultConnectionID
+	}
+
+	return ConnKey{SessionID: sessionID, ConnectionID: connectionID}
+}
+
+type registeredConn struct {
+	conn     *sql.DB
+	connInfo model.ConnInfo
+	lastUsed time.Time
+	active   int  // Jobs using the connection, which keep it open (see Acquire)
+	removed  bool // The connection was removed from the registry, and is closed once no job uses it
+}
+
+// ConnRegistry stores the connection pools created by each user session, so that one session cannot use (or overwrite) the connection of another.
+// Connections are closed and evicted when the session no longer exists in the session store, when they are idle for longer than idleTimeout, or when the session logs out.
+// A connection used by a running job is never idle, and when it is removed it is only closed once the job releases it
+type ConnRegistry struct {
+	mu           sync.Mutex
+	conns        map[ConnKey]*registeredConn
+	idleTimeout  time.Duration
+	sessionAlive func(sessionID string) bool
+}
+
+// Creates an instance of ConnRegistry.
+// Args: idleTimeout -> Time without use after which a connection is closed (0 disables it); sessionAlive -> Reports if a session still exists in the session store (nil disables it)
+func NewConnRegistry(idleTimeout time.Duration, sessionAlive func(sessionID string) bool) *ConnRegistry {
+	return &ConnRegistry{
+		conns:        make(map[ConnKey]*registeredConn),
+		idleTimeout:  idleTimeout,
+		sessionAlive: sessionAlive,
+	}
+}
+
+// Register stores the connection pool for the key. If the key already had a connection, the old one is closed.
+func (cr *ConnRegistry) Register(key ConnKey, conn *sql.DB, connInfo model.ConnInfo) {
+	cr.mu.Lock()
+	old, ok := cr.conns[key]
+	cr.conns[key] = &registeredConn{conn: conn, connInfo: connInfo, lastUsed: time.Now()}
+	cr.mu.Unlock()
+
+	if ok && old.conn != conn {
+		slog.Info("Replacing connection", "Session", key.SessionID, "Connection", key.ConnectionID, "Host", old.connInfo.Host)
+		cr.closeRemoved(old)
+	}
+}
+
+// Get returns the connection pool related to the key, refreshing its last usage time.
+func (cr *ConnRegistry) Get(key ConnKey) (*sql.DB, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	rc, ok := cr.conns[key]
+	if !ok {
+		return nil, ErrConnNotFound
+	}
+	rc.lastUsed = time.Now()
+
+	return rc.conn, nil
+}
+
+// Acquire returns the connection pool related to the key, like Get, for a background job. The connection is not evicted while the job runs,
+// and if it is removed (logout, expired session, replaced connection), it is only closed once every job released it.
+// The returned function releases the connection, and must be called once the job no longer uses it
+func (cr *ConnRegistry) Acquire(key ConnKey) (*sql.DB, func(), error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	rc, ok := cr.conns[key]
+	if !ok {
+		return nil, nil, ErrConnNotFound
+	}
+	rc.lastUsed = time.Now()
+	rc.active++
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			cr.mu.Lock()
+			rc.active--
+			rc.lastUsed = time.Now()
+			closeNow := rc.removed && rc.active == 0
+			cr.mu.Unlock()
+
+			if closeNow {
+				slog.Info("Closing released connection", "Session", key.SessionID, "Connection", key.ConnectionID, "Host", rc.connInfo.Host)
+				rc.conn.Close()
+			}
+		})
+	}
+
+	return rc.conn, release, nil
+}
+
+// Closes a connection removed from the registry, unless a job still uses it: then it is closed when the last job releases it (see Acquire)
+func (cr *ConnRegistry) closeRemoved(rc *registeredConn) {
+	cr.mu.Lock()
+	rc.removed = true
+	active := rc.active
+	cr.mu.Unlock()
+
+	if active > 0 {
+		slog.Info("Connection removed while jobs use it. It is closed once they finish", "Host", rc.connInfo.Host, "Jobs", active)
+		return
+	}
+
+	rc.conn.Close()
+}
+
+// Server returns the SQL Server instance of the connection related to the key, like host\instance or host,port, or an empty string if it does not exist.
+func (cr *ConnRegistry) Server(key ConnKey) string {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	rc, ok := cr.conns[key]
+	if !ok {
+		return ""
+	}
+
+	return rc.connInfo.Server()
+}
+
+// Close closes and removes the connection related to the key, if it exists.
+func (cr *ConnRegistry) Close(key ConnKey) {
+	cr.mu.Lock()
+	rc, ok := cr.conns[key]
+	delete(cr.conns, key)
+	cr.mu.Unlock()
+
+	if ok {
+		cr.closeRemoved(rc)
+	}
+}
+
+// CloseSession closes and removes every connection owned by the session. Returns how many connections were closed.
+func (cr *ConnRegistry) CloseSession(sessionID string) int {
+	var toClose []*registeredConn
+
+	cr.mu.Lock()
+	for key, rc := range cr.conns {
+		if key.SessionID == sessionID {
+			toClose = append(toClose, rc)
+			delete(cr.conns, key)
+		}
+	}
+	cr.mu.Unlock()
+
+	for _, rc := range toClose {
+		cr.closeRemoved(rc)
+	}
+
+	return len(toClose)
+}
+
+// Evict closes the connections whose session has expired or that are idle for longer than the idle timeout. Connections used by running jobs are not idle.
+func (cr *ConnRegistry) Evict() {
+	var toClose []*registeredConn
+
+	cr.mu.Lock()
+	for key, rc := range cr.conns {
+		expired := cr.sessionAlive != nil && !cr.sessionAlive(key.SessionID)
+		idle := cr.idleTimeout > 0 && rc.active == 0 && time.Since(rc.lastUsed) > cr.idleTimeout
+		if expired || idle {
+			slog.Info("Evicting connection", "Session", key.SessionID, "Connection", key.ConnectionID, "Host", rc.connInfo.Host, "SessionExpired", expired, "Idle", idle)
+			toClose = append(toClose, rc)
+			delete(cr.conns, key)
+		}
+	}
+	cr.mu.Unlock()
+
+	for _, rc := range toClose {
+		cr.closeRemoved(rc)
+	}
+}
+
+// StartJanitor runs Evict on every interval, in a goroutine, for the whole application lifetime.
+func (cr *ConnRegistry) StartJanitor(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			cr.Evict()
+		}
+	}()
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+)
+
+// A driver whose connections do nothing, so the tests can tell an open pool (Ping succeeds) from a closed one
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func init() {
+	sql.Register("fake", fakeDriver{})
+}
+
+func openFake(t *testing.T) *sql.DB {
+	t.Helper()
+	conn, err := sql.Open("fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func isClosed(conn *sql.DB) bool {
+	return conn.Ping() != nil
+}
+
+func TestConnRegistryKeepsAcquiredConnections(t *testing.T) {
+	tests := []struct {
+		name   string
+		remove func(cr *ConnRegistry, key ConnKey)
+	}{
+		{"close", func(cr *ConnRegistry, key ConnKey) { cr.Close(key) }},
+		{"logout", func(cr *ConnRegistry, key ConnKey) { cr.CloseSession(key.SessionID) }},
+		{"expired session", func(cr *ConnRegistry, key ConnKey) {
+			cr.sessionAlive = func(string) bool { return false }
+			cr.Evict()
+		}},
+		{"replaced", func(cr *ConnRegistry, key ConnKey) { cr.Register(key, openFake(t), model.ConnInfo{}) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr := NewConnRegistry(0, nil)
+			key := NewConnKey("session", "")
+			conn := openFake(t)
+			cr.Register(key, conn, model.ConnInfo{})
+
+			_, release, err := cr.Acquire(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tt.remove(cr, key)
+			if isClosed(conn) {
+				t.Fatal("the connection was closed while a job used it")
+			}
+
+			release()
+			if !isClosed(conn) {
+				t.Fatal("the connection was not closed once the job released it")
+			}
+			release()
+		})
+	}
+}
+
+func TestConnRegistryEvictsIdleConnections(t *testing.T) {
+	tests := []struct {
+		name    string
+		acquire bool
+		evicted bool
+	}{
+		{"idle", false, true},
+		{"used by a job", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr := NewConnRegistry(time.Millisecond, nil)
+			key := NewConnKey("session", "")
+			conn := openFake(t)
+			cr.Register(key, conn, model.ConnInfo{})
+			if tt.acquire {
+				_, release, err := cr.Acquire(key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer release()
+			}
+
+			time.Sleep(5 * time.Millisecond)
+			cr.Evict()
+
+			_, err := cr.Get(key)
+			if evicted := errors.Is(err, ErrConnNotFound); evicted != tt.evicted {
+				t.Fatalf("evicted = %v, want %v", evicted, tt.evicted)
+			}
+			if isClosed(conn) != tt.evicted {
+				t.Fatalf("closed = %v, want %v", isClosed(conn), tt.evicted)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ package main
 import (
 	"embed"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io/fs"
 	"log/slog"
@@ -18,6 +19,7 @@ import (
 
 	"github.com/RenanMonteiroS/MaestroSQLWeb/config"
 	"github.com/RenanMonteiroS/MaestroSQLWeb/controller"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/db"
 	"github.com/RenanMonteiroS/MaestroSQLWeb/middleware"
 	"github.com/RenanMonteiroS/MaestroSQLWeb/repository"
 	"github.com/RenanMonteiroS/MaestroSQLWeb/service"
@@ -42,6 +44,14 @@ var StaticFS embed.FS
 var LocaleFS embed.FS
 
 func main() {
+	// Handles the "config validate" subcommand before starting anything else
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:]))
+	}
+
+	configPath := flag.String("config", os.Getenv(config.ConfigPathEnv), "Path to the configuration file (.yaml, .yml, .toml or .json)")
+	flag.Parse()
+
 	// Create logs
 	logFile, err := os.OpenFile("app.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
@@ -55,9 +65,17 @@ func main() {
 	}))
 	slog.SetDefault(logger)
 
+	// Loads the runtime configuration: defaults, then the configuration file, then the MAESTRO_* environment variables
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		slog.Error("Invalid configuration", "Path", *configPath, "Error", err)
+		os.Exit(1)
+	}
+
 	//Get server network information
-	serverIP := config.AppHost
-	serverAddr := fmt.Sprintf(config.AppHost + ":" + fmt.Sprint(config.AppPort))
+	serverIP := cfg.App.Host
+	serverAddr := fmt.Sprintf("%v:%v", cfg.App.Host, cfg.App.Port)
 	localIP := getOutboundIP()
 	var serverProtocol string
 
@@ -84,15 +102,6 @@ func main() {
 		slog.Error("Failed to load pt-BR messages", "Error", err)
 	}
 
-	// Initialize the auth layers instances
-	AuthService := service.NewAuthService()
-	AuthController := controller.NewAuthController(AuthService)
-
-	// Initialize the database layers instances
-	DatabaseRepository := repository.NewDatabaseRepository(nil)
-	DatabaseService := service.NewDatabaseService(DatabaseRepository)
-	DatabaseController := controller.NewDatabaseController(DatabaseService)
-
 	// Create subfilesystem to serve static
 	staticSub, err := fs.Sub(StaticFS, "static")
 	if err != nil {
@@ -100,28 +109,73 @@ func main() {
 	}
 
 	// Starts a Session Cookie Store
+	sessionExpiration := cfg.App.SessionExpiration.Std()
 	store := session.New(session.Config{
 		KeyLookup:      "cookie:maestro-sessions",
 		CookieDomain:   "",
 		CookiePath:     "/",
-		CookieSecure:   config.AppCertificateUsage,
+		CookieSecure:   cfg.App.CertificateUsage,
 		CookieHTTPOnly: true,
 		CookieSameSite: "Lax",
-		Expiration:     time.Hour * 24,
+		Expiration:     sessionExpiration,
 		KeyGenerator: func() string {
 			return utils.UUID()
 		},
 	})
 
+	// Starts the connection registry. Each session owns its connection pools, which are evicted once the session expires or stays idle
+	connRegistry := db.NewConnRegistry(sessionExpiration, func(sessionID string) bool {
+		rawData, err := store.Storage.Get(sessionID)
+		return err == nil && rawData != nil
+	})
+	connRegistry.StartJanitor(time.Minute)
+
+	// Initialize the auth layers instances
+	AuthService := service.NewAuthService(connRegistry)
+	AuthController := controller.NewAuthController(AuthService, cfg.Auth)
+
+	// Opens the operation history, which keeps every job across restarts
+	operationStore, err := repository.NewOperationStore(cfg.Jobs.HistoryPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open the operation history %v: %v\n", cfg.Jobs.HistoryPath, err)
+		slog.Error("Cannot open the operation history", "Path", cfg.Jobs.HistoryPath, "Error", err)
+		os.Exit(1)
+	}
+	defer operationStore.Close()
+
+	// Initialize the job engine instances
+	JobService := service.NewJobService(cfg.Jobs.Retention.Std(), operationStore)
+	JobController := controller.NewJobController(JobService)
+
+	// Initialize the database layers instances
+	DatabaseService := service.NewDatabaseService(connRegistry, JobService, cfg.Database, cfg.Jobs, cfg.Backup, cfg.Storage)
+	DatabaseController := controller.NewDatabaseController(DatabaseService)
+
+	// Initialize the backup schedule instances. The schedules are kept in the operation history file
+	scheduleStore, err := repository.NewScheduleStore(operationStore)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open the backup schedules: %v\n", err)
+		slog.Error("Cannot open the backup schedules", "Error", err)
+		os.Exit(1)
+	}
+	ScheduleService, err := service.NewScheduleService(scheduleStore, &DatabaseService, cfg.Schedules.Secret)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot load the backup schedules: %v\n", err)
+		slog.Error("Cannot load the backup schedules", "Error", err)
+		os.Exit(1)
+	}
+	ScheduleService.Start()
+	ScheduleController := controller.NewScheduleController(ScheduleService)
+
 	//Middlewares session
 
 	// Configure CORS usage
-	if config.AppCORSUsage {
-		server.Use(middleware.CorsMiddleware())
+	if cfg.App.CORSUsage {
+		server.Use(middleware.CorsMiddleware(cfg.App))
 	}
 
 	// Configure CSRF token usage
-	if config.AppCSRFTokenUsage {
+	if cfg.App.CSRFTokenUsage {
 		server.Use(middleware.CsrfMiddleware())
 	}
 
@@ -160,29 +214,29 @@ func main() {
 		var authenticationGoogleOAuth2Usage bool
 		var authenticationMicrosoftOAuth2Usage bool
 
-		if slices.Contains(config.AuthenticationMethods, "OSI") {
+		if slices.Contains(cfg.Auth.Methods, "OSI") {
 			authenticationOSIUsage = true
 			authenticationUsage = true
 		}
 
-		if slices.Contains(config.AuthenticationMethods, "OAUTH2GOOGLE") {
+		if slices.Contains(cfg.Auth.Methods, "OAUTH2GOOGLE") {
 			authenticationGoogleOAuth2Usage = true
 			authenticationUsage = true
 		}
 
-		if slices.Contains(config.AuthenticationMethods, "OAUTH2MICROSOFT") {
+		if slices.Contains(cfg.Auth.Methods, "OAUTH2MICROSOFT") {
 			authenticationMicrosoftOAuth2Usage = true
 			authenticationUsage = true
 		}
 
 		varToServe = fiber.Map{
 			"appHost":                            serverIP,
-			"appPort":                            config.AppPort,
-			"appCertificateUsage":                config.AppCertificateUsage,
-			"appCSRFTokenUsage":                  config.AppCSRFTokenUsage,
-			"authenticatorURL":                   config.AuthenticatorURL,
+			"appPort":                            cfg.App.Port,
+			"appCertificateUsage":                cfg.App.CertificateUsage,
+			"appCSRFTokenUsage":                  cfg.App.CSRFTokenUsage,
+			"authenticatorURL":                   cfg.Auth.AuthenticatorURL,
 			"authenticationUsage":                authenticationUsage,
-			"authenticationMethods":              config.AuthenticationMethods,
+			"authenticationMethods":              cfg.Auth.Methods,
 			"authenticationOSIUsage":             authenticationOSIUsage,
 			"authenticationGoogleOAuth2Usage":    authenticationGoogleOAuth2Usage,
 			"authenticationMicrosoftOAuth2Usage": authenticationMicrosoftOAuth2Usage,
@@ -193,7 +247,7 @@ func main() {
 			},
 		}
 
-		if config.AppCSRFTokenUsage {
+		if cfg.App.CSRFTokenUsage {
 			varToServe["csrfToken"] = ctx.Locals("csrf")
 		}
 		return ctx.Render("backupForm.html", varToServe)
@@ -201,7 +255,7 @@ func main() {
 
 	// If the app uses some sort of authentication, starts a security middleware
 	protected := server.Group("/api")
-	if len(config.AuthenticationMethods) > 0 {
+	if len(cfg.Auth.Methods) > 0 {
 		protected.Use(middleware.AuthMiddleware())
 	}
 
@@ -209,8 +263,37 @@ func main() {
 		protected.Post("/connect", DatabaseController.ConnectDatabase)
 		protected.Get("/databases", DatabaseController.GetDatabases)
 		protected.Post("/backup", DatabaseController.BackupDatabase)
+		protected.Get("/backup/options", DatabaseController.GetBackupOptions)
+		protected.Get("/backup/encryptors", DatabaseController.ListEncryptors)
+		protected.Post("/backup/plan", DatabaseController.PlanBackup)
 		protected.Post("/restore", DatabaseController.RestoreDatabase)
+		protected.Post("/restore/plan", DatabaseController.PlanRestore)
+		protected.Post("/restore/system", DatabaseController.RestoreSystemDatabase)
+		protected.Post("/restore/chain", DatabaseController.RestoreChain)
+		protected.Post("/restore/chain/plan", DatabaseController.PlanRestoreChain)
+		protected.Post("/verify", DatabaseController.VerifyBackups)
 		protected.Post("/list-backups", DatabaseController.ListBackups)
+		protected.Post("/backup-files/inspect", DatabaseController.InspectBackupFile)
+		protected.Post("/retention", DatabaseController.ApplyRetention)
+		protected.Get("/credentials", DatabaseController.ListCredentials)
+		protected.Post("/credentials", DatabaseController.SaveCredential)
+		protected.Delete("/credentials/:name", DatabaseController.DeleteCredential)
+		protected.Get("/history/backups", DatabaseController.GetBackupHistory)
+		protected.Get("/history/restores", DatabaseController.GetRestoreHistory)
+		protected.Get("/jobs", JobController.ListJobs)
+		protected.Get("/jobs/:id", JobController.GetJob)
+		protected.Get("/jobs/:id/events", JobController.JobEvents)
+		protected.Post("/jobs/:id/cancel", JobController.CancelJob)
+		protected.Get("/operations", JobController.ListOperations)
+		protected.Get("/operations/:id", JobController.GetOperation)
+		protected.Get("/schedules", ScheduleController.ListSchedules)
+		protected.Post("/schedules", ScheduleController.CreateSchedule)
+		protected.Get("/schedules/:id", ScheduleController.GetSchedule)
+		protected.Put("/schedules/:id", ScheduleController.UpdateSchedule)
+		protected.Delete("/schedules/:id", ScheduleController.DeleteSchedule)
+		protected.Post("/schedules/:id/pause", ScheduleController.PauseSchedule)
+		protected.Post("/schedules/:id/resume", ScheduleController.ResumeSchedule)
+		protected.Get("/schedules/:id/retention", ScheduleController.PlanScheduleRetention)
 	}
 
 	// Not found route
@@ -235,18 +318,18 @@ func main() {
 
 	// Gets the server network information
 	serverIP = localIP
-	serverAddr = fmt.Sprintf(config.AppHost + ":" + fmt.Sprint(config.AppPort))
+	serverAddr = fmt.Sprintf("%v:%v", cfg.App.Host, cfg.App.Port)
 
-	if config.AppCertificateUsage {
+	if cfg.App.CertificateUsage {
 		serverProtocol = "https"
 	} else {
 		serverProtocol = "http"
 	}
 
-	if config.AppOpenOnceRunned {
+	if cfg.App.OpenOnceRunned {
 		// Opens the URL in the browser and starts the server
-		if config.AppHost == "0.0.0.0" {
-			go openFile(fmt.Sprintf("%v://%v:%v/", serverProtocol, localIP, config.AppPort))
+		if cfg.App.Host == "0.0.0.0" {
+			go openFile(fmt.Sprintf("%v://%v:%v/", serverProtocol, localIP, cfg.App.Port))
 		} else {
 			go openFile(fmt.Sprintf("%v://%v/", serverProtocol, serverAddr))
 		}
@@ -255,14 +338,42 @@ func main() {
 	fmt.Printf("MaestroSQL started. Your application is running at: http://%v/", serverAddr)
 	logger.Info(fmt.Sprintf("MaestroSQL started. Your application is running at: http://%v/", serverAddr))
 
-	if config.AppCertificateUsage {
-		server.ListenTLS(serverAddr, config.AppCertificateLocation, config.AppCertificateKeyLocation)
+	if cfg.App.CertificateUsage {
+		server.ListenTLS(serverAddr, cfg.App.CertificateLocation, cfg.App.CertificateKeyLocation)
 	} else {
 		server.Listen(serverAddr)
 	}
 
 }
 
+// Handles the "config" subcommand. Usage: maestrosql config validate [--config path]
+// Loads and validates the configuration without starting the server, returning the process exit code.
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, "Usage: maestrosql config validate [--config path]")
+		return 2
+	}
+
+	flagSet := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	configPath := flagSet.String("config", os.Getenv(config.ConfigPathEnv), "Path to the configuration file (.yaml, .yml, .toml or .json)")
+	if err := flagSet.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	_, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		return 1
+	}
+
+	if *configPath == "" {
+		fmt.Println("Configuration is valid (defaults and environment variables only)")
+	} else {
+		fmt.Printf("Configuration %v is valid\n", *configPath)
+	}
+	return 0
+}
+
 // Opens the browser to some URL. The command executed depends on the type of operating system.
 func openFile(url string) error {
 	var cmd string
@@ -6,9 +6,9 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 )
 
-func CorsMiddleware() fiber.Handler {
+func CorsMiddleware(cfg config.AppConfig) fiber.Handler {
 	return cors.New(cors.Config{
-		AllowOrigins:     config.AppCORSAllowOrigins,
+		AllowOrigins:     cfg.CORSAllowOrigins,
 		AllowMethods:     "GET,POST",
 		AllowHeaders:     "Content-Type, Authorization, Accept-Language, X-Csrf-Token",
 		AllowCredentials: true,
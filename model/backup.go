@@ -0,0 +1,213 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// BackupType is the kind of backup executed by /api/backup. Each type writes a file with its own extension.
+type BackupType string
+
+const (
+	BackupFull         BackupType = "full"         // BACKUP DATABASE, written to a .bak file
+	BackupDifferential BackupType = "differential" // BACKUP DATABASE ... WITH DIFFERENTIAL, written to a .dif file
+	BackupLog          BackupType = "log"          // BACKUP LOG, written to a .trn file
+)
+
+// MaxStripes is the maximum count of backup devices of a BACKUP statement
+const MaxStripes = 64
+
+// BackupTypes lists the accepted backup types
+var BackupTypes = []BackupType{BackupFull, BackupDifferential, BackupLog}
+
+// ParseBackupType validates the backup type of a request. An empty value is a full backup.
+func ParseBackupType(value string) (BackupType, error) {
+	if value == "" {
+		return BackupFull, nil
+	}
+
+	for _, backupType := range BackupTypes {
+		if BackupType(value) == backupType {
+			return backupType, nil
+		}
+	}
+
+	return "", fmt.Errorf("Unknown backup type %q. Accepts: full, differential, log", value)
+}
+
+// Extension returns the file extension of the backup type, with the leading dot
+func (bt BackupType) Extension() string {
+	switch bt {
+	case BackupDifferential:
+		return ".dif"
+	case BackupLog:
+		return ".trn"
+	default:
+		return ".bak"
+	}
+}
+
+// BackupTypeFromExtension returns the backup type of the file extension (.bak, .dif or .trn, in any case), the reverse of Extension.
+// It reports false for the other extensions
+func BackupTypeFromExtension(extension string) (BackupType, bool) {
+	for _, backupType := range BackupTypes {
+		if strings.EqualFold(extension, backupType.Extension()) {
+			return backupType, true
+		}
+	}
+
+	return "", false
+}
+
+// BackupOptions are the WITH options of the BACKUP statement, sent in the /api/backup request. Unset fields (nil or 0) take the server-side defaults (backup.* configuration).
+// Description and Name are passed to the statement as parameters, and the numeric options are validated before being rendered.
+type BackupOptions struct {
+	Compression        *bool  `json:"compression,omitempty"`        // COMPRESSION or NO_COMPRESSION
+	Checksum           *bool  `json:"checksum,omitempty"`           // CHECKSUM or NO_CHECKSUM
+	CopyOnly           *bool  `json:"copyOnly,omitempty"`           // COPY_ONLY, which does not change the differential base or the log chain
+	ContinueAfterError *bool  `json:"continueAfterError,omitempty"` // CONTINUE_AFTER_ERROR or STOP_ON_ERROR
+	Init               *bool  `json:"init,omitempty"`               // INIT (overwrites the backup sets of the file) or NOINIT
+	Format             *bool  `json:"format,omitempty"`             // FORMAT (writes a new media header) or NOFORMAT
+	Stats              int    `json:"stats,omitempty"`              // STATS = percentage. Between 1 and 100
+	BufferCount        int    `json:"bufferCount,omitempty"`        // BUFFERCOUNT. Greater than zero
+	MaxTransferSize    int    `json:"maxTransferSize,omitempty"`    // MAXTRANSFERSIZE, in bytes. A multiple of 65536, up to 4194304
+	BlockSize          int    `json:"blockSize,omitempty"`          // BLOCKSIZE, in bytes. A power of 2 between 512 and 65536
+	Description        string `json:"description,omitempty"`        // DESCRIPTION of the backup set. Up to 255 characters
+	Name               string `json:"name,omitempty"`               // NAME of the backup set. Up to 128 characters
+
+	Stripes           int      `json:"stripes,omitempty"`           // How many files the backup is striped into (TO DISK = @Path1, DISK = @Path2, ...). Up to 64
+	StripeDirectories []string `json:"stripeDirectories,omitempty"` // Other directories (volumes) where the stripes are written, in turns with the backup path
+
+	Verify *bool `json:"verify,omitempty"` // Runs RESTORE VERIFYONLY after each successful backup (WITH CHECKSUM, when the backup has checksums)
+
+	Encryption *BackupEncryption `json:"encryption,omitempty"` // ENCRYPTION (ALGORITHM = ..., SERVER CERTIFICATE = ...). See BackupEncryption
+}
+
+// Validate checks the options for the backup type, returning all the problems found at once
+func (bo BackupOptions) Validate(backupType BackupType) error {
+	var errs []error
+
+	if bo.Stats < 0 || bo.Stats > 100 {
+		errs = append(errs, fmt.Errorf("stats: must be between 1 and 100, got %v", bo.Stats))
+	}
+	if bo.BufferCount < 0 {
+		errs = append(errs, fmt.Errorf("bufferCount: must be greater than zero, got %v", bo.BufferCount))
+	}
+	if bo.MaxTransferSize != 0 && (bo.MaxTransferSize < 65536 || bo.MaxTransferSize > 4194304 || bo.MaxTransferSize%65536 != 0) {
+		errs = append(errs, fmt.Errorf("maxTransferSize: must be a multiple of 65536 between 65536 and 4194304, got %v", bo.MaxTransferSize))
+	}
+	if bo.BlockSize != 0 && (bo.BlockSize < 512 || bo.BlockSize > 65536 || bo.BlockSize&(bo.BlockSize-1) != 0) {
+		errs = append(errs, fmt.Errorf("blockSize: must be a power of 2 between 512 and 65536, got %v", bo.BlockSize))
+	}
+	if len([]rune(bo.Description)) > 255 {
+		errs = append(errs, errors.New("description: cannot be longer than 255 characters"))
+	}
+	if len([]rune(bo.Name)) > 128 {
+		errs = append(errs, errors.New("name: cannot be longer than 128 characters"))
+	}
+	if bo.Stripes < 0 || bo.Stripes > MaxStripes {
+		errs = append(errs, fmt.Errorf("stripes: must be between 1 and %v, got %v", MaxStripes, bo.Stripes))
+	}
+	if len(bo.StripeDirectories) > 0 && bo.Stripes < 2 {
+		errs = append(errs, errors.New("stripeDirectories: requires at least 2 stripes"))
+	}
+	if backupType == BackupDifferential && bo.CopyOnly != nil && *bo.CopyOnly {
+		errs = append(errs, errors.New("copyOnly: cannot be used with differential backups"))
+	}
+	if bo.Encryption != nil {
+		if err := bo.Encryption.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("encryption: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// BackupPostRequired is the body of the /api/backup and /api/backup/plan requests. Path is a disk folder or, with the url target, a backup URL (see BackupTarget).
+// ShipTo is the secondary location where the backup files are copied once the backup of each database finishes (see ParseShipDestination)
+type BackupPostRequired struct {
+	Databases     []Database    `json:"databases" binding:"required"`
+	Path          string        `json:"path" binding:"required"`
+	Target        BackupTarget  `json:"target,omitempty"`
+	ShipTo        string        `json:"shipTo,omitempty"`
+	BackupType    string        `json:"backupType,omitempty"`
+	Options       BackupOptions `json:"options,omitempty"`
+	ConcurrentOpe *int          `json:"concurrentOpe,omitempty"`
+}
+
+// BackupPlanDatabase is the planned backup of one database: the BACKUP statement, as it would be executed, the files it would write and the estimated backup size.
+// The file names have the time of the plan; the backup names them with the time it starts
+type BackupPlanDatabase struct {
+	Database       string   `json:"database"`
+	Statement      string   `json:"statement"`
+	Files          []string `json:"files"`
+	EstimatedBytes int64    `json:"estimatedBytes"`
+	Warnings       []string `json:"warnings,omitempty"`
+}
+
+// BackupPlan is the dry-run of a backup, returned by POST /api/backup/plan: the databases which would be backed up, the ones which would be rejected,
+// the estimated size of the backup and the free space of the volumes of the backup path and stripe directories. Nothing is written to build it
+type BackupPlan struct {
+	BackupPath     string               `json:"backupPath"`
+	Target         BackupTarget         `json:"target"`
+	BackupType     BackupType           `json:"backupType"`
+	Options        BackupOptions        `json:"options"`
+	Databases      []BackupPlanDatabase `json:"databases"`
+	Rejected       []SqlErr             `json:"rejected"`
+	EstimatedBytes int64                `json:"estimatedBytes"`
+	Volumes        []VolumeSpace        `json:"volumes"`
+	Warnings       []string             `json:"warnings,omitempty"`
+}
+
+// ToBeVerifiedFile is an existing backup file to be checked with RESTORE VERIFYONLY, sent in the /api/verify request. BackupPaths lists every stripe of a striped backup.
+type ToBeVerifiedFile struct {
+	BackupPath  string   `json:"backupPath" binding:"required"`
+	BackupPaths []string `json:"backupPaths,omitempty"`
+}
+
+// MediaSet returns the files of the backup: every stripe of a striped backup, or only the backup path
+func (tv ToBeVerifiedFile) MediaSet() []string {
+	if len(tv.BackupPaths) > 0 {
+		return tv.BackupPaths
+	}
+
+	return []string{tv.BackupPath}
+}
+
+// VerifyPostRequired is the body of the /api/verify request. Checksum adds WITH CHECKSUM to RESTORE VERIFYONLY and defaults to true
+type VerifyPostRequired struct {
+	Files         []ToBeVerifiedFile `json:"files" binding:"required"`
+	Checksum      *bool              `json:"checksum,omitempty"`
+	ConcurrentOpe *int               `json:"concurrentOpe,omitempty"`
+}
+
+// Matches the stripe suffix of a striped backup file, like name=2024-06-24_14-30-15_1of4.bak
+var stripePattern = regexp.MustCompile(`^(.+)_(\d+)of(\d+)(\.[A-Za-z]+)$`)
+
+// StripeFileName returns the file name of one stripe: the base name (without extension) plus the _{stripe}of{count} suffix
+func StripeFileName(baseName string, stripe int, count int, extension string) string {
+	return fmt.Sprintf("%s_%dof%d%s", baseName, stripe, count, extension)
+}
+
+// ParseStripe reports if the file name is a stripe of a striped backup. It returns the base name (without the suffix and the extension),
+// the stripe number, the stripe count and the extension.
+func ParseStripe(fileName string) (baseName string, stripe int, count int, extension string, ok bool) {
+	match := stripePattern.FindStringSubmatch(fileName)
+	if match == nil {
+		return "", 0, 0, "", false
+	}
+
+	stripe, err := strconv.Atoi(match[2])
+	if err != nil {
+		return "", 0, 0, "", false
+	}
+	count, err = strconv.Atoi(match[3])
+	if err != nil || stripe < 1 || count < 2 || stripe > count {
+		return "", 0, 0, "", false
+	}
+
+	return match[1], stripe, count, match[4], true
+}
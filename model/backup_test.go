@@ -0,0 +1,73 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBackupOptionsValidate(t *testing.T) {
+	copyOnly := true
+
+	tests := []struct {
+		name       string
+		options    BackupOptions
+		backupType BackupType
+		wantErrs   []string // The fields reported, in order. Empty when the options are valid
+	}{
+		{"zero value", BackupOptions{}, BackupFull, nil},
+		{"valid limits", BackupOptions{Stats: 100, BufferCount: 1, MaxTransferSize: 4194304, BlockSize: 65536, Stripes: MaxStripes}, BackupFull, nil},
+		{"stats out of range", BackupOptions{Stats: 101}, BackupFull, []string{"stats:"}},
+		{"negative stats", BackupOptions{Stats: -1}, BackupFull, []string{"stats:"}},
+		{"negative buffer count", BackupOptions{BufferCount: -1}, BackupFull, []string{"bufferCount:"}},
+		{"max transfer size not a multiple of 64 KB", BackupOptions{MaxTransferSize: 65537}, BackupFull, []string{"maxTransferSize:"}},
+		{"max transfer size too large", BackupOptions{MaxTransferSize: 4194304 + 65536}, BackupFull, []string{"maxTransferSize:"}},
+		{"block size not a power of 2", BackupOptions{BlockSize: 1000}, BackupFull, []string{"blockSize:"}},
+		{"block size too small", BackupOptions{BlockSize: 256}, BackupFull, []string{"blockSize:"}},
+		{"long description", BackupOptions{Description: strings.Repeat("d", 256)}, BackupFull, []string{"description:"}},
+		{"description counted in characters", BackupOptions{Description: strings.Repeat("ç", 255)}, BackupFull, nil},
+		{"long name", BackupOptions{Name: strings.Repeat("n", 129)}, BackupFull, []string{"name:"}},
+		{"too many stripes", BackupOptions{Stripes: MaxStripes + 1}, BackupFull, []string{"stripes:"}},
+		{"stripe directories without stripes", BackupOptions{StripeDirectories: []string{"/other"}}, BackupFull, []string{"stripeDirectories:"}},
+		{"stripe directories with stripes", BackupOptions{Stripes: 2, StripeDirectories: []string{"/other"}}, BackupFull, nil},
+		{"copy-only full backup", BackupOptions{CopyOnly: &copyOnly}, BackupFull, nil},
+		{"copy-only differential backup", BackupOptions{CopyOnly: &copyOnly}, BackupDifferential, []string{"copyOnly:"}},
+		{"encryption without encryptor", BackupOptions{Encryption: &BackupEncryption{}}, BackupFull, []string{"encryption: serverCertificate:"}},
+		{"encryption with unknown algorithm", BackupOptions{Encryption: &BackupEncryption{Algorithm: "AES_512", ServerCertificate: "BackupCert"}}, BackupFull, []string{"encryption: algorithm:"}},
+		{"every problem at once", BackupOptions{Stats: 200, BufferCount: -1, BlockSize: 3}, BackupFull, []string{"stats:", "bufferCount:", "blockSize:"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.Validate(tt.backupType)
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() = nil, want %v", tt.wantErrs)
+			}
+
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(tt.wantErrs) {
+				t.Fatalf("Validate() = %q, want %v errors", err, len(tt.wantErrs))
+			}
+			for i, want := range tt.wantErrs {
+				if !strings.HasPrefix(lines[i], want) {
+					t.Errorf("error %v = %q, want prefix %q", i, lines[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestBackupEncryptionDefaultAlgorithm(t *testing.T) {
+	options := BackupOptions{Encryption: &BackupEncryption{ServerCertificate: "BackupCert"}}
+	if err := options.Validate(BackupFull); err != nil {
+		t.Fatal(err)
+	}
+	if options.Encryption.Algorithm != "AES_256" {
+		t.Fatalf("Algorithm = %q, want AES_256", options.Encryption.Algorithm)
+	}
+}
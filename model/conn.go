@@ -11,6 +11,19 @@ type ConnInfo struct {
 	Instance               string `json:"instance"`
 	Encryption             string `json:"encryption"`
 	TrustServerCertificate *bool  `json:"trustServerCertificate"`
+	ConnectionID           string `json:"connectionId"`
+}
+
+// Server returns the SQL Server instance of the connection: host\instance, host,port or only the host
+func (ci ConnInfo) Server() string {
+	switch {
+	case ci.Instance != "":
+		return ci.Host + `\` + ci.Instance
+	case ci.Port != "":
+		return ci.Host + "," + ci.Port
+	}
+
+	return ci.Host
 }
 
 func (ci ConnInfo) LogValue() slog.Value {
@@ -20,5 +33,6 @@ func (ci ConnInfo) LogValue() slog.Value {
 		slog.String("user", ci.User),
 		slog.String("password", "REDACTED"),
 		slog.String("instance", ci.Instance),
+		slog.String("connectionId", ci.ConnectionID),
 	)
 }
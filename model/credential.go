@@ -0,0 +1,85 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CredentialType is the kind of a SQL Server credential used by the backups to URL. Each type sets the IDENTITY and SECRET of CREATE CREDENTIAL
+type CredentialType string
+
+const (
+	CredentialS3         CredentialType = "s3"          // IDENTITY = 'S3 Access Key', SECRET = 'accessKey:secretKey'. Named after the S3 URL, like s3://host/bucket
+	CredentialSAS        CredentialType = "sas"         // IDENTITY = 'SHARED ACCESS SIGNATURE', SECRET = the SAS token. Named after the Azure container URL
+	CredentialStorageKey CredentialType = "storage_key" // IDENTITY = the storage account, SECRET = the storage account key. Used with WITH CREDENTIAL
+)
+
+// Credential is a SQL Server credential (sys.credentials). The secret is never returned by the server
+type Credential struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Identity   string    `json:"identity"`
+	CreatedAt  time.Time `json:"createdAt"`
+	ModifiedAt time.Time `json:"modifiedAt"`
+}
+
+// CredentialPostRequired is the body of the /api/credentials request, which creates the credential or replaces its identity and secret.
+// Identity is the access key (s3) or the storage account (storage_key), and is not used by sas. Secret is the secret key (s3), the SAS token, without the
+// leading "?" (sas), or the storage account key (storage_key)
+type CredentialPostRequired struct {
+	Name     string         `json:"name" binding:"required"`
+	Type     CredentialType `json:"type" binding:"required"`
+	Identity string         `json:"identity,omitempty"`
+	Secret   string         `json:"secret" binding:"required"`
+}
+
+// Validate checks the request, returning all the problems found at once
+func (cr CredentialPostRequired) Validate() error {
+	var errs []error
+
+	if cr.Name == "" || len([]rune(cr.Name)) > 128 {
+		errs = append(errs, errors.New("name: required, up to 128 characters"))
+	}
+	if cr.Secret == "" {
+		errs = append(errs, errors.New("secret: required"))
+	}
+
+	switch cr.Type {
+	case CredentialS3:
+		if !strings.HasPrefix(strings.ToLower(cr.Name), "s3://") {
+			errs = append(errs, errors.New("name: S3 credentials are named after the URL of the backups, like s3://host/bucket"))
+		}
+		if cr.Identity == "" || strings.Contains(cr.Identity, ":") {
+			errs = append(errs, errors.New("identity: the access key is required, and cannot have a colon"))
+		}
+	case CredentialSAS:
+		if !strings.HasPrefix(strings.ToLower(cr.Name), "https://") {
+			errs = append(errs, errors.New("name: SAS credentials are named after the URL of the container, like https://account.blob.core.windows.net/container"))
+		}
+		if strings.HasPrefix(cr.Secret, "?") {
+			errs = append(errs, errors.New("secret: the SAS token cannot start with \"?\""))
+		}
+	case CredentialStorageKey:
+		if cr.Identity == "" {
+			errs = append(errs, errors.New("identity: the storage account is required"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("type: unknown credential type %q. Accepts: s3, sas, storage_key", cr.Type))
+	}
+
+	return errors.Join(errs...)
+}
+
+// CredentialIdentity returns the IDENTITY and SECRET of the CREATE CREDENTIAL statement of the request
+func (cr CredentialPostRequired) CredentialIdentity() (string, string) {
+	switch cr.Type {
+	case CredentialS3:
+		return "S3 Access Key", cr.Identity + ":" + cr.Secret
+	case CredentialSAS:
+		return "SHARED ACCESS SIGNATURE", cr.Secret
+	default:
+		return cr.Identity, cr.Secret
+	}
+}
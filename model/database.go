@@ -1,80 +1,201 @@
 package model
 
+import (
+	"slices"
+	"strings"
+)
+
 // Database is a set of id, name, and the database files of some SQL Server database. Its populated by JSON, via HTTP request. Expects an id, name and files in the request body.
+// The other fields are read from sys.databases by GetDatabases, and are ignored in the requests.
 type Database struct {
-	ID    string         `json:"id,omitempty"`
-	Name  string         `json:"name" binding:"required"`
-	Files []DatabaseFile `json:"files,omitempty"`
+	ID                 string         `json:"id,omitempty"`
+	Name               string         `json:"name" binding:"required"`
+	State              string         `json:"state,omitempty"`              // ONLINE, OFFLINE, RESTORING, RECOVERING, RECOVERY_PENDING, SUSPECT or EMERGENCY
+	RecoveryModel      string         `json:"recoveryModel,omitempty"`      // SIMPLE, FULL or BULK_LOGGED
+	CompatibilityLevel int            `json:"compatibilityLevel,omitempty"` // Like 160 for SQL Server 2022
+	IsReadOnly         bool           `json:"isReadOnly,omitempty"`
+	IsSnapshot         bool           `json:"isSnapshot,omitempty"` // A database snapshot, which cannot be backed up
+	IsSystem           bool           `json:"isSystem,omitempty"`   // master, model, msdb or tempdb
+	SizeBytes          int64          `json:"sizeBytes,omitempty"`  // The size of all the database files
+	Owner              string         `json:"owner,omitempty"`
+	Files              []DatabaseFile `json:"files,omitempty"`
+}
+
+// SystemDatabases lists the system databases of SQL Server
+var SystemDatabases = []string{"master", "model", "msdb", "tempdb"}
+
+// IsSystemDatabase reports if the name is of a system database. The names are compared case-insensitively, like in the default server collations
+func IsSystemDatabase(name string) bool {
+	return slices.ContainsFunc(SystemDatabases, func(systemDatabase string) bool {
+		return strings.EqualFold(systemDatabase, name)
+	})
+}
+
+// DatabaseFilter filters the databases listed by GET /api/databases, read from its query parameters. The fields which are not set do not filter.
+// BackupType keeps only the databases which accept a backup of the type
+type DatabaseFilter struct {
+	Name               string     // Part of the name, case-insensitive
+	State              string     // Case-insensitive
+	RecoveryModel      string     // Case-insensitive
+	CompatibilityLevel int        // The exact level
+	ReadOnly           *bool      // Read-only databases (true) or writable ones (false)
+	System             *bool      // System databases (true) or user ones (false)
+	Snapshot           *bool      // Database snapshots (true) or databases (false)
+	Owner              string     // Case-insensitive
+	MinSizeBytes       int64      // Databases with at least this size
+	MaxSizeBytes       int64      // Databases with at most this size
+	BackupType         BackupType // Databases which accept this backup type
+}
+
+// Match reports if the database passes every filter but BackupType, which depends on the eligibility rules of the backup
+func (df DatabaseFilter) Match(database Database) bool {
+	switch {
+	case df.Name != "" && !strings.Contains(strings.ToLower(database.Name), strings.ToLower(df.Name)):
+		return false
+	case df.State != "" && !strings.EqualFold(database.State, df.State):
+		return false
+	case df.RecoveryModel != "" && !strings.EqualFold(database.RecoveryModel, df.RecoveryModel):
+		return false
+	case df.CompatibilityLevel != 0 && database.CompatibilityLevel != df.CompatibilityLevel:
+		return false
+	case df.ReadOnly != nil && database.IsReadOnly != *df.ReadOnly:
+		return false
+	case df.System != nil && database.IsSystem != *df.System:
+		return false
+	case df.Snapshot != nil && database.IsSnapshot != *df.Snapshot:
+		return false
+	case df.Owner != "" && !strings.EqualFold(database.Owner, df.Owner):
+		return false
+	case df.MinSizeBytes > 0 && database.SizeBytes < df.MinSizeBytes:
+		return false
+	case df.MaxSizeBytes > 0 && database.SizeBytes > df.MaxSizeBytes:
+		return false
+	}
+
+	return true
 }
 
-// DatabaseFile is a set of a LogicalName, PhysicalName and a FileType (data or log). It refers to a SQL Server database file.
+// DatabaseFile is a set of a LogicalName, PhysicalName and a FileType (ROWS, LOG, FILESTREAM or FULLTEXT). It refers to a SQL Server database file.
+// FileId, FileGroupName and Size (in bytes) are set when the file is read from a backup (RESTORE FILELISTONLY).
 type DatabaseFile struct {
-	LogicalName  string `json:"logicalName"`
-	PhysicalName string `json:"physicalName"`
-	FileType     string `json:"fileType"`
+	LogicalName   string `json:"logicalName"`
+	PhysicalName  string `json:"physicalName"`
+	FileType      string `json:"fileType"`
+	FileId        int    `json:"fileId,omitempty"`
+	FileGroupName string `json:"fileGroupName,omitempty"`
+	Size          int64  `json:"size,omitempty"`
 }
 
-// MergedDatabaseFileInfo is a set of DatabaseId, DatabaseName, LogicalName, PhysicalName, and FileType. Typically, when SELECT is executed on repository.GetDatabases(),
+// MergedDatabaseFileInfo is a set of DatabaseId, DatabaseName, LogicalName, PhysicalName, and FileType, plus the database metadata and the file Size in bytes. Typically, when SELECT is executed on repository.GetDatabases(),
 // it returns information about the database and its files. For each file in a database, one row will be returned. This is where MergedDatabaseFileInfo is used.
 type MergedDatabaseFileInfo struct {
-	DatabaseId   string
-	DatabaseName string
-	LogicalName  string
-	PhysicalName string
-	FileType     string
+	DatabaseId         string
+	DatabaseName       string
+	LogicalName        string
+	PhysicalName       string
+	FileType           string
+	Size               int64
+	State              string
+	RecoveryModel      string
+	CompatibilityLevel int
+	IsReadOnly         bool
+	IsSnapshot         bool
+	Owner              string
 }
 
 // BackupDataFile is a set of LogicalName, PhysicalName, FileType, FileGroupName, Size, MaxSize, FileId, CreateLSN, DropLSN, UniqueId, ReadOnlyLSN, ReadWriteLSN, BackupSizeInBytes,
-// SourceBlockSize, FileGroupId, LogGroupGUID, DifferentialBaseLSN, DifferentialBaseGUID, IsReadOnly, IsPresent, TDEThumbprint, SnapshotUrl.
+// SourceBlockSize, FileGroupId, LogGroupGUID, DifferentialBaseLSN, DifferentialBaseGUID, IsReadOnly, IsPresent, TDEThumbprint, SnapshotUrl. TDEThumbprint is hexadecimal, like the thumbprints of /api/backup/encryptors.
 // This is used for allocate RESTORE FILELISTONLY information. More informations about each attribute in https://learn.microsoft.com/en-us/sql/t-sql/statements/restore-statements-filelistonly-transact-sql?view=sql-server-ver16
 type BackupDataFile struct {
-	LogicalName          string
-	PhysicalName         string
-	FileType             string
-	FileGroupName        *string
-	Size                 string
-	MaxSize              string
-	FileId               string
-	CreateLSN            string
-	DropLSN              *string
-	UniqueId             string
-	ReadOnlyLSN          *string
-	ReadWriteLSN         *string
-	BackupSizeInBytes    string
-	SourceBlockSize      string
-	FileGroupId          string
-	LogGroupGUID         *string
-	DifferentialBaseLSN  *string
-	DifferentialBaseGUID *string
-	IsReadOnly           string
-	IsPresent            string
-	TDEThumbprint        *string
-	SnapshotUrl          *string
+	LogicalName          string  `json:"logicalName"`
+	PhysicalName         string  `json:"physicalName"`
+	FileType             string  `json:"fileType"`
+	FileGroupName        *string `json:"fileGroupName,omitempty"`
+	Size                 string  `json:"size"`
+	MaxSize              string  `json:"maxSize"`
+	FileId               string  `json:"fileId"`
+	CreateLSN            string  `json:"createLSN"`
+	DropLSN              *string `json:"dropLSN,omitempty"`
+	UniqueId             string  `json:"uniqueId"`
+	ReadOnlyLSN          *string `json:"readOnlyLSN,omitempty"`
+	ReadWriteLSN         *string `json:"readWriteLSN,omitempty"`
+	BackupSizeInBytes    string  `json:"backupSizeInBytes"`
+	SourceBlockSize      string  `json:"sourceBlockSize"`
+	FileGroupId          string  `json:"fileGroupId"`
+	LogGroupGUID         *string `json:"logGroupGUID,omitempty"`
+	DifferentialBaseLSN  *string `json:"differentialBaseLSN,omitempty"`
+	DifferentialBaseGUID *string `json:"differentialBaseGUID,omitempty"`
+	IsReadOnly           string  `json:"isReadOnly"`
+	IsPresent            string  `json:"isPresent"`
+	TDEThumbprint        *string `json:"tdeThumbprint,omitempty"`
+	SnapshotUrl          *string `json:"snapshotUrl,omitempty"`
 }
 
 // DatabaseFromBackupFile is a set of Name, BackupFilePath, and BackupFileInfo. It is used to return information collected from the RESTORE FILELISTONLY statement,
 // which reads the .bak file (in the BackupFilePath folder) related to the database and its files in an organized and unified manner.
+// BackupFilePaths has every file of the media set, when the backup is striped.
 type DatabaseFromBackupFile struct {
-	Name           string
-	BackupFilePath string
-	BackupFileInfo []BackupDataFile
+	Name            string
+	BackupFilePath  string
+	BackupFilePaths []string
+	BackupFileInfo  []BackupDataFile
 }
 
-// RestoreDb is a set of BackupPath and Database. Its used to return the RESTORE DATABASE completed.
+// RestoreDb is a set of BackupPath, Database, the restore Options and the MOVE of each database file. Its used to return the RESTORE DATABASE completed.
+// When the backup is striped, BackupPaths has every file of the media set.
 type RestoreDb struct {
-	BackupPath string   `json:"backupPath"`
-	Database   Database `json:"database"`
+	BackupPath  string         `json:"backupPath"`
+	BackupPaths []string       `json:"backupPaths,omitempty"`
+	Database    Database       `json:"database"`
+	Options     RestoreOptions `json:"options"`
+	Moves       []FileMove     `json:"moves,omitempty"`
+
+	TDEThumbprint        string `json:"tdeThumbprint,omitempty"`        // The thumbprint of the certificate which encrypts the database with TDE, read with RESTORE FILELISTONLY
+	CreateTDECertificate bool   `json:"createTdeCertificate,omitempty"` // The certificate is not in the server, and is created from Options.TDECertificate before the restore
 }
 
-// BackupFileInfo contains information about a backup file
+// MediaSet returns the files to be read by the RESTORE statement: every stripe of a striped backup, or only the backup path
+func (rd RestoreDb) MediaSet() []string {
+	if len(rd.BackupPaths) > 0 {
+		return rd.BackupPaths
+	}
+
+	return []string{rd.BackupPath}
+}
+
+// BackupFileInfo contains information about a backup file. For a striped backup, FileName is the first stripe,
+// Stripes is the stripe count of the set and StripeFiles are the stripes found in the directory.
 type BackupFileInfo struct {
-	FileName      string `json:"fileName"`
-	DefaultDbName string `json:"defaultDbName"`
+	FileName      string     `json:"fileName"`
+	DefaultDbName string     `json:"defaultDbName"`
+	BackupType    BackupType `json:"backupType"` // By the extension of the file: .bak, .dif or .trn
+	Stripes       int        `json:"stripes,omitempty"`
+	StripeFiles   []string   `json:"stripeFiles,omitempty"`
 }
 
+// ToBeRestoredDb is a database to be restored, sent in the /api/restore request. Name is the restored database name, which may differ from the database in the backup.
+// BackupPaths lists every stripe of a striped backup. If it is empty and BackupPath is a stripe (like name=date_time_1of4.bak), the other stripes are searched in the same directory.
 type ToBeRestoredDb struct {
-	Name       string `json:"name" binding:"required"`
-	BackupPath string `json:"backupPath" binding:"required"`
+	Name        string         `json:"name" binding:"required"`
+	BackupPath  string         `json:"backupPath" binding:"required"`
+	BackupPaths []string       `json:"backupPaths,omitempty"`
+	Options     RestoreOptions `json:"options"`
+}
+
+// MediaSet returns the files of the backup: every stripe of a striped backup, or only the backup path
+func (tr ToBeRestoredDb) MediaSet() []string {
+	if len(tr.BackupPaths) > 0 {
+		return tr.BackupPaths
+	}
+
+	return []string{tr.BackupPath}
+}
+
+// RestoreSystemPostRequired is the body of the /api/restore/system request, which restores one system database (master, model or msdb).
+// ConfirmSystemDatabase must repeat the database name, so a system database is never overwritten by accident
+type RestoreSystemPostRequired struct {
+	Database              ToBeRestoredDb `json:"database" binding:"required"`
+	ConfirmSystemDatabase string         `json:"confirmSystemDatabase"`
 }
 
 type RestorePostRequired struct {
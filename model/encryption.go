@@ -0,0 +1,119 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// EncryptorType is the kind of key of the master database which encrypts a backup
+type EncryptorType string
+
+const (
+	EncryptorCertificate   EncryptorType = "certificate"    // SERVER CERTIFICATE, a certificate of master.sys.certificates
+	EncryptorAsymmetricKey EncryptorType = "asymmetric_key" // SERVER ASYMMETRIC KEY, an asymmetric key of master.sys.asymmetric_keys, kept by an EKM provider
+)
+
+// EncryptionAlgorithms lists the algorithms accepted by the ENCRYPTION option of the BACKUP statement
+var EncryptionAlgorithms = []string{"AES_128", "AES_192", "AES_256", "TRIPLE_DES_3KEY"}
+
+// BackupEncryption is the ENCRYPTION option of the BACKUP statement, sent in the backup options. The backup is encrypted by a certificate or by an asymmetric key
+// of the master database (see /api/backup/encryptors), which is required to restore it on any server. Algorithm defaults to AES_256
+type BackupEncryption struct {
+	Algorithm           string `json:"algorithm,omitempty"`
+	ServerCertificate   string `json:"serverCertificate,omitempty"`
+	ServerAsymmetricKey string `json:"serverAsymmetricKey,omitempty"`
+}
+
+// BackupEncryptor is a certificate or an asymmetric key of the master database which can encrypt the backups, returned by GET /api/backup/encryptors.
+// Thumbprint is the hexadecimal SHA-1 hash which the backups encrypted by it report in RESTORE HEADERONLY (EncryptorThumbprint).
+// PrivateKeyLastBackup is when the private key was last backed up (BACKUP CERTIFICATE ... WITH PRIVATE KEY), only known for certificates.
+// Warnings report the encryptors which cannot encrypt the backups, or whose backups could not be restored if the server is lost
+type BackupEncryptor struct {
+	Name                 string        `json:"name"`
+	Type                 EncryptorType `json:"type"`
+	Thumbprint           string        `json:"thumbprint"`
+	Subject              string        `json:"subject,omitempty"`
+	ExpiryDate           *time.Time    `json:"expiryDate,omitempty"`
+	PrivateKeyEncryption string        `json:"privateKeyEncryption"` // pvt_key_encryption_type_desc, like ENCRYPTED_BY_MASTER_KEY or NO_PRIVATE_KEY
+	PrivateKeyLastBackup *time.Time    `json:"privateKeyLastBackup,omitempty"`
+	Warnings             []string      `json:"warnings,omitempty"`
+}
+
+// Validate checks the encryption option, and sets the default algorithm when it is empty
+func (be *BackupEncryption) Validate() error {
+	var errs []error
+
+	if be.Algorithm == "" {
+		be.Algorithm = "AES_256"
+	}
+	if !slices.Contains(EncryptionAlgorithms, be.Algorithm) {
+		errs = append(errs, fmt.Errorf("algorithm: unknown algorithm %q. Accepts: AES_128, AES_192, AES_256, TRIPLE_DES_3KEY", be.Algorithm))
+	}
+
+	switch {
+	case be.ServerCertificate == "" && be.ServerAsymmetricKey == "":
+		errs = append(errs, errors.New("serverCertificate: required, unless serverAsymmetricKey is set"))
+	case be.ServerCertificate != "" && be.ServerAsymmetricKey != "":
+		errs = append(errs, errors.New("serverAsymmetricKey: cannot be used with serverCertificate"))
+	}
+	if len([]rune(be.ServerCertificate)) > 128 || len([]rune(be.ServerAsymmetricKey)) > 128 {
+		errs = append(errs, errors.New("serverCertificate: the name cannot be longer than 128 characters"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Encryptor returns the type and the name of the key which encrypts the backup
+func (be BackupEncryption) Encryptor() (EncryptorType, string) {
+	if be.ServerAsymmetricKey != "" {
+		return EncryptorAsymmetricKey, be.ServerAsymmetricKey
+	}
+
+	return EncryptorCertificate, be.ServerCertificate
+}
+
+// TDECertificate is the backup of the certificate which encrypts a database with Transparent Data Encryption (TDE), sent in the restore options.
+// When the certificate of a TDE backup is not in the master database of the server, it is created from these files before the restore
+// (CREATE CERTIFICATE ... FROM FILE ... WITH PRIVATE KEY). The files are read by SQL Server, so the paths are the ones of the server. The master database must have a master key
+type TDECertificate struct {
+	Name               string `json:"name"`
+	CertificateFile    string `json:"certificateFile"`
+	PrivateKeyFile     string `json:"privateKeyFile"`
+	PrivateKeyPassword string `json:"privateKeyPassword,omitempty"` // DECRYPTION BY PASSWORD of the private key. It is never returned
+}
+
+// Validate checks the certificate files and the password of the private key, returning all the problems found at once
+func (tc TDECertificate) Validate() error {
+	var errs []error
+
+	if tc.Name == "" {
+		errs = append(errs, errors.New("name: required"))
+	}
+	if len([]rune(tc.Name)) > 128 {
+		errs = append(errs, errors.New("name: cannot be longer than 128 characters"))
+	}
+	if tc.CertificateFile == "" {
+		errs = append(errs, errors.New("certificateFile: required"))
+	}
+	if tc.PrivateKeyFile == "" {
+		errs = append(errs, errors.New("privateKeyFile: required"))
+	}
+	if len([]rune(tc.CertificateFile)) > 260 || len([]rune(tc.PrivateKeyFile)) > 260 {
+		errs = append(errs, errors.New("certificateFile/privateKeyFile: cannot be longer than 260 characters"))
+	}
+	if tc.PrivateKeyPassword == "" {
+		errs = append(errs, errors.New("privateKeyPassword: required to decrypt the private key"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// MarshalJSON leaves the password of the private key out, so it is never returned by the restore plans and jobs
+func (tc TDECertificate) MarshalJSON() ([]byte, error) {
+	type tdeCertificate TDECertificate
+	tc.PrivateKeyPassword = ""
+	return json.Marshal(tdeCertificate(tc))
+}
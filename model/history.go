@@ -0,0 +1,205 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// HistoryDateLayout is the layout of the from and to dates of the history filters, without time zone, since msdb records the server local time. A date only (DateLayout) is accepted too
+const HistoryDateLayout = StopAtLayout
+
+// DateLayout is the layout of a date without time
+const DateLayout = "2006-01-02"
+
+// The default and the maximum page size of the history endpoints
+const (
+	DefaultHistoryPageSize = 50
+	MaxHistoryPageSize     = 500
+)
+
+// BackupHistoryEntry is one backup set recorded by SQL Server in msdb.dbo.backupset, with the devices (files) of its media family, from msdb.dbo.backupmediafamily.
+// The dates are the server local time. The LSNs are numeric(25,0) values, kept as strings
+type BackupHistoryEntry struct {
+	BackupSetID       int64      `json:"backupSetId"`
+	Database          string     `json:"database"`
+	BackupType        BackupType `json:"backupType"`
+	Name              string     `json:"name,omitempty"`
+	ServerName        string     `json:"serverName,omitempty"`
+	UserName          string     `json:"userName,omitempty"`
+	RecoveryModel     string     `json:"recoveryModel,omitempty"`
+	StartDate         time.Time  `json:"startDate"`
+	FinishDate        time.Time  `json:"finishDate"`
+	DurationSeconds   int64      `json:"durationSeconds"`
+	BackupSize        int64      `json:"backupSize"`
+	CompressedSize    int64      `json:"compressedSize"`
+	FirstLSN          string     `json:"firstLSN"`
+	LastLSN           string     `json:"lastLSN"`
+	CheckpointLSN     string     `json:"checkpointLSN"`
+	DatabaseBackupLSN string     `json:"databaseBackupLSN"`
+	IsCopyOnly        bool       `json:"isCopyOnly"`
+	Devices           []string   `json:"devices"`
+}
+
+// LastBackups are the latest full, differential and log backups of a database, recorded in msdb. A nil backup means the database has none of that type
+type LastBackups struct {
+	Database     string              `json:"database"`
+	Full         *BackupHistoryEntry `json:"full,omitempty"`
+	Differential *BackupHistoryEntry `json:"differential,omitempty"`
+	Log          *BackupHistoryEntry `json:"log,omitempty"`
+}
+
+// HistoryFilter filters the backup and restore history endpoints: Database is an exact name, From and To limit the date (inclusive) and Page starts at 1.
+// BackupType is only used by the backup history, and UserName by the restore history
+type HistoryFilter struct {
+	Database   string
+	BackupType BackupType
+	UserName   string
+	From       *time.Time
+	To         *time.Time
+	Page       int
+	PageSize   int
+}
+
+// BackupHistory is the response of GET /api/history/backups: one page of backup sets, from the newest to the oldest, the total of backup sets of the filter
+// and the last backups of each database of the filter. ServerTime is the current server local time, to compare with the backup dates
+type BackupHistory struct {
+	Backups     []BackupHistoryEntry `json:"backups"`
+	Total       int64                `json:"total"`
+	Page        int                  `json:"page"`
+	PageSize    int                  `json:"pageSize"`
+	LastBackups []LastBackups        `json:"lastBackups"`
+	ServerTime  time.Time            `json:"serverTime"`
+}
+
+// RestoreHistoryEntry is one restore recorded by SQL Server in msdb.dbo.restorehistory: who restored the database, when, with which options (REPLACE, RECOVERY, STOPAT)
+// and the files it was restored to (msdb.dbo.restorefile). Backup is the restored backup set, from msdb.dbo.backupset, with its source database and devices.
+// RestoreType is database, differential, log, file, filegroup, verifyonly or revert
+type RestoreHistoryEntry struct {
+	RestoreHistoryID int64               `json:"restoreHistoryId"`
+	Database         string              `json:"database"`
+	RestoreDate      time.Time           `json:"restoreDate"`
+	UserName         string              `json:"userName"`
+	RestoreType      string              `json:"restoreType"`
+	Replace          bool                `json:"replace"`
+	Recovery         bool                `json:"recovery"`
+	Restart          bool                `json:"restart"`
+	StopAt           *time.Time          `json:"stopAt,omitempty"`
+	StopAtMarkName   string              `json:"stopAtMarkName,omitempty"`
+	StopBefore       bool                `json:"stopBefore,omitempty"`
+	Files            []string            `json:"files"`
+	Backup           *BackupHistoryEntry `json:"backup,omitempty"`
+}
+
+// RestoreHistory is the response of GET /api/history/restores: one page of restores, from the newest to the oldest, and the total of restores of the filter.
+// ServerTime is the current server local time, to compare with the restore dates
+type RestoreHistory struct {
+	Restores   []RestoreHistoryEntry `json:"restores"`
+	Total      int64                 `json:"total"`
+	Page       int                   `json:"page"`
+	PageSize   int                   `json:"pageSize"`
+	ServerTime time.Time             `json:"serverTime"`
+}
+
+// RestoreTypeFromHistory converts the type code of msdb.dbo.restorehistory to the restore type
+func RestoreTypeFromHistory(code string) string {
+	switch code {
+	case "D":
+		return "database"
+	case "I":
+		return "differential"
+	case "L":
+		return "log"
+	case "F":
+		return "file"
+	case "G":
+		return "filegroup"
+	case "V":
+		return "verifyonly"
+	case "R":
+		return "revert"
+	}
+
+	return code
+}
+
+// HistoryTypeCode returns the type code of msdb.dbo.backupset of the backup type: D (database), I (differential) or L (log)
+func (bt BackupType) HistoryTypeCode() string {
+	switch bt {
+	case BackupDifferential:
+		return "I"
+	case BackupLog:
+		return "L"
+	}
+
+	return "D"
+}
+
+// BackupTypeFromHistory converts the type code of msdb.dbo.backupset to a BackupType. File, filegroup and partial backups return an empty BackupType
+func BackupTypeFromHistory(code string) BackupType {
+	switch code {
+	case "D":
+		return BackupFull
+	case "I":
+		return BackupDifferential
+	case "L":
+		return BackupLog
+	}
+
+	return ""
+}
+
+// ParseHistoryDate parses a date of the history filters, like 2025-07-18 or 2025-07-18T14:30:00. A date only is the start of the day or, when end is true, its last second
+func ParseHistoryDate(value string, end bool) (*time.Time, error) {
+	return parseDate(value, end, time.UTC)
+}
+
+// ParseOperationDate parses a date of the operation history filter, like ParseHistoryDate, in the local time of the application, which records the operations
+func ParseOperationDate(value string, end bool) (*time.Time, error) {
+	return parseDate(value, end, time.Local)
+}
+
+func parseDate(value string, end bool, location *time.Location) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.ParseInLocation(HistoryDateLayout, value, location)
+	if err == nil {
+		return &parsed, nil
+	}
+
+	parsed, err = time.ParseInLocation(DateLayout, value, location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q. Use a date like 2025-07-18 or 2025-07-18T14:30:00", value)
+	}
+	if end {
+		parsed = parsed.Add(24*time.Hour - time.Second)
+	}
+
+	return &parsed, nil
+}
+
+// Validate checks the filter and sets the default page and page size
+func (hf *HistoryFilter) Validate() error {
+	var errs []error
+
+	if hf.Page == 0 {
+		hf.Page = 1
+	}
+	if hf.PageSize == 0 {
+		hf.PageSize = DefaultHistoryPageSize
+	}
+
+	if hf.Page < 1 {
+		errs = append(errs, errors.New("page: must be at least 1"))
+	}
+	if hf.PageSize < 1 || hf.PageSize > MaxHistoryPageSize {
+		errs = append(errs, fmt.Errorf("pageSize: must be between 1 and %v", MaxHistoryPageSize))
+	}
+	if hf.From != nil && hf.To != nil && hf.From.After(*hf.To) {
+		errs = append(errs, errors.New("from: cannot be after to"))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+// BackupInspectPostRequired is the body of the /api/backup-files/inspect request. BackupPaths lists every stripe of a striped backup.
+// If it is empty and BackupPath is a stripe (like name=date_time_1of4.bak), the other stripes are searched in the same directory, like in the restore
+type BackupInspectPostRequired struct {
+	BackupPath  string   `json:"backupPath" binding:"required"`
+	BackupPaths []string `json:"backupPaths,omitempty"`
+}
+
+// MediaSet returns the files of the backup: every stripe of a striped backup, or only the backup path
+func (bi BackupInspectPostRequired) MediaSet() []string {
+	if len(bi.BackupPaths) > 0 {
+		return bi.BackupPaths
+	}
+
+	return []string{bi.BackupPath}
+}
+
+// BackupLabel is the media of a backup, read with RESTORE LABELONLY. FamilyCount is the number of stripes of the media set, and FamilySequenceNumber the stripe read.
+// More informations about each attribute in https://learn.microsoft.com/en-us/sql/t-sql/statements/restore-statements-labelonly-transact-sql
+type BackupLabel struct {
+	MediaName            string    `json:"mediaName,omitempty"`
+	MediaSetID           string    `json:"mediaSetId"`
+	FamilyCount          int       `json:"familyCount"`
+	FamilySequenceNumber int       `json:"familySequenceNumber"`
+	MediaFamilyID        string    `json:"mediaFamilyId"`
+	MediaDate            time.Time `json:"mediaDate"`
+	SoftwareName         string    `json:"softwareName,omitempty"`
+	MirrorCount          int       `json:"mirrorCount"`
+	IsCompressed         bool      `json:"isCompressed"`
+}
+
+// BackupInspection is what a backup file has, returned by POST /api/backup-files/inspect: its media (RESTORE LABELONLY), its backup sets (RESTORE HEADERONLY)
+// and the database files of the first backup set (RESTORE FILELISTONLY), which is the one restored by /api/restore.
+// DatabaseName is the name of the database in the first backup set, which the restore defaults to, instead of the file name.
+// Warnings report the parts of the backup which could not be read, like the files of an encrypted backup whose encryptor is not in the server
+type BackupInspection struct {
+	BackupPath   string           `json:"backupPath"`
+	BackupPaths  []string         `json:"backupPaths,omitempty"`
+	DatabaseName string           `json:"databaseName"`
+	Label        *BackupLabel     `json:"label,omitempty"`
+	Headers      []BackupHeader   `json:"headers"`
+	Files        []BackupDataFile `json:"files"`
+	Warnings     []string         `json:"warnings,omitempty"`
+}
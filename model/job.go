@@ -0,0 +1,90 @@
+package model
+
+import "time"
+
+// JobState is the state of a job, or of one database inside a job
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	JobPartial   JobState = "partial" // Only for jobs: some databases succeeded and others failed
+	JobCancelled JobState = "cancelled"
+)
+
+// JobType is the operation executed by a job
+type JobType string
+
+const (
+	JobBackup  JobType = "backup"
+	JobRestore JobType = "restore"
+	JobVerify  JobType = "verify" // RESTORE VERIFYONLY of existing backup files
+)
+
+// Job is a backup or restore operation executed asynchronously by the job engine. It is returned by GET /api/jobs/{id}.
+// Server is the SQL Server instance of the connection, and Options the options of the request. ScheduleID is set for the jobs started by a backup schedule.
+// Warnings report the problems which do not fail the job, like a backup file which could not be shipped.
+// Jobs are kept in the operation history (GET /api/operations/{id}) after they finish.
+type Job struct {
+	ID          string        `json:"id"`
+	Type        JobType       `json:"type"`
+	State       JobState      `json:"state"`
+	CreatedBy   string        `json:"createdBy,omitempty"`
+	SessionID   string        `json:"-"`
+	Server      string        `json:"server,omitempty"`
+	ScheduleID  string        `json:"scheduleId,omitempty"`
+	Path        string        `json:"path,omitempty"`
+	Options     any           `json:"options,omitempty"`
+	CreatedAt   time.Time     `json:"createdAt"`
+	StartedAt   *time.Time    `json:"startedAt,omitempty"`
+	FinishedAt  *time.Time    `json:"finishedAt,omitempty"`
+	TotalTime   string        `json:"totalTime,omitempty"`
+	CancelledBy string        `json:"cancelledBy,omitempty"`
+	CancelledAt *time.Time    `json:"cancelledAt,omitempty"`
+	Databases   []JobDatabase `json:"databases"`
+	Errors      []SqlErr      `json:"errors,omitempty"`
+	Warnings    []string      `json:"warnings,omitempty"`
+}
+
+// JobDatabase is the state of one database inside a job. While it runs, SessionID is the SQL Server session (SPID) executing the statement,
+// and PercentComplete/EstimatedRemaining are sampled from sys.dm_exec_requests.
+// Files are the backup files written (backup) or read (restore), one per stripe. When a running backup is cancelled, PartialFiles reports the files which may have been left behind.
+// When the backup is verified, Verified reports the RESTORE VERIFYONLY result, separately from the backup state, and VerifyError its error.
+// When the backup is shipped, Shipped lists the copies of the files in the secondary location, and ShipError the error of the shipping, which does not fail the backup.
+// The files are shipped after the database finished: Shipping is set while they are queued or being shipped.
+type JobDatabase struct {
+	Name               string        `json:"name"`
+	State              JobState      `json:"state"`
+	SessionID          int           `json:"sessionId,omitempty"`
+	Files              []string      `json:"files,omitempty"`
+	PartialFiles       []string      `json:"partialFiles,omitempty"`
+	Verified           *bool         `json:"verified,omitempty"`
+	VerifyError        string        `json:"verifyError,omitempty"`
+	Shipped            []ShippedFile `json:"shipped,omitempty"`
+	ShipError          string        `json:"shipError,omitempty"`
+	Shipping           bool          `json:"shipping,omitempty"`
+	CancelledBy        string        `json:"cancelledBy,omitempty"`
+	PercentComplete    float64       `json:"percentComplete"`
+	EstimatedRemaining string        `json:"estimatedRemaining,omitempty"`
+	StartedAt          *time.Time    `json:"startedAt,omitempty"`
+	FinishedAt         *time.Time    `json:"finishedAt,omitempty"`
+	Duration           string        `json:"duration,omitempty"`
+	Error              string        `json:"error,omitempty"`
+	Reason             RejectReason  `json:"reason,omitempty"` // Why the database was rejected before the operation started
+}
+
+// OperationProgress is the progress of a BACKUP or RESTORE command running in a SQL Server session, read from sys.dm_exec_requests.
+// EstimatedCompletionTime is in milliseconds.
+type OperationProgress struct {
+	SessionID               int
+	Command                 string
+	PercentComplete         float64
+	EstimatedCompletionTime int64
+}
+
+// Finished reports if the job reached a final state
+func (j Job) Finished() bool {
+	return j.State == JobSucceeded || j.State == JobFailed || j.State == JobPartial || j.State == JobCancelled
+}
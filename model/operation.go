@@ -0,0 +1,95 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrOperationInterrupted is the error of the databases of an operation which was still running when the application stopped
+var ErrOperationInterrupted = errors.New("The application stopped before the operation finished")
+
+// OperationFilter filters GET /api/operations. Database matches any database of the operation, CreatedBy and Server are case-insensitive,
+// ScheduleID selects the backups started by a schedule, From and To limit the creation date (inclusive) and Page starts at 1
+type OperationFilter struct {
+	Type       JobType
+	State      JobState
+	Database   string
+	CreatedBy  string
+	Server     string
+	ScheduleID string
+	From       *time.Time
+	To         *time.Time
+	Page       int
+	PageSize   int
+}
+
+// Operations is the response of GET /api/operations: one page of the operation history, from the newest to the oldest, and the total of operations of the filter
+type Operations struct {
+	Operations []Job `json:"operations"`
+	Total      int   `json:"total"`
+	Page       int   `json:"page"`
+	PageSize   int   `json:"pageSize"`
+}
+
+// Validate checks the filter and sets the default page and page size
+func (of *OperationFilter) Validate() error {
+	var errs []error
+
+	if of.Page == 0 {
+		of.Page = 1
+	}
+	if of.PageSize == 0 {
+		of.PageSize = DefaultHistoryPageSize
+	}
+
+	if of.Type != "" && !slices.Contains([]JobType{JobBackup, JobRestore, JobVerify}, of.Type) {
+		errs = append(errs, fmt.Errorf("type: unknown type %q. Accepts: backup, restore, verify", of.Type))
+	}
+	if of.State != "" && !slices.Contains([]JobState{JobQueued, JobRunning, JobSucceeded, JobFailed, JobPartial, JobCancelled}, of.State) {
+		errs = append(errs, fmt.Errorf("state: unknown state %q. Accepts: queued, running, succeeded, failed, partial, cancelled", of.State))
+	}
+	if of.Page < 1 {
+		errs = append(errs, errors.New("page: must be at least 1"))
+	}
+	if of.PageSize < 1 || of.PageSize > MaxHistoryPageSize {
+		errs = append(errs, fmt.Errorf("pageSize: must be between 1 and %v", MaxHistoryPageSize))
+	}
+	if of.From != nil && of.To != nil && of.From.After(*of.To) {
+		errs = append(errs, errors.New("from: cannot be after to"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Match reports if the operation passes every criterion of the filter
+func (of OperationFilter) Match(job Job) bool {
+	if of.Type != "" && job.Type != of.Type {
+		return false
+	}
+	if of.State != "" && job.State != of.State {
+		return false
+	}
+	if of.CreatedBy != "" && !strings.EqualFold(job.CreatedBy, of.CreatedBy) {
+		return false
+	}
+	if of.Server != "" && !strings.EqualFold(job.Server, of.Server) {
+		return false
+	}
+	if of.ScheduleID != "" && job.ScheduleID != of.ScheduleID {
+		return false
+	}
+	if of.From != nil && job.CreatedAt.Before(*of.From) {
+		return false
+	}
+	if of.To != nil && job.CreatedAt.After(*of.To) {
+		return false
+	}
+	if of.Database != "" && !slices.ContainsFunc(job.Databases, func(database JobDatabase) bool { return database.Name == of.Database }) {
+		return false
+	}
+
+	return true
+}
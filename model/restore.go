@@ -0,0 +1,264 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+)
+
+// StopAtLayout is the layout of the STOPAT time of a point-in-time restore. It has no time zone, since SQL Server reads it (and writes the backup dates) in the server local time
+const StopAtLayout = "2006-01-02T15:04:05"
+
+// RecoveryMode is how a RESTORE statement leaves the database
+type RecoveryMode string
+
+const (
+	RestoreRecovery   RecoveryMode = "recovery"   // WITH RECOVERY: the database is online
+	RestoreNoRecovery RecoveryMode = "norecovery" // WITH NORECOVERY: the database is left RESTORING, so more backups (logs) can be applied
+	RestoreStandby    RecoveryMode = "standby"    // WITH STANDBY: the database is read-only between log restores. Requires an undo file
+)
+
+// RecoveryModes lists the accepted recovery modes
+var RecoveryModes = []RecoveryMode{RestoreRecovery, RestoreNoRecovery, RestoreStandby}
+
+// RestoreOptions are the WITH options of the RESTORE DATABASE statement of one database, sent in the /api/restore request. The zero value restores WITH RECOVERY.
+// The standby file is passed to the statement as a parameter. Replace requires ConfirmReplace to be the name of the restored database, so an existing database is never overwritten by accident.
+type RestoreOptions struct {
+	Replace         bool         `json:"replace,omitempty"`         // REPLACE: overwrites an existing database
+	ConfirmReplace  string       `json:"confirmReplace,omitempty"`  // The name of the restored database, repeated to confirm REPLACE
+	Recovery        RecoveryMode `json:"recovery,omitempty"`        // RECOVERY (default), NORECOVERY or STANDBY
+	StandbyFile     string       `json:"standbyFile,omitempty"`     // The undo file of STANDBY = @StandbyFile
+	KeepReplication bool         `json:"keepReplication,omitempty"` // KEEP_REPLICATION. Cannot be used with NORECOVERY
+	KeepCDC         bool         `json:"keepCdc,omitempty"`         // KEEP_CDC. Cannot be used with NORECOVERY
+	EnableBroker    bool         `json:"enableBroker,omitempty"`    // ENABLE_BROKER: keeps the Service Broker identifier and enables message delivery
+	NewBroker       bool         `json:"newBroker,omitempty"`       // NEW_BROKER: assigns a new Service Broker identifier
+	RestrictedUser  bool         `json:"restrictedUser,omitempty"`  // RESTRICTED_USER: only db_owner, dbcreator and sysadmin members can access the database
+	Checksum        *bool        `json:"checksum,omitempty"`        // CHECKSUM or NO_CHECKSUM. Not set, SQL Server verifies the checksums only if the backup has them
+
+	TDECertificate *TDECertificate `json:"tdeCertificate,omitempty"` // Creates the TDE certificate of the backup from its files, when it is not in the server
+
+	Move map[string]string `json:"move,omitempty"` // Overrides the MOVE target of the database files, by their logical name. FILESTREAM targets are directories
+}
+
+// FileMove is the MOVE of one database file in a RESTORE DATABASE statement: from its physical name in the backup to the target path.
+// Override reports if the target was set by RestoreOptions.Move
+type FileMove struct {
+	LogicalName   string `json:"logicalName"`
+	FileType      string `json:"fileType"`
+	FileGroupName string `json:"fileGroupName,omitempty"`
+	From          string `json:"from"`
+	To            string `json:"to"`
+	Size          int64  `json:"size,omitempty"` // The size of the file in bytes, read with RESTORE FILELISTONLY
+	Override      bool   `json:"override,omitempty"`
+}
+
+// VolumeSpace is a disk volume of the server, read from sys.dm_os_volume_stats. In a restore plan, RequiredBytes is the size of the files restored to the volume
+type VolumeSpace struct {
+	MountPoint     string `json:"mountPoint"`
+	TotalBytes     int64  `json:"totalBytes"`
+	AvailableBytes int64  `json:"availableBytes"`
+	RequiredBytes  int64  `json:"requiredBytes,omitempty"`
+}
+
+// RestorePlan is the dry-run of the restore of one database, returned by POST /api/restore/plan: the RESTORE DATABASE statement, as it would be executed, the file moves,
+// the default data and log paths of the server, if the database already exists and the disk space required by its files against the free space of their volumes.
+// Nothing is executed to build it, other than RESTORE FILELISTONLY and the queries of the server paths, databases and volumes
+type RestorePlan struct {
+	Database      string         `json:"database"`
+	BackupPaths   []string       `json:"backupPaths"`
+	Options       RestoreOptions `json:"options"`
+	Statement     string         `json:"statement"`
+	Moves         []FileMove     `json:"moves"`
+	DataPath      string         `json:"dataPath"`
+	LogPath       string         `json:"logPath"`
+	Exists        bool           `json:"exists"`
+	RequiredBytes int64          `json:"requiredBytes"`
+	Volumes       []VolumeSpace  `json:"volumes"`
+	Warnings      []string       `json:"warnings,omitempty"`
+}
+
+// Mode returns the recovery mode, which is RECOVERY when it is not set
+func (ro RestoreOptions) Mode() RecoveryMode {
+	if ro.Recovery == "" {
+		return RestoreRecovery
+	}
+
+	return ro.Recovery
+}
+
+// Validate checks the options of the restore of the database, returning all the problems found at once
+func (ro RestoreOptions) Validate(database string) error {
+	var errs []error
+
+	mode := ro.Mode()
+	if !slices.Contains(RecoveryModes, mode) {
+		errs = append(errs, fmt.Errorf("recovery: unknown mode %q. Accepts: recovery, norecovery, standby", ro.Recovery))
+	}
+	if mode == RestoreStandby && ro.StandbyFile == "" {
+		errs = append(errs, errors.New("standbyFile: required by the standby mode"))
+	}
+	if mode != RestoreStandby && ro.StandbyFile != "" {
+		errs = append(errs, errors.New("standbyFile: can only be used with the standby mode"))
+	}
+	if len([]rune(ro.StandbyFile)) > 260 {
+		errs = append(errs, errors.New("standbyFile: cannot be longer than 260 characters"))
+	}
+	if ro.Replace && ro.ConfirmReplace != database {
+		errs = append(errs, fmt.Errorf("confirmReplace: must be %q to overwrite the existing database with REPLACE", database))
+	}
+	if mode == RestoreNoRecovery && ro.KeepReplication {
+		errs = append(errs, errors.New("keepReplication: cannot be used with norecovery"))
+	}
+	if mode == RestoreNoRecovery && ro.KeepCDC {
+		errs = append(errs, errors.New("keepCdc: cannot be used with norecovery"))
+	}
+	if ro.EnableBroker && ro.NewBroker {
+		errs = append(errs, errors.New("enableBroker/newBroker: only one of them can be set"))
+	}
+	for logicalName, target := range ro.Move {
+		if target == "" {
+			errs = append(errs, fmt.Errorf("move: the target of %q cannot be empty", logicalName))
+		}
+	}
+	if ro.TDECertificate != nil {
+		if err := ro.TDECertificate.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("tdeCertificate: %w", err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%v: %w", database, errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// BackupHeader is one backup set of a backup file, read with RESTORE HEADERONLY. BackupPaths lists every stripe of a striped backup.
+// The LSNs are numeric(25,0) values, kept as strings and compared with CompareLSN. Encrypted backups report the thumbprint and the type of their encryptor,
+// which must be in the master database of the server to restore them.
+// More informations about each attribute in https://learn.microsoft.com/en-us/sql/t-sql/statements/restore-statements-headeronly-transact-sql?view=sql-server-ver16
+type BackupHeader struct {
+	BackupPath          string    `json:"backupPath"`
+	BackupPaths         []string  `json:"backupPaths,omitempty"`
+	Position            int       `json:"position"`
+	BackupName          string    `json:"backupName,omitempty"`
+	BackupDescription   string    `json:"backupDescription,omitempty"`
+	BackupType          int       `json:"backupType"`               // 1: database, 2: transaction log, 5: differential database. Other types are file/filegroup backups
+	BackupTypeName      string    `json:"backupTypeName,omitempty"` // BackupTypeDescription, like Database, Transaction Log or Database Differential
+	DatabaseName        string    `json:"databaseName"`
+	ServerName          string    `json:"serverName,omitempty"`
+	SoftwareVersion     string    `json:"softwareVersion,omitempty"` // The SQL Server version which wrote the backup, like 16.0.4135
+	CompatibilityLevel  int       `json:"compatibilityLevel,omitempty"`
+	Collation           string    `json:"collation,omitempty"`
+	RecoveryModel       string    `json:"recoveryModel,omitempty"`
+	FirstLSN            string    `json:"firstLSN"`
+	LastLSN             string    `json:"lastLSN"`
+	CheckpointLSN       string    `json:"checkpointLSN"`
+	DatabaseBackupLSN   string    `json:"databaseBackupLSN"`
+	DifferentialBaseLSN string    `json:"differentialBaseLSN,omitempty"`
+	BackupStartDate     time.Time `json:"backupStartDate"`
+	BackupFinishDate    time.Time `json:"backupFinishDate"`
+	IsCopyOnly          bool      `json:"isCopyOnly"`
+	HasBackupChecksums  bool      `json:"hasBackupChecksums"`
+	BackupSize          int64     `json:"backupSize"`     // Uncompressed size, in bytes
+	CompressedSize      int64     `json:"compressedSize"` // CompressedBackupSize, in bytes. Equal to BackupSize when the backup is not compressed
+	IsDamaged           bool      `json:"isDamaged,omitempty"`
+	Encrypted           bool      `json:"encrypted"`
+	KeyAlgorithm        string    `json:"keyAlgorithm,omitempty"`
+	EncryptorThumbprint string    `json:"encryptorThumbprint,omitempty"` // Hexadecimal, like the thumbprint of BackupEncryptor
+	EncryptorType       string    `json:"encryptorType,omitempty"`       // CERTIFICATE or ASYMMETRIC KEY
+}
+
+// Kind returns the BackupType of the backup set, or an empty BackupType for file/filegroup backups, which are not part of a restore chain
+func (bh BackupHeader) Kind() BackupType {
+	switch bh.BackupType {
+	case 1:
+		return BackupFull
+	case 5:
+		return BackupDifferential
+	case 2:
+		return BackupLog
+	}
+
+	return ""
+}
+
+// MediaSet returns the files of the backup: every stripe of a striped backup, or only the backup path
+func (bh BackupHeader) MediaSet() []string {
+	if len(bh.BackupPaths) > 0 {
+		return bh.BackupPaths
+	}
+
+	return []string{bh.BackupPath}
+}
+
+// CompareLSN compares two LSNs, returning -1, 0 or +1. An empty LSN is taken as zero
+func CompareLSN(a string, b string) int {
+	return lsnValue(a).Cmp(lsnValue(b))
+}
+
+func lsnValue(lsn string) *big.Int {
+	value, ok := new(big.Int).SetString(lsn, 10)
+	if !ok {
+		return new(big.Int)
+	}
+
+	return value
+}
+
+// RestoreChainStep is one RESTORE statement of a restore chain. Every step but the last one is restored WITH NORECOVERY
+type RestoreChainStep struct {
+	BackupHeader
+	Recovery  bool   `json:"recovery"`
+	Statement string `json:"statement"`
+}
+
+// RestoreChain is the planned point-in-time restore of a database: the full backup, the latest differential based on it and the log backups up to StopAt,
+// ordered by LSN. Files are the database files of the full backup, and Moves their targets in the default data and log paths of the server.
+// Replace overwrites the existing target database, with the REPLACE option of the full backup step. When the database is encrypted with TDE by a certificate
+// which is not in the server, it is created from TDECertificate before the first step (CreateTDECertificate), like RestoreDb
+type RestoreChain struct {
+	Database   string             `json:"database"`
+	TargetName string             `json:"targetName"`
+	StopAt     string             `json:"stopAt,omitempty"`
+	Replace    bool               `json:"replace,omitempty"`
+	Files      []DatabaseFile     `json:"files"`
+	Moves      []FileMove         `json:"moves"`
+	Steps      []RestoreChainStep `json:"steps"`
+	Warnings   []string           `json:"warnings,omitempty"`
+
+	TDEThumbprint        string          `json:"tdeThumbprint,omitempty"`
+	TDECertificate       *TDECertificate `json:"tdeCertificate,omitempty"`
+	CreateTDECertificate bool            `json:"createTdeCertificate,omitempty"`
+}
+
+// RestoreChainPostRequired is the body of the /api/restore/chain and /api/restore/chain/plan requests.
+// Database is the database name in the backups, read from the files of Path; TargetName is the restored database name (defaults to Database).
+// StopAt is the point in time to be restored, in the server local time (StopAtLayout). If it is empty, every log backup of the chain is restored.
+// An existing target database is only overwritten with Replace, confirmed by its name in ConfirmReplace, and TDECertificate creates the TDE certificate
+// of the backups when it is not in the server, like RestoreOptions
+type RestoreChainPostRequired struct {
+	Database       string          `json:"database" binding:"required"`
+	TargetName     string          `json:"targetName,omitempty"`
+	Path           string          `json:"path" binding:"required"`
+	StopAt         string          `json:"stopAt,omitempty"`
+	Replace        bool            `json:"replace,omitempty"`
+	ConfirmReplace string          `json:"confirmReplace,omitempty"`
+	TDECertificate *TDECertificate `json:"tdeCertificate,omitempty"`
+}
+
+// ParseStopAt parses the STOPAT time, like 2025-07-18T14:30:00. An empty value returns nil
+func ParseStopAt(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(StopAtLayout, value)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid stopAt %q. Use the server local time, like 2025-07-18T14:30:00", value)
+	}
+
+	return &parsed, nil
+}
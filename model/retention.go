@@ -0,0 +1,111 @@
+package model
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BackupFileTimeLayout is the layout of the time in the backup file names, like name=2024-06-24_14-30-15.bak. It is the application local time
+const BackupFileTimeLayout = "2006-01-02_15-04-05"
+
+// RetentionPolicy selects the backup sets which are kept in a backup folder. The rules are applied to each database and backup type separately,
+// and a backup set kept by any rule is kept. Besides the rules, the newest full backup of each database is never deleted, nor the full backup
+// (and the newest differential backup) a kept differential or log backup was taken on
+type RetentionPolicy struct {
+	KeepLast    int `json:"keepLast,omitempty"`    // The N newest backup sets
+	KeepDays    int `json:"keepDays,omitempty"`    // The backup sets of the last N days
+	KeepDaily   int `json:"keepDaily,omitempty"`   // GFS: the newest backup set of each of the last N days which have backups
+	KeepWeekly  int `json:"keepWeekly,omitempty"`  // GFS: the newest backup set of each of the last N weeks (ISO weeks) which have backups
+	KeepMonthly int `json:"keepMonthly,omitempty"` // GFS: the newest backup set of each of the last N months which have backups
+}
+
+// Why a backup set is kept by the retention
+const (
+	RetainLast       = "keepLast"
+	RetainDays       = "keepDays"
+	RetainDaily      = "keepDaily"
+	RetainWeekly     = "keepWeekly"
+	RetainMonthly    = "keepMonthly"
+	RetainNewestFull = "newestFull" // The newest full backup of the database
+	RetainChain      = "chain"      // The base of a kept differential or log backup, or a log backup between that base and it
+)
+
+// RetentionPostRequired is the body of the /api/retention request. Databases limits the retention to the backups of those databases.
+// With DryRun, the backup sets which would be deleted are listed, but nothing is deleted
+type RetentionPostRequired struct {
+	Path      string          `json:"path" binding:"required"`
+	Databases []string        `json:"databases,omitempty"`
+	Policy    RetentionPolicy `json:"policy" binding:"required"`
+	DryRun    bool            `json:"dryRun,omitempty"`
+}
+
+// RetentionBackupSet is one backup set of the backup folder, found by the name of its files (see ParseBackupFileName): a single file,
+// or the stripes of a striped backup which are in the folder. Reasons are the rules which keep it, and Error the error of its deletion.
+// CopyOnly is only set for the full backups whose COPY_ONLY option could be read from the server; it is nil when it is not known
+type RetentionBackupSet struct {
+	Database   string     `json:"database"`
+	BackupType BackupType `json:"backupType"`
+	BackupTime time.Time  `json:"backupTime"`
+	Files      []string   `json:"files"`
+	Size       int64      `json:"size"`
+	CopyOnly   *bool      `json:"copyOnly,omitempty"`
+	Reasons    []string   `json:"reasons,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Retention is the result of the retention of a backup folder: the backup sets kept, the ones deleted (or which would be deleted, in a dry run),
+// the ones which could not be deleted, and the files ignored, since their names do not follow the naming of the backups
+type Retention struct {
+	Path       string               `json:"path"`
+	DryRun     bool                 `json:"dryRun"`
+	Policy     RetentionPolicy      `json:"policy"`
+	Kept       []RetentionBackupSet `json:"kept"`
+	Deleted    []RetentionBackupSet `json:"deleted"`
+	Failed     []RetentionBackupSet `json:"failed,omitempty"`
+	Ignored    []string             `json:"ignored,omitempty"`
+	FreedBytes int64                `json:"freedBytes"`
+}
+
+// Validate checks the policy, which needs at least one rule
+func (rp RetentionPolicy) Validate() error {
+	var errs []error
+
+	if rp.KeepLast < 0 || rp.KeepDays < 0 || rp.KeepDaily < 0 || rp.KeepWeekly < 0 || rp.KeepMonthly < 0 {
+		errs = append(errs, errors.New("policy: the rules cannot be negative"))
+	}
+	if rp.KeepLast == 0 && rp.KeepDays == 0 && rp.KeepDaily == 0 && rp.KeepWeekly == 0 && rp.KeepMonthly == 0 {
+		errs = append(errs, errors.New("policy: at least one rule is required (keepLast, keepDays, keepDaily, keepWeekly or keepMonthly)"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// ParseBackupFileName parses the name of a backup file written by MaestroSQL, like name=2024-06-24_14-30-15.bak or, for a stripe, name=2024-06-24_14-30-15_1of4.bak:
+// the database name is before the last "=", followed by the backup time, and the extension is the backup type (.bak, .dif or .trn, see BackupType.Extension).
+// It reports false for the files which do not follow the naming
+func ParseBackupFileName(fileName string) (database string, backupType BackupType, backupTime time.Time, ok bool) {
+	baseName, _, _, extension, striped := ParseStripe(fileName)
+	if !striped {
+		extension = filepath.Ext(fileName)
+		baseName = strings.TrimSuffix(fileName, extension)
+	}
+
+	backupType, ok = BackupTypeFromExtension(extension)
+	if !ok {
+		return "", "", time.Time{}, false
+	}
+
+	separator := strings.LastIndex(baseName, "=")
+	if separator < 1 {
+		return "", "", time.Time{}, false
+	}
+
+	backupTime, err := time.ParseInLocation(BackupFileTimeLayout, baseName[separator+1:], time.Local)
+	if err != nil {
+		return "", "", time.Time{}, false
+	}
+
+	return baseName[:separator], backupType, backupTime, true
+}
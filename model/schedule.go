@@ -0,0 +1,196 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// MissedRunPolicy is what a schedule does, when the application starts, with the runs which were missed while it was stopped
+type MissedRunPolicy string
+
+const (
+	MissedRunSkip    MissedRunPolicy = "skip"     // The missed runs are skipped, and the schedule waits for its next run
+	MissedRunRunOnce MissedRunPolicy = "run_once" // The backup runs once at the start, however many runs were missed
+)
+
+// Schedule is a backup plan executed by the scheduler on its cron expressions: the backup of the databases of the connection, selected by name or by a pattern,
+// with the backup type, options, path and concurrency of a /api/backup request. The password of the connection is never returned.
+// NextRunAt is unset while the schedule is paused. The Last* fields report the last run, and the last skipped run, like the overlap with a run still going.
+// With a retention policy, the old backups of the databases of the schedule are deleted from its path after each run which backed up any database.
+// The retention only deletes the backups of disk folders. With ShipTo, the backup files are also copied to a secondary location, like in a /api/backup request
+type Schedule struct {
+	ID              string           `json:"id"`
+	Name            string           `json:"name"`
+	Cron            []string         `json:"cron"`
+	Connection      ConnInfo         `json:"connection"`
+	Databases       []string         `json:"databases,omitempty"`
+	DatabasePattern string           `json:"databasePattern,omitempty"`
+	BackupType      BackupType       `json:"backupType"`
+	Options         BackupOptions    `json:"options"`
+	Path            string           `json:"path"`
+	Target          BackupTarget     `json:"target"`
+	ShipTo          string           `json:"shipTo,omitempty"`
+	ConcurrentOpe   *int             `json:"concurrentOpe,omitempty"`
+	MissedRunPolicy MissedRunPolicy  `json:"missedRunPolicy"`
+	Retention       *RetentionPolicy `json:"retention,omitempty"`
+	Paused          bool             `json:"paused"`
+	CreatedBy       string           `json:"createdBy,omitempty"`
+	CreatedAt       time.Time        `json:"createdAt"`
+	UpdatedAt       time.Time        `json:"updatedAt"`
+	NextRunAt       *time.Time       `json:"nextRunAt,omitempty"`
+	Running         bool             `json:"running"`
+	LastRunAt       *time.Time       `json:"lastRunAt,omitempty"`
+	LastJobID       string           `json:"lastJobId,omitempty"`
+	LastState       JobState         `json:"lastState,omitempty"`
+	LastError       string           `json:"lastError,omitempty"`
+	LastSkippedAt   *time.Time       `json:"lastSkippedAt,omitempty"`
+	LastSkipReason  string           `json:"lastSkipReason,omitempty"`
+
+	LastRetentionAt      *time.Time `json:"lastRetentionAt,omitempty"`
+	LastRetentionDeleted int        `json:"lastRetentionDeleted,omitempty"` // How many backup sets the last retention deleted
+	LastRetentionError   string     `json:"lastRetentionError,omitempty"`
+}
+
+// SchedulePostRequired is the body of the POST /api/schedules and PUT /api/schedules/{id} requests. The databases are selected by name (Databases)
+// or by a pattern (DatabasePattern), like Sales_* (see path.Match). An empty password in PUT keeps the password of the schedule
+type SchedulePostRequired struct {
+	Name            string           `json:"name" binding:"required"`
+	Cron            []string         `json:"cron" binding:"required"`
+	Connection      ConnInfo         `json:"connection" binding:"required"`
+	Databases       []string         `json:"databases,omitempty"`
+	DatabasePattern string           `json:"databasePattern,omitempty"`
+	BackupType      string           `json:"backupType,omitempty"`
+	Options         BackupOptions    `json:"options,omitempty"`
+	Path            string           `json:"path" binding:"required"`
+	Target          BackupTarget     `json:"target,omitempty"`
+	ShipTo          string           `json:"shipTo,omitempty"`
+	ConcurrentOpe   *int             `json:"concurrentOpe,omitempty"`
+	MissedRunPolicy MissedRunPolicy  `json:"missedRunPolicy,omitempty"`
+	Retention       *RetentionPolicy `json:"retention,omitempty"`
+	Paused          bool             `json:"paused,omitempty"`
+}
+
+// ParseCron parses a cron expression of 5 fields (minute, hour, day of month, month, day of week), or a descriptor like @daily or @every 6h.
+// The expression runs in the application local time, unless it starts with CRON_TZ=, like CRON_TZ=America/Sao_Paulo 0 2 * * *
+func ParseCron(expression string) (cron.Schedule, error) {
+	return cron.ParseStandard(expression)
+}
+
+// NextRun returns the earliest next run of the cron expressions after the time. The expressions must be valid (see ParseCron)
+func NextRun(expressions []string, after time.Time) time.Time {
+	var next time.Time
+
+	for _, expression := range expressions {
+		parsed, err := ParseCron(expression)
+		if err != nil {
+			continue
+		}
+
+		run := parsed.Next(after)
+		if !run.IsZero() && (next.IsZero() || run.Before(next)) {
+			next = run
+		}
+	}
+
+	return next
+}
+
+// Validate checks the request, returning all the problems found at once, and sets the default backup type and missed run policy.
+// The password is not required: a connection without user and password uses the integrated (Windows) authentication, and PUT may keep the password of the schedule
+func (sr *SchedulePostRequired) Validate() error {
+	var errs []error
+
+	if sr.MissedRunPolicy == "" {
+		sr.MissedRunPolicy = MissedRunSkip
+	}
+
+	if sr.Name == "" || len([]rune(sr.Name)) > 128 {
+		errs = append(errs, errors.New("name: required, up to 128 characters"))
+	}
+
+	if len(sr.Cron) == 0 {
+		errs = append(errs, errors.New("cron: at least one cron expression is required"))
+	}
+	for _, expression := range sr.Cron {
+		if _, err := ParseCron(expression); err != nil {
+			errs = append(errs, fmt.Errorf("cron: invalid expression %q: %w", expression, err))
+		}
+	}
+
+	if sr.Connection.Host == "" {
+		errs = append(errs, errors.New("connection: host is required"))
+	}
+	if sr.Connection.Port == "" && sr.Connection.Instance == "" {
+		errs = append(errs, errors.New("connection: port or instance is required"))
+	}
+	if sr.Connection.User == "" && sr.Connection.Password != "" {
+		errs = append(errs, errors.New("connection: user is required with a password"))
+	}
+
+	switch {
+	case len(sr.Databases) == 0 && sr.DatabasePattern == "":
+		errs = append(errs, errors.New("databases: select the databases by name or by databasePattern"))
+	case len(sr.Databases) > 0 && sr.DatabasePattern != "":
+		errs = append(errs, errors.New("databases: cannot be used with databasePattern"))
+	case sr.DatabasePattern != "":
+		if _, err := path.Match(sr.DatabasePattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("databasePattern: invalid pattern %q", sr.DatabasePattern))
+		}
+	}
+	if slices.Contains(sr.Databases, "") {
+		errs = append(errs, errors.New("databases: cannot have an empty name"))
+	}
+
+	backupType, err := ParseBackupType(sr.BackupType)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("backupType: %w", err))
+	} else {
+		sr.BackupType = string(backupType)
+		if err := sr.Options.Validate(backupType); err != nil {
+			errs = append(errs, fmt.Errorf("options: %w", err))
+		}
+	}
+
+	if sr.Path == "" {
+		errs = append(errs, errors.New("path: required"))
+	} else if err := sr.Target.Validate(sr.Path, sr.Options); err != nil {
+		errs = append(errs, fmt.Errorf("target: %w", err))
+	} else if err := ValidateShipTo(sr.ShipTo, sr.Target); err != nil {
+		errs = append(errs, fmt.Errorf("shipTo: %w", err))
+	}
+	if sr.ConcurrentOpe != nil && *sr.ConcurrentOpe < 1 {
+		errs = append(errs, errors.New("concurrentOpe: must be at least 1"))
+	}
+	if sr.MissedRunPolicy != MissedRunSkip && sr.MissedRunPolicy != MissedRunRunOnce {
+		errs = append(errs, fmt.Errorf("missedRunPolicy: unknown policy %q. Accepts: skip, run_once", sr.MissedRunPolicy))
+	}
+	if sr.Retention != nil {
+		if err := sr.Retention.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("retention: %w", err))
+		}
+		if sr.Target.Type == StorageURL {
+			errs = append(errs, errors.New("retention: only the backups of disk folders are deleted by the retention"))
+		}
+		// The retention only reads the path, so it would delete the stripes of the path and leave the ones of the other directories
+		if len(sr.Options.StripeDirectories) > 0 {
+			errs = append(errs, errors.New("retention: cannot be used with options.stripeDirectories, since only the backups of the path are deleted"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// MatchDatabase reports if the database is selected by the schedule, by its name or by its pattern
+func (s Schedule) MatchDatabase(name string) bool {
+	if s.DatabasePattern == "" {
+		return slices.Contains(s.Databases, name)
+	}
+
+	matched, _ := path.Match(s.DatabasePattern, name)
+	return matched
+}
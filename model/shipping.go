@@ -0,0 +1,91 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ShipType is the kind of secondary location where the backup files are copied after the backup
+type ShipType string
+
+const (
+	ShipMount ShipType = "mount" // A directory of MaestroSQL host, like a SMB or NFS mount
+	ShipSFTP  ShipType = "sftp"  // sftp://user@host[:port]/directory
+	ShipS3    ShipType = "s3"    // s3://host[:port]/bucket/folder, an AWS S3 or S3-compatible endpoint
+)
+
+// ShipDestination is the secondary location of the backup files, parsed from the shipTo of the backup request (see ParseShipDestination).
+// Path is the directory of a mount or of the SFTP server
+type ShipDestination struct {
+	Type     ShipType       `json:"type"`
+	Path     string         `json:"path,omitempty"`
+	Host     string         `json:"host,omitempty"`
+	User     string         `json:"user,omitempty"`
+	Location ObjectLocation `json:"-"`
+}
+
+// ShippedFile is a backup file copied to the secondary location: its source, its copy, their size and the SHA-256 checksum they were verified with
+type ShippedFile struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+}
+
+// ValidateShipTo checks the shipTo of a backup of the target. It is optional, and the backups to URL cannot be shipped: their files are not read by MaestroSQL
+func ValidateShipTo(shipTo string, target BackupTarget) error {
+	if shipTo == "" {
+		return nil
+	}
+	if target.Type == StorageURL {
+		return errors.New("The backups of the url target are not shipped. Their files are already in the object storage")
+	}
+
+	_, err := ParseShipDestination(shipTo)
+	return err
+}
+
+// ParseShipDestination parses the secondary location of the backup files: a directory, like /mnt/nfs/backups or \\server\share\backups,
+// an SFTP directory, like sftp://backup@sftp.local:22/backups, or a S3 folder, like s3://minio.local:9000/backups/sql
+func ParseShipDestination(destination string) (ShipDestination, error) {
+	lower := strings.ToLower(destination)
+
+	switch {
+	case strings.HasPrefix(lower, "s3://"):
+		location, err := ParseObjectPath(destination)
+		if err != nil {
+			return ShipDestination{}, err
+		}
+		return ShipDestination{Type: ShipS3, Host: location.Host, Path: location.Prefix, Location: location}, nil
+	case strings.HasPrefix(lower, "sftp://"):
+		parsed, err := url.Parse(destination)
+		if err != nil {
+			return ShipDestination{}, err
+		}
+		if parsed.Hostname() == "" || parsed.User == nil || parsed.User.Username() == "" {
+			return ShipDestination{}, errors.New("The SFTP destination requires the user and the host, like sftp://user@host:22/directory")
+		}
+		if _, hasPassword := parsed.User.Password(); hasPassword {
+			return ShipDestination{}, errors.New("The SFTP destination cannot have a password. It is set by the storage.sftp configuration")
+		}
+
+		port := parsed.Port()
+		if port == "" {
+			port = "22"
+		}
+		directory := parsed.Path
+		if directory == "" {
+			directory = "."
+		}
+		return ShipDestination{Type: ShipSFTP, Host: net.JoinHostPort(parsed.Hostname(), port), User: parsed.User.Username(), Path: directory}, nil
+	case strings.Contains(lower, "://"):
+		return ShipDestination{}, fmt.Errorf("Unknown destination %q. Accepts: a directory, sftp:// and s3://", destination)
+	case destination == "":
+		return ShipDestination{}, errors.New("The destination is empty")
+	}
+
+	return ShipDestination{Type: ShipMount, Path: destination}, nil
+}
@@ -2,9 +2,53 @@ package model
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 )
 
+// RejectReason is the typed reason why a database is rejected before its operation starts
+type RejectReason string
+
+const (
+	RejectNotFound               RejectReason = "not_found"                 // The database does not exist in the server
+	RejectTempdb                 RejectReason = "tempdb"                    // tempdb cannot be backed up or restored
+	RejectSnapshot               RejectReason = "snapshot"                  // Database snapshots cannot be backed up
+	RejectNotOnline              RejectReason = "not_online"                // The database is OFFLINE, RESTORING, RECOVERING, SUSPECT, ...
+	RejectMasterFullOnly         RejectReason = "master_full_only"          // master only accepts full backups
+	RejectSimpleRecovery         RejectReason = "simple_recovery"           // Log backups are not allowed in the SIMPLE recovery model
+	RejectSystemDatabase         RejectReason = "system_database"           // master, model and msdb are only restored by /api/restore/system
+	RejectEncryptorNotFound      RejectReason = "encryptor_not_found"       // The certificate or asymmetric key of an encrypted backup is not in the master database of the server
+	RejectTDECertificateNotFound RejectReason = "tde_certificate_not_found" // The certificate of a database encrypted with TDE is not in the master database of the server
+)
+
+// RejectedErr is the error of a database rejected before its operation starts, with its typed reason
+type RejectedErr struct {
+	Reason RejectReason
+	Err    error
+}
+
+func NewRejectedErr(reason RejectReason, err error) *RejectedErr {
+	return &RejectedErr{Reason: reason, Err: err}
+}
+
+func (re *RejectedErr) Error() string {
+	return re.Err.Error()
+}
+
+func (re *RejectedErr) Unwrap() error {
+	return re.Err
+}
+
+// Reason returns the reason of a rejected database error, or an empty reason for other errors
+func Reason(err error) RejectReason {
+	var rejected *RejectedErr
+	if errors.As(err, &rejected) {
+		return rejected.Reason
+	}
+
+	return ""
+}
+
 type SqlErr struct {
 	Database string
 	Err      error
@@ -33,12 +77,34 @@ func (se *SqlErr) Unwrap() error {
 	return se.Err
 }
 
+// sqlErrJSON is the JSON representation of a SqlErr
+type sqlErrJSON struct {
+	Database string       `json:"database"`
+	Error    string       `json:"error"`
+	Reason   RejectReason `json:"reason,omitempty"`
+}
+
 func (se *SqlErr) MarshalJSON() ([]byte, error) {
-	return json.Marshal(struct {
-		Database string `json:"database"`
-		Error    string `json:"error"`
-	}{
+	return json.Marshal(sqlErrJSON{
 		Database: se.Database,
 		Error:    se.Err.Error(),
+		Reason:   Reason(se.Err),
 	})
 }
+
+// UnmarshalJSON reads a SqlErr stored by MarshalJSON, like the errors of the operation history. The error keeps only its message and reason
+func (se *SqlErr) UnmarshalJSON(data []byte) error {
+	var value sqlErrJSON
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+
+	se.Database = value.Database
+	se.Err = errors.New(value.Error)
+	if value.Reason != "" {
+		se.Err = NewRejectedErr(value.Reason, se.Err)
+	}
+
+	return nil
+}
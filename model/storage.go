@@ -0,0 +1,128 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// StorageType is where a backup is written: the files of a disk folder (a local volume or a network share) or the objects of an object storage
+type StorageType string
+
+const (
+	StorageDisk StorageType = "disk" // BACKUP ... TO DISK, written to the backup path
+	StorageURL  StorageType = "url"  // BACKUP ... TO URL, written to a S3-compatible bucket (SQL Server 2022) or an Azure Blob Storage container
+)
+
+// ObjectProvider is the object storage of a backup URL
+type ObjectProvider string
+
+const (
+	ProviderS3    ObjectProvider = "s3"    // s3://host[:port]/bucket/folder, an AWS S3 or S3-compatible endpoint (like MinIO)
+	ProviderAzure ObjectProvider = "azure" // https://account.blob.core.windows.net/container/folder
+)
+
+// BackupTarget is the storage of a backup, sent in the /api/backup request. An empty Type is taken from the path: url for s3:// and https:// paths, disk otherwise.
+// Credential adds WITH CREDENTIAL, for the credentials with the storage account key of an Azure container (page blobs). S3 URLs, and Azure URLs with a
+// shared access signature, use the credential named after the URL prefix instead (see /api/credentials)
+type BackupTarget struct {
+	Type       StorageType `json:"type,omitempty"`
+	Credential string      `json:"credential,omitempty"`
+}
+
+// ObjectLocation is a backup URL split into its parts. Prefix is the folder inside the bucket or container, without the leading and trailing slashes
+type ObjectLocation struct {
+	Provider ObjectProvider `json:"provider"`
+	Host     string         `json:"host"`
+	Bucket   string         `json:"bucket"`
+	Prefix   string         `json:"prefix,omitempty"`
+}
+
+// IsURLPath reports if the backup path is the URL of an object storage, written and read with TO URL / FROM URL instead of DISK
+func IsURLPath(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasPrefix(lower, "s3://") || strings.HasPrefix(lower, "https://")
+}
+
+// ParseObjectPath splits a backup URL, like s3://minio.local:9000/backups/sql or https://account.blob.core.windows.net/backups/sql, into its object location
+func ParseObjectPath(path string) (ObjectLocation, error) {
+	parsed, err := url.Parse(path)
+	if err != nil {
+		return ObjectLocation{}, err
+	}
+
+	var location ObjectLocation
+	switch strings.ToLower(parsed.Scheme) {
+	case "s3":
+		location.Provider = ProviderS3
+	case "https":
+		location.Provider = ProviderAzure
+	default:
+		return ObjectLocation{}, fmt.Errorf("Unknown URL scheme %q. Accepts: s3:// (S3-compatible) and https:// (Azure Blob Storage)", parsed.Scheme)
+	}
+
+	if parsed.Host == "" {
+		return ObjectLocation{}, errors.New("The URL has no host")
+	}
+	if parsed.RawQuery != "" || parsed.User != nil {
+		return ObjectLocation{}, errors.New("The URL cannot have a query or user information. The secrets are kept in the server credentials")
+	}
+	location.Host = parsed.Host
+
+	bucket, prefix, _ := strings.Cut(strings.Trim(parsed.Path, "/"), "/")
+	if bucket == "" {
+		return ObjectLocation{}, errors.New("The URL has no bucket or container")
+	}
+	location.Bucket = bucket
+	location.Prefix = strings.Trim(prefix, "/")
+
+	return location, nil
+}
+
+// Validate checks the target for the backup path and options, and sets the type taken from the path when it is empty
+func (bt *BackupTarget) Validate(path string, options BackupOptions) error {
+	var errs []error
+
+	if bt.Type == "" {
+		bt.Type = StorageDisk
+		if IsURLPath(path) {
+			bt.Type = StorageURL
+		}
+	}
+
+	switch bt.Type {
+	case StorageDisk:
+		if IsURLPath(path) {
+			errs = append(errs, errors.New("path: URLs are written by the url target"))
+		}
+		if bt.Credential != "" {
+			errs = append(errs, errors.New("credential: only used by the url target"))
+		}
+	case StorageURL:
+		if !IsURLPath(path) {
+			errs = append(errs, errors.New("path: the url target requires a s3:// or https:// URL"))
+			break
+		}
+		location, err := ParseObjectPath(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("path: %w", err))
+			break
+		}
+		if len(options.StripeDirectories) > 0 {
+			errs = append(errs, errors.New("stripeDirectories: cannot be used with the url target. The stripes are written to the backup URL"))
+		}
+		if location.Provider == ProviderS3 {
+			if bt.Credential != "" {
+				errs = append(errs, errors.New("credential: S3 URLs use the credential named after the URL, like s3://host/bucket. WITH CREDENTIAL is not supported"))
+			}
+			if options.MaxTransferSize != 0 {
+				errs = append(errs, errors.New("maxTransferSize: S3 URLs use the SQL Server default (10 MB)"))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Errorf("type: unknown storage type %q. Accepts: disk, url", bt.Type))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,40 @@
+package model
+
+import "testing"
+
+func TestParseObjectPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    ObjectLocation
+		wantErr bool
+	}{
+		{path: "s3://minio.local:9000/backups/sql", want: ObjectLocation{Provider: ProviderS3, Host: "minio.local:9000", Bucket: "backups", Prefix: "sql"}},
+		{path: "S3://minio.local:9000/backups", want: ObjectLocation{Provider: ProviderS3, Host: "minio.local:9000", Bucket: "backups"}},
+		{path: "s3://minio.local/backups/sql/daily/", want: ObjectLocation{Provider: ProviderS3, Host: "minio.local", Bucket: "backups", Prefix: "sql/daily"}},
+		{path: "https://account.blob.core.windows.net/backups/sql", want: ObjectLocation{Provider: ProviderAzure, Host: "account.blob.core.windows.net", Bucket: "backups", Prefix: "sql"}},
+		{path: "http://minio.local:9000/backups", wantErr: true},
+		{path: "D:/Backups", wantErr: true},
+		{path: "s3:///backups", wantErr: true},
+		{path: "s3://minio.local:9000/", wantErr: true},
+		{path: "https://account.blob.core.windows.net/backups?sv=2021-08-06&sig=secret", wantErr: true},
+		{path: "s3://key:secret@minio.local:9000/backups", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			location, err := ParseObjectPath(tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseObjectPath() = %+v, want an error", location)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseObjectPath() error = %v", err)
+			}
+			if location != tt.want {
+				t.Errorf("ParseObjectPath() = %+v, want %+v", location, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+)
+
+// ErrCredentialNotFound is returned when the credential does not exist in the server
+var ErrCredentialNotFound = errors.New("Credential not found")
+
+// Gets the credentials of the server (sys.credentials), by name. Their secrets cannot be read
+func (dr *DatabaseRepository) GetCredentials() ([]model.Credential, error) {
+	query := `SELECT credential_id, name, ISNULL(credential_identity, ''), create_date, modify_date
+		FROM sys.credentials
+		ORDER BY name;`
+
+	rows, err := dr.connection.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credentials := []model.Credential{}
+	for rows.Next() {
+		var credential model.Credential
+		err = rows.Scan(&credential.ID, &credential.Name, &credential.Identity, &credential.CreatedAt, &credential.ModifiedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		credentials = append(credentials, credential)
+	}
+
+	return credentials, rows.Err()
+}
+
+// Creates the credential, or replaces the identity and secret of an existing one (ALTER CREDENTIAL). Reports if it was created.
+// CREATE CREDENTIAL does not accept parameters, so the statement is built by the server from them: the name is quoted by QUOTENAME and
+// the identity and secret are escaped as string literals
+func (dr *DatabaseRepository) SaveCredential(name string, identity string, secret string) (bool, error) {
+	query := `DECLARE @Exists bit = CASE WHEN EXISTS (SELECT 1 FROM sys.credentials WHERE name = @Name) THEN 1 ELSE 0 END;
+		DECLARE @Statement nvarchar(max) = CASE WHEN @Exists = 1 THEN N'ALTER' ELSE N'CREATE' END
+			+ N' CREDENTIAL ' + QUOTENAME(@Name)
+			+ N' WITH IDENTITY = N''' + REPLACE(@Identity, N'''', N'''''') + N''''
+			+ N', SECRET = N''' + REPLACE(@Secret, N'''', N'''''') + N''';';
+		EXEC (@Statement);
+		SELECT @Exists;`
+
+	var exists bool
+	err := dr.connection.QueryRow(query, sql.Named("Name", name), sql.Named("Identity", identity), sql.Named("Secret", secret)).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return !exists, nil
+}
+
+// Drops the credential. Returns ErrCredentialNotFound if it does not exist
+func (dr *DatabaseRepository) DropCredential(name string) error {
+	query := `IF NOT EXISTS (SELECT 1 FROM sys.credentials WHERE name = @Name)
+		BEGIN
+			SELECT CAST(0 AS bit);
+			RETURN;
+		END
+		DECLARE @Statement nvarchar(max) = N'DROP CREDENTIAL ' + QUOTENAME(@Name) + N';';
+		EXEC (@Statement);
+		SELECT CAST(1 AS bit);`
+
+	var dropped bool
+	err := dr.connection.QueryRow(query, sql.Named("Name", name)).Scan(&dropped)
+	if err != nil {
+		return err
+	}
+	if !dropped {
+		return ErrCredentialNotFound
+	}
+
+	return nil
+}
@@ -3,18 +3,25 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/RenanMonteiroS/MaestroSQLWeb/config"
 	"github.com/RenanMonteiroS/MaestroSQLWeb/db"
 	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	mssql "github.com/microsoft/go-mssqldb"
 )
 
+// How long a cancelled statement may keep running before its session is killed
+const killGracePeriod = 10 * time.Second
+
 // Struct responsible for manage database access, like SELECT, BACKUP and RESTORE statements. Requires a sql connection pool object [sql.DB]
 // Related to database objects
 type DatabaseRepository struct {
@@ -29,9 +36,9 @@ func NewDatabaseRepository(connection *sql.DB) DatabaseRepository {
 }
 
 // Establish a connection with a database.
-// Args: connInfo -> A struct with connection params (host, port, user, password)
-func (ds *DatabaseRepository) ConnectDatabase(connInfo model.ConnInfo) (*sql.DB, error) {
-	conn, err := db.ConnDb(connInfo)
+// Args: connInfo -> A struct with connection params (host, port, user, password); cfg -> The database connection defaults
+func (ds *DatabaseRepository) ConnectDatabase(connInfo model.ConnInfo, cfg config.DatabaseConfig) (*sql.DB, error) {
+	conn, err := db.ConnDb(connInfo, cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -56,10 +63,13 @@ func (ds *DatabaseRepository) CheckDbConn() error {
 
 }
 
-// Performs a SELECT in [master] database, to list all server databases
+// Performs a SELECT in [master] database, to list all server databases, with their state, recovery model, compatibility level, read-only flag, owner and if they are snapshots.
+// One row is returned for each database file, with its size in bytes
 func (dr *DatabaseRepository) GetDatabases() ([]model.MergedDatabaseFileInfo, error) {
 	query := "SELECT d.database_id, d.name DatabaseName, " +
-		"f.name LogicalName, f.physical_name AS PhysicalName, f.type_desc TypeofFile " +
+		"f.name LogicalName, f.physical_name AS PhysicalName, f.type_desc TypeofFile, CAST(f.size AS bigint) * 8192 AS Size, " +
+		"d.state_desc, d.recovery_model_desc, d.compatibility_level, d.is_read_only, " +
+		"CAST(CASE WHEN d.source_database_id IS NULL THEN 0 ELSE 1 END AS bit) AS IsSnapshot, ISNULL(SUSER_SNAME(d.owner_sid), '') AS Owner " +
 		"FROM sys.master_files f " +
 		"INNER JOIN sys.databases " +
 		"d ON d.database_id = f.database_id ORDER BY d.name;"
@@ -74,7 +84,8 @@ func (dr *DatabaseRepository) GetDatabases() ([]model.MergedDatabaseFileInfo, er
 
 	for rows.Next() {
 		err = rows.Scan(&dbObjAux.DatabaseId, &dbObjAux.DatabaseName, &dbObjAux.LogicalName,
-			&dbObjAux.PhysicalName, &dbObjAux.FileType)
+			&dbObjAux.PhysicalName, &dbObjAux.FileType, &dbObjAux.Size, &dbObjAux.State, &dbObjAux.RecoveryModel,
+			&dbObjAux.CompatibilityLevel, &dbObjAux.IsReadOnly, &dbObjAux.IsSnapshot, &dbObjAux.Owner)
 
 		if err != nil {
 			return nil, err
@@ -86,10 +97,15 @@ func (dr *DatabaseRepository) GetDatabases() ([]model.MergedDatabaseFileInfo, er
 	return dbListAux, nil
 }
 
-// Performs a BACKUP DATABASE statement, for each database selected, storing into the backup path choosed.
-// The BACKUP DATABASE statements are executed in goroutines, which makes them concurrent
-func (dr *DatabaseRepository) BackupDatabase(backupDbList []model.Database, backupPath string, concurrentOpe *int) ([]model.Database, []model.SqlErr) {
+// Performs a BACKUP DATABASE (full or differential) or BACKUP LOG statement, depending on the backup type, for each database selected, storing into the backup path choosed.
+// The options are rendered as the WITH clause of the statement.
+// The BACKUP statements are executed in goroutines, which makes them concurrent. It is the executor of the backup jobs:
+// each statement is limited by the timeout and the observer is notified when each database starts and finishes
+func (dr *DatabaseRepository) BackupDatabase(ctx context.Context, backupDbList []model.Database, backupPath string, target model.BackupTarget, backupType model.BackupType, options model.BackupOptions, concurrentOpe *int, timeout time.Duration, observer OperationObserver) ([]model.Database, []model.SqlErr) {
 	t0 := time.Now()
+	if observer == nil {
+		observer = noopObserver{}
+	}
 	type BackupResult struct {
 		Database model.Database
 		Error    error
@@ -111,29 +127,69 @@ func (dr *DatabaseRepository) BackupDatabase(backupDbList []model.Database, back
 
 	// Creates a function to perform backups
 	doBackup := func(database model.Database) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		databaseCtx, cancelDatabase := observer.DatabaseContext(ctx, database.Name)
+		defer cancelDatabase()
+
+		// The database was cancelled while it was queued
+		if err := databaseCtx.Err(); err != nil {
+			observer.DatabaseFinished(database.Name, err)
+			resultCh <- BackupResult{database, err, false}
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(databaseCtx, timeout)
 		defer cancel()
 
-		query := fmt.Sprintf("BACKUP DATABASE [%s] TO DISK = @Path", database.Name)
-		path := fmt.Sprintf("%s/%s=%v_%v.bak", backupPath, database.Name,
-			time.Now().Format("2006-01-02"), time.Now().Format("15-04-05"))
+		paths := backupFilePaths(database.Name, backupPath, backupType, options)
+		query, args := backupStatement(database.Name, backupType, paths, target, options)
+		args = append(args, diskArgs(paths)...)
 
-		stmt, err := dr.connection.Prepare(query)
+		conn, spid, err := dr.openSession(ctx)
+		if err != nil {
+			backupLogger.Error("Error opening a session for the BACKUP query: ", "Query: ", query, "Error: ", err)
+			observer.DatabaseFinished(database.Name, err)
+			resultCh <- BackupResult{database, err, false}
+			return
+		}
+		defer conn.Close()
+
+		observer.DatabaseStarted(database.Name, spid, paths)
+
+		stmt, err := conn.PrepareContext(ctx, query)
 		if err != nil {
 			backupLogger.Error("Error preparing BACKUP query: ", "Query: ", query, "Error: ", err)
+			observer.DatabaseFinished(database.Name, err)
 			resultCh <- BackupResult{database, err, false}
 			return
 		}
 		defer stmt.Close()
 
-		_, err = stmt.ExecContext(ctx, sql.Named("Path", path))
+		err = dr.execOrKill(ctx, stmt, spid, args...)
 		if err != nil {
 			backupLogger.Error("Error executing BACKUP query: ", "Query: ", query, "Error: ", err)
+			observer.DatabaseFinished(database.Name, err)
 			resultCh <- BackupResult{database, err, false}
 			return
 		}
 
 		backupLogger.Info(fmt.Sprintf("Backup related to [%v] database completed", database.Name), "Database:", database.Name)
+
+		// The verification has its own timeout, so a long backup does not leave it without time
+		if options.Verify != nil && *options.Verify {
+			verifyCtx, cancelVerify := context.WithTimeout(databaseCtx, timeout)
+			defer cancelVerify()
+
+			checksum := options.Checksum != nil && *options.Checksum
+			err = dr.verifyBackup(verifyCtx, conn, spid, paths, checksum)
+			if err != nil {
+				backupLogger.Error("Error verifying the backup (RESTORE VERIFYONLY): ", "Database: ", database.Name, "Files: ", paths, "Error: ", err)
+			} else {
+				backupLogger.Info(fmt.Sprintf("Backup related to [%v] database verified", database.Name), "Database:", database.Name)
+			}
+			observer.DatabaseVerified(database.Name, err)
+		}
+
+		observer.DatabaseFinished(database.Name, nil)
 		resultCh <- BackupResult{database, nil, true}
 	}
 
@@ -197,16 +253,295 @@ func (dr *DatabaseRepository) BackupDatabase(backupDbList []model.Database, back
 
 	backupLogger.Info(fmt.Sprintf("Total Time: %v", time.Since(t0)))
 	backupLogger.Info(fmt.Sprintf("Path: %v", backupPath))
+	backupLogger.Info(fmt.Sprintf("Target: %v", target.Type))
+	backupLogger.Info(fmt.Sprintf("Backup type: %v", backupType))
+	backupLogger.Info("Backup options", "Options", options)
 	backupLogger.Info(fmt.Sprintf("Total Backups: %v", len(dbDoneList)))
 
 	return dbDoneList, errorsList
 }
 
+// Builds the file paths of the database backup. A striped backup writes one file per stripe, named like name=date_time_1of4.bak,
+// distributed in turns between the backup path and the stripe directories. For a backup URL, the paths are the URLs of the objects
+func backupFilePaths(database string, backupPath string, backupType model.BackupType, options model.BackupOptions) []string {
+	if model.IsURLPath(backupPath) {
+		backupPath = strings.TrimSuffix(backupPath, "/")
+	}
+
+	now := time.Now()
+	baseName := fmt.Sprintf("%s=%v", database, now.Format(model.BackupFileTimeLayout))
+
+	if options.Stripes < 2 {
+		return []string{fmt.Sprintf("%s/%s%s", backupPath, baseName, backupType.Extension())}
+	}
+
+	directories := append([]string{backupPath}, options.StripeDirectories...)
+	paths := make([]string, 0, options.Stripes)
+	for stripe := 1; stripe <= options.Stripes; stripe++ {
+		directory := directories[(stripe-1)%len(directories)]
+		paths = append(paths, fmt.Sprintf("%s/%s", directory, model.StripeFileName(baseName, stripe, options.Stripes, backupType.Extension())))
+	}
+
+	return paths
+}
+
+// BackupStatement returns the BACKUP statement of the database and the files it writes, as they are executed by BackupDatabase. The files are passed as parameters (@Path1, @Path2, ...)
+func BackupStatement(database string, backupPath string, target model.BackupTarget, backupType model.BackupType, options model.BackupOptions) (string, []string) {
+	paths := backupFilePaths(database, backupPath, backupType, options)
+	query, _ := backupStatement(database, backupType, paths, target, options)
+
+	return query, paths
+}
+
+// Builds the list of backup devices of a BACKUP/RESTORE statement: DISK = @Path1, DISK = @Path2, ... The URLs of an object storage are URL devices (URL = @Path1).
+// The paths are passed with diskArgs
+func diskList(paths []string) string {
+	disks := make([]string, 0, len(paths))
+	for i, path := range paths {
+		device := "DISK"
+		if model.IsURLPath(path) {
+			device = "URL"
+		}
+		disks = append(disks, fmt.Sprintf("%s = @Path%d", device, i+1))
+	}
+
+	return strings.Join(disks, ", ")
+}
+
+// Builds the parameters of the devices listed by diskList
+func diskArgs(paths []string) []any {
+	args := make([]any, 0, len(paths))
+	for i, path := range paths {
+		args = append(args, sql.Named(fmt.Sprintf("Path%d", i+1), path))
+	}
+
+	return args
+}
+
+// Builds the RESTORE VERIFYONLY statement of the devices of diskList. WITH CHECKSUM also validates the page checksums, and fails if the backup has no checksums
+func verifyStatement(paths []string, checksum bool) string {
+	query := fmt.Sprintf("RESTORE VERIFYONLY FROM %s", diskList(paths))
+	if checksum {
+		query += " WITH CHECKSUM"
+	}
+
+	return query + ";"
+}
+
+// Checks the backup files with RESTORE VERIFYONLY, in the session which wrote them
+func (dr *DatabaseRepository) verifyBackup(ctx context.Context, conn *sql.Conn, spid int, paths []string, checksum bool) error {
+	stmt, err := conn.PrepareContext(ctx, verifyStatement(paths, checksum))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	return dr.execOrKill(ctx, stmt, spid, diskArgs(paths)...)
+}
+
+// Performs a RESTORE VERIFYONLY statement for each backup file (every stripe of the media set, when the backup is striped), checking if it can be restored.
+// The statements are executed in goroutines, which makes them concurrent. It is the executor of the verify jobs: each database of the job is named by the backup path,
+// each statement is limited by the timeout and the observer is notified when each file starts and finishes
+func (dr *DatabaseRepository) VerifyBackups(ctx context.Context, files []model.ToBeVerifiedFile, checksum bool, concurrentOpe *int, timeout time.Duration, observer OperationObserver) ([]model.ToBeVerifiedFile, []model.SqlErr) {
+	t0 := time.Now()
+	if observer == nil {
+		observer = noopObserver{}
+	}
+
+	type verifyResult struct {
+		file model.ToBeVerifiedFile
+		err  error
+	}
+
+	resultCh := make(chan verifyResult, len(files))
+
+	verifyLogFile, err := os.OpenFile("verify.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		slog.Error("Cannot open verify log file: ", "Error: ", err)
+	}
+	defer verifyLogFile.Close()
+
+	verifyLogger := slog.New(slog.NewJSONHandler(verifyLogFile, &slog.HandlerOptions{
+		AddSource: true,
+		Level:     slog.LevelInfo,
+	}))
+
+	doVerify := func(file model.ToBeVerifiedFile) {
+		ctx, cancelFile := observer.DatabaseContext(ctx, file.BackupPath)
+		defer cancelFile()
+
+		// The file was cancelled while it was queued
+		if err := ctx.Err(); err != nil {
+			observer.DatabaseFinished(file.BackupPath, err)
+			resultCh <- verifyResult{file, err}
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		conn, spid, err := dr.openSession(ctx)
+		if err != nil {
+			verifyLogger.Error("Error opening a session for the RESTORE VERIFYONLY query: ", "File: ", file.BackupPath, "Error: ", err)
+			observer.DatabaseFinished(file.BackupPath, err)
+			resultCh <- verifyResult{file, err}
+			return
+		}
+		defer conn.Close()
+
+		observer.DatabaseStarted(file.BackupPath, spid, file.MediaSet())
+
+		err = dr.verifyBackup(ctx, conn, spid, file.MediaSet(), checksum)
+		if err != nil {
+			verifyLogger.Error("Error executing RESTORE VERIFYONLY query: ", "Files: ", file.MediaSet(), "Error: ", err)
+			observer.DatabaseFinished(file.BackupPath, err)
+			resultCh <- verifyResult{file, err}
+			return
+		}
+
+		verifyLogger.Info(fmt.Sprintf("Backup file [%v] verified", file.BackupPath), "Files:", file.MediaSet())
+		observer.DatabaseFinished(file.BackupPath, nil)
+		resultCh <- verifyResult{file, nil}
+	}
+
+	// If any concurrent operation quantity is set...
+	var wg sync.WaitGroup
+	if concurrentOpe != nil && *concurrentOpe > 0 {
+		jobCh := make(chan model.ToBeVerifiedFile, len(files))
+
+		// Creates a quantity of physical goroutines, based on the concurrent operation quantity
+		for i := 0; i < *concurrentOpe; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for file := range jobCh {
+					doVerify(file)
+				}
+			}()
+		}
+
+		for _, file := range files {
+			jobCh <- file
+		}
+		close(jobCh)
+	} else { //If the concurrent operation limit is not set, it creates all the routines.
+		for _, file := range files {
+			wg.Add(1)
+			go func(file model.ToBeVerifiedFile) {
+				defer wg.Done()
+				doVerify(file)
+			}(file)
+		}
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultCh)
+	}()
+
+	var verifiedFiles []model.ToBeVerifiedFile
+	var errorsList []model.SqlErr
+
+	for result := range resultCh {
+		if result.err == nil {
+			verifiedFiles = append(verifiedFiles, result.file)
+		} else {
+			errorsList = append(errorsList, model.SqlErr{Database: result.file.BackupPath, Err: result.err})
+		}
+	}
+
+	verifyLogger.Info(fmt.Sprintf("Total Time: %v", time.Since(t0)))
+	verifyLogger.Info(fmt.Sprintf("Total Verified: %v", len(verifiedFiles)))
+
+	return verifiedFiles, errorsList
+}
+
+// Builds the BACKUP statement of the database, writing to the devices of diskList. DESCRIPTION, NAME and the CREDENTIAL of the target are passed as parameters
+// (returned in args), while the numeric options are rendered in the statement, since they were validated by BackupOptions.Validate.
+// The ENCRYPTION option does not accept parameters, so the name of its encryptor is quoted
+func backupStatement(database string, backupType model.BackupType, paths []string, target model.BackupTarget, options model.BackupOptions) (string, []any) {
+	var query string
+	if backupType == model.BackupLog {
+		query = fmt.Sprintf("BACKUP LOG [%s] TO %s", database, diskList(paths))
+	} else {
+		query = fmt.Sprintf("BACKUP DATABASE [%s] TO %s", database, diskList(paths))
+	}
+
+	var with []string
+	var args []any
+
+	// Renders a boolean option, which is omitted when it is not set
+	flag := func(value *bool, enabled string, disabled string) {
+		if value == nil {
+			return
+		}
+		if *value {
+			with = append(with, enabled)
+		} else if disabled != "" {
+			with = append(with, disabled)
+		}
+	}
+
+	if backupType == model.BackupDifferential {
+		with = append(with, "DIFFERENTIAL")
+	}
+	flag(options.Compression, "COMPRESSION", "NO_COMPRESSION")
+	flag(options.Checksum, "CHECKSUM", "NO_CHECKSUM")
+	flag(options.CopyOnly, "COPY_ONLY", "")
+	flag(options.ContinueAfterError, "CONTINUE_AFTER_ERROR", "STOP_ON_ERROR")
+	flag(options.Init, "INIT", "NOINIT")
+	flag(options.Format, "FORMAT", "NOFORMAT")
+
+	if options.Stats > 0 {
+		with = append(with, fmt.Sprintf("STATS = %d", options.Stats))
+	}
+	if options.BufferCount > 0 {
+		with = append(with, fmt.Sprintf("BUFFERCOUNT = %d", options.BufferCount))
+	}
+	if options.MaxTransferSize > 0 {
+		with = append(with, fmt.Sprintf("MAXTRANSFERSIZE = %d", options.MaxTransferSize))
+	}
+	if options.BlockSize > 0 {
+		with = append(with, fmt.Sprintf("BLOCKSIZE = %d", options.BlockSize))
+	}
+	if options.Description != "" {
+		with = append(with, "DESCRIPTION = @Description")
+		args = append(args, sql.Named("Description", options.Description))
+	}
+	if options.Name != "" {
+		with = append(with, "NAME = @Name")
+		args = append(args, sql.Named("Name", options.Name))
+	}
+	// CREDENTIAL does not accept a variable, so the credential name is rendered as a quoted identifier
+	if target.Credential != "" {
+		with = append(with, fmt.Sprintf("CREDENTIAL = [%s]", strings.ReplaceAll(target.Credential, "]", "]]")))
+	}
+	if options.Encryption != nil {
+		encryptorType, encryptor := options.Encryption.Encryptor()
+		keyword := "SERVER CERTIFICATE"
+		if encryptorType == model.EncryptorAsymmetricKey {
+			keyword = "SERVER ASYMMETRIC KEY"
+		}
+		with = append(with, fmt.Sprintf("ENCRYPTION (ALGORITHM = %s, %s = [%s])", options.Encryption.Algorithm, keyword, strings.ReplaceAll(encryptor, "]", "]]")))
+	}
+
+	if len(with) > 0 {
+		query += " WITH " + strings.Join(with, ", ")
+	}
+
+	return query, args
+}
+
 // Performs a RESTORE DATABASE statement, for all backup files inside the backup path.
-// The database name is based on the backup file name, as well as the name of the database files (.mdf, .ldf, .ndf)
-// The RESTORE DATABASE statements are executed in goroutines, which makes them concurrent
-func (dr *DatabaseRepository) RestoreDatabase(restoreDbList []model.RestoreDb, dataPath string, logPath string, concurrentOpe *int) ([]model.RestoreDb, []model.SqlErr) {
+// The database files are moved to the targets planned in the Moves of each database.
+// The RESTORE DATABASE statements are executed in goroutines, which makes them concurrent. It is the executor of the restore jobs:
+// each statement is limited by the timeout and the observer is notified when each database starts and finishes
+func (dr *DatabaseRepository) RestoreDatabase(ctx context.Context, restoreDbList []model.RestoreDb, concurrentOpe *int, timeout time.Duration, observer OperationObserver) ([]model.RestoreDb, []model.SqlErr) {
 	t0 := time.Now()
+	if observer == nil {
+		observer = noopObserver{}
+	}
 
 	var restoreDoneDbList []model.RestoreDb
 	var errorsList []model.SqlErr
@@ -231,38 +566,51 @@ func (dr *DatabaseRepository) RestoreDatabase(restoreDbList []model.RestoreDb, d
 	}))
 
 	doRestore := func(db model.RestoreDb) {
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+		ctx, cancelDatabase := observer.DatabaseContext(ctx, db.Database.Name)
+		defer cancelDatabase()
+
+		// The database was cancelled while it was queued
+		if err := ctx.Err(); err != nil {
+			observer.DatabaseFinished(db.Database.Name, err)
+			resultCh <- restoreResult{database: db, err: err, success: false}
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
 		defer cancel()
 
-		query := fmt.Sprintf("RESTORE DATABASE [%s] FROM DISK = @Path WITH ", db.Database.Name)
-		for _, file := range db.Database.Files {
-			if file.FileType == "ROWS" {
-				if strings.Contains(file.PhysicalName, ".mdf") {
-					query += fmt.Sprintf("MOVE '%s' TO '%s%s.mdf' , ", file.LogicalName, dataPath, db.Database.Name)
-				} else if strings.Contains(file.PhysicalName, ".ndf") {
-					query += fmt.Sprintf("MOVE '%s' TO '%s%s.ndf' , ", file.LogicalName, dataPath, db.Database.Name)
-				}
+		query, args := restoreStatement(db)
 
-			} else if file.FileType == "LOG" {
-				query += fmt.Sprintf("MOVE '%s' TO '%s%s.ldf' , ", file.LogicalName, logPath, db.Database.Name)
-			}
+		conn, spid, err := dr.openSession(ctx)
+		if err != nil {
+			restoreLogger.Error("Error opening a session for the RESTORE query: ", "Query: ", query, "Error: ", err)
+			observer.DatabaseFinished(db.Database.Name, err)
+			resultCh <- restoreResult{database: db, err: err, success: false}
+			return
 		}
-		query += "RECOVERY;"
+		defer conn.Close()
 
-		stmt, err := dr.connection.Prepare(query)
+		observer.DatabaseStarted(db.Database.Name, spid, db.MediaSet())
+
+		stmt, err := conn.PrepareContext(ctx, query)
 		if err != nil {
 			restoreLogger.Error("Error preparing RESTORE query: ", "Query: ", query, "Error: ", err)
+			observer.DatabaseFinished(db.Database.Name, err)
 			resultCh <- restoreResult{database: db, err: err, success: false}
 			return
 		}
-		_, err = stmt.ExecContext(ctx, sql.Named("Path", db.BackupPath))
+		defer stmt.Close()
+
+		err = dr.execOrKill(ctx, stmt, spid, args...)
 		if err != nil {
 			restoreLogger.Error("Error executing RESTORE query: ", "Query: ", query, "Error: ", err)
+			observer.DatabaseFinished(db.Database.Name, err)
 			resultCh <- restoreResult{database: db, err: err, success: false}
 			return
 		}
 
 		restoreLogger.Info(fmt.Sprintf("Restore related to [%v] database completed", db.Database.Name), "Database:", db.Database.Name)
+		observer.DatabaseFinished(db.Database.Name, nil)
 		resultCh <- restoreResult{database: db, err: nil, success: true}
 
 		return
@@ -335,6 +683,232 @@ func (dr *DatabaseRepository) RestoreDatabase(restoreDbList []model.RestoreDb, d
 
 }
 
+// RestoreStatement returns the RESTORE DATABASE statement of the database, as it is executed by RestoreDatabase. The backup files, the file moves and the standby file
+// are passed as parameters (@Path1, @LogicalName1, @MoveTo1, @StandbyFile, ...)
+func RestoreStatement(db model.RestoreDb) string {
+	query, _ := restoreStatement(db)
+	return query
+}
+
+// Builds the RESTORE DATABASE statement of the database: the files of the media set, the MOVE of each database file and the WITH options
+func restoreStatement(db model.RestoreDb) (string, []any) {
+	mediaSet := db.MediaSet()
+	query := fmt.Sprintf("RESTORE DATABASE [%s] FROM %s WITH ", db.Database.Name, diskList(mediaSet))
+	moves, moveArgs := moveClause(db.Moves)
+	options, optionArgs := restoreOptionsClause(db.Options)
+	query += moves + options + ";"
+
+	return query, append(append(diskArgs(mediaSet), moveArgs...), optionArgs...)
+}
+
+// Builds the options of a RESTORE DATABASE statement which follow the MOVE options, ending with the recovery mode. The standby file is passed as a parameter (returned in args),
+// while the other options are keywords, validated by RestoreOptions.Validate
+func restoreOptionsClause(options model.RestoreOptions) (string, []any) {
+	var with []string
+	var args []any
+
+	if options.Replace {
+		with = append(with, "REPLACE")
+	}
+	if options.KeepReplication {
+		with = append(with, "KEEP_REPLICATION")
+	}
+	if options.KeepCDC {
+		with = append(with, "KEEP_CDC")
+	}
+	if options.EnableBroker {
+		with = append(with, "ENABLE_BROKER")
+	}
+	if options.NewBroker {
+		with = append(with, "NEW_BROKER")
+	}
+	if options.RestrictedUser {
+		with = append(with, "RESTRICTED_USER")
+	}
+	if options.Checksum != nil {
+		if *options.Checksum {
+			with = append(with, "CHECKSUM")
+		} else {
+			with = append(with, "NO_CHECKSUM")
+		}
+	}
+
+	switch options.Mode() {
+	case model.RestoreNoRecovery:
+		with = append(with, "NORECOVERY")
+	case model.RestoreStandby:
+		with = append(with, "STANDBY = @StandbyFile")
+		args = append(args, sql.Named("StandbyFile", options.StandbyFile))
+	default:
+		with = append(with, "RECOVERY")
+	}
+
+	return strings.Join(with, ", "), args
+}
+
+// Builds the MOVE options of a RESTORE DATABASE statement, one for each planned file move. The logical names and the targets are passed as parameters (returned in args):
+// MOVE @LogicalName1 TO @MoveTo1, ...
+func moveClause(moves []model.FileMove) (string, []any) {
+	var clause string
+	var args []any
+	for key, move := range moves {
+		clause += fmt.Sprintf("MOVE @LogicalName%d TO @MoveTo%d, ", key+1, key+1)
+		args = append(args, sql.Named(fmt.Sprintf("LogicalName%d", key+1), move.LogicalName), sql.Named(fmt.Sprintf("MoveTo%d", key+1), move.To))
+	}
+
+	return clause, args
+}
+
+// Reserves a dedicated connection of the pool and gets its session ID (SPID), so the statement executed through it can be followed in sys.dm_exec_requests
+func (dr *DatabaseRepository) openSession(ctx context.Context) (*sql.Conn, int, error) {
+	conn, err := dr.connection.Conn(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var spid int
+	err = conn.QueryRowContext(ctx, "SELECT @@SPID;").Scan(&spid)
+	if err != nil {
+		conn.Close()
+		return nil, 0, err
+	}
+
+	return conn, spid, nil
+}
+
+// Executes the prepared statement in the session. When the context is cancelled (or times out), the driver is expected to abort the statement.
+// If the statement is still running after killGracePeriod, the session is killed with KILL, which requires the ALTER ANY CONNECTION permission
+func (dr *DatabaseRepository) execOrKill(ctx context.Context, stmt *sql.Stmt, spid int, args ...any) error {
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+		}
+
+		select {
+		case <-done:
+			return
+		case <-time.After(killGracePeriod):
+		}
+
+		slog.Warn("The statement was not aborted after the cancellation. Killing the session", "SPID", spid)
+		err := dr.KillSession(spid)
+		if err != nil {
+			slog.Error("Cannot kill the session", "SPID", spid, "Error", err)
+		}
+	}()
+
+	_, err := stmt.ExecContext(ctx, args...)
+	return err
+}
+
+// Ends a SQL Server session (SPID) with the KILL statement. Any open transaction of the session is rolled back
+func (dr *DatabaseRepository) KillSession(spid int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), killGracePeriod)
+	defer cancel()
+
+	// KILL does not accept parameters. The SPID is an integer, so it cannot inject anything
+	_, err := dr.connection.ExecContext(ctx, fmt.Sprintf("KILL %d;", spid))
+	return err
+}
+
+// Gets the progress of every BACKUP and RESTORE command running in the server. Requires the VIEW SERVER STATE permission
+func (dr *DatabaseRepository) GetOperationsProgress(ctx context.Context) ([]model.OperationProgress, error) {
+	query := "SELECT session_id, command, percent_complete, estimated_completion_time FROM sys.dm_exec_requests " +
+		"WHERE command LIKE 'BACKUP%' OR command LIKE 'RESTORE%';"
+
+	rows, err := dr.connection.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var progress model.OperationProgress
+	var progressList []model.OperationProgress
+
+	for rows.Next() {
+		err = rows.Scan(&progress.SessionID, &progress.Command, &progress.PercentComplete, &progress.EstimatedCompletionTime)
+		if err != nil {
+			return nil, err
+		}
+
+		progressList = append(progressList, progress)
+	}
+
+	return progressList, rows.Err()
+}
+
+// Estimates the size in bytes of the backup of the database, before it is executed. A full backup is estimated by the used pages of the database, like sp_spaceused,
+// a differential backup by the extents modified since the last full backup (sys.dm_db_file_space_usage) and a log backup by the log generated since the last log backup
+// (sys.dm_db_log_stats, SQL Server 2016 SP2 or later). The estimates do not consider the backup compression
+func (dr *DatabaseRepository) GetBackupSize(database string, backupType model.BackupType) (int64, error) {
+	// The catalog views are read in the database itself, so its name is quoted in the query
+	quotedName := strings.ReplaceAll(database, "]", "]]")
+
+	var query string
+	var args []any
+	switch backupType {
+	case model.BackupLog:
+		query = "SELECT CAST(log_since_last_log_backup_mb * 1048576 AS bigint) FROM sys.dm_db_log_stats(DB_ID(@Name));"
+		args = append(args, sql.Named("Name", database))
+	case model.BackupDifferential:
+		query = fmt.Sprintf("SELECT SUM(CAST(modified_extent_page_count AS bigint)) * 8192 FROM [%s].sys.dm_db_file_space_usage;", quotedName)
+	default:
+		query = fmt.Sprintf("SELECT SUM(CAST(used_pages AS bigint)) * 8192 FROM [%s].sys.allocation_units;", quotedName)
+	}
+
+	var size sql.NullInt64
+	err := dr.connection.QueryRow(query, args...).Scan(&size)
+	if err != nil {
+		return 0, err
+	}
+
+	return size.Int64, nil
+}
+
+// Checks if a database with the name exists on the server
+func (dr *DatabaseRepository) DatabaseExists(name string) (bool, error) {
+	var count int
+
+	err := dr.connection.QueryRow("SELECT COUNT(*) FROM sys.databases WHERE name = @Name;", sql.Named("Name", name)).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// Gets the disk volumes of the server and their free space, from sys.dm_os_volume_stats. Only the volumes which have a database file are known,
+// since sys.dm_os_volume_stats is queried by the files of sys.master_files. It requires the VIEW SERVER STATE permission
+func (dr *DatabaseRepository) GetVolumes() ([]model.VolumeSpace, error) {
+	query := `SELECT DISTINCT vs.volume_mount_point, vs.total_bytes, vs.available_bytes
+		FROM sys.master_files AS mf
+		CROSS APPLY sys.dm_os_volume_stats(mf.database_id, mf.file_id) AS vs;`
+
+	rows, err := dr.connection.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var volumes []model.VolumeSpace
+	for rows.Next() {
+		var volume model.VolumeSpace
+		err = rows.Scan(&volume.MountPoint, &volume.TotalBytes, &volume.AvailableBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		volumes = append(volumes, volume)
+	}
+
+	return volumes, rows.Err()
+}
+
 // Gets the default data path and log path, set as a server property
 func (dr *DatabaseRepository) GetDefaultFilesPath() (string, string, error) {
 	var dataPath, logPath string
@@ -366,7 +940,7 @@ func (dr *DatabaseRepository) GetDefaultFilesPath() (string, string, error) {
 	return dataPath, logPath, nil
 }
 
-// Performs a RESTORE FILELISTONLY for each backup file. It gets all the information about the related database backup file.
+// Performs a RESTORE FILELISTONLY for each backup file (every stripe of the media set, when the backup is striped). It gets all the information about the related database backup file.
 // RESTORE FILELISTONLY is necessary because if RESTORE DATABASE is run without setting the name and location of the database files,
 // it will restore the database using the previous data. Therefore, if the database was previously located in /var/opt/mssql/,
 // even if the restore is being performed on a Windows server, it will attempt to restore the files in /var/opt/mssql/. Also, RESTORE DATABASE expects the original
@@ -378,7 +952,11 @@ func (dr *DatabaseRepository) GetBackupFilesData(restoreDbList []model.ToBeResto
 	var restoreDatabase model.DatabaseFromBackupFile
 
 	for _, db := range restoreDbList {
-		query := "RESTORE FILELISTONLY FROM DISK = @Path;"
+		mediaSet := db.MediaSet()
+		query := fmt.Sprintf("RESTORE FILELISTONLY FROM %s;", diskList(mediaSet))
+		var tdeThumbprint []byte
+		var uniqueID mssql.UniqueIdentifier
+		var logGroupGUID, differentialBaseGUID mssql.NullUniqueIdentifier
 
 		stmt, err := dr.connection.Prepare(query)
 		if err != nil {
@@ -386,7 +964,7 @@ func (dr *DatabaseRepository) GetBackupFilesData(restoreDbList []model.ToBeResto
 			return nil, err
 		}
 
-		rows, err := stmt.Query(sql.Named("Path", db.BackupPath))
+		rows, err := stmt.Query(diskArgs(mediaSet)...)
 		if err != nil {
 			slog.Error("Error executing RESTORE FILELISTONLY query: ", "Query: ", query, "Error: ", err)
 			return nil, err
@@ -395,15 +973,26 @@ func (dr *DatabaseRepository) GetBackupFilesData(restoreDbList []model.ToBeResto
 		for rows.Next() {
 			err = rows.Scan(&restoreDatabaseInfo.LogicalName, &restoreDatabaseInfo.PhysicalName, &restoreDatabaseInfo.FileType, &restoreDatabaseInfo.FileGroupName,
 				&restoreDatabaseInfo.Size, &restoreDatabaseInfo.MaxSize, &restoreDatabaseInfo.FileId, &restoreDatabaseInfo.CreateLSN, &restoreDatabaseInfo.DropLSN,
-				&restoreDatabaseInfo.UniqueId, &restoreDatabaseInfo.ReadOnlyLSN, &restoreDatabaseInfo.ReadWriteLSN, &restoreDatabaseInfo.BackupSizeInBytes,
-				&restoreDatabaseInfo.SourceBlockSize, &restoreDatabaseInfo.FileGroupId, &restoreDatabaseInfo.LogGroupGUID, &restoreDatabaseInfo.DifferentialBaseLSN,
-				&restoreDatabaseInfo.DifferentialBaseGUID, &restoreDatabaseInfo.IsReadOnly, &restoreDatabaseInfo.IsPresent, &restoreDatabaseInfo.TDEThumbprint,
+				&uniqueID, &restoreDatabaseInfo.ReadOnlyLSN, &restoreDatabaseInfo.ReadWriteLSN, &restoreDatabaseInfo.BackupSizeInBytes,
+				&restoreDatabaseInfo.SourceBlockSize, &restoreDatabaseInfo.FileGroupId, &logGroupGUID, &restoreDatabaseInfo.DifferentialBaseLSN,
+				&differentialBaseGUID, &restoreDatabaseInfo.IsReadOnly, &restoreDatabaseInfo.IsPresent, &tdeThumbprint,
 				&restoreDatabaseInfo.SnapshotUrl)
 			if err != nil {
 				return nil, err
 			}
+			// The uniqueidentifier columns are scanned by the driver, since their bytes are not in the order of the GUID text
+			restoreDatabaseInfo.UniqueId = uniqueID.String()
+			restoreDatabaseInfo.LogGroupGUID = nullGUID(logGroupGUID)
+			restoreDatabaseInfo.DifferentialBaseGUID = nullGUID(differentialBaseGUID)
+			// TDEThumbprint is a varbinary, reported in hexadecimal like the thumbprints of master.sys.certificates
+			restoreDatabaseInfo.TDEThumbprint = nil
+			if len(tdeThumbprint) > 0 {
+				thumbprint := strings.ToUpper(hex.EncodeToString(tdeThumbprint))
+				restoreDatabaseInfo.TDEThumbprint = &thumbprint
+			}
 			restoreDatabase.Name = db.Name
 			restoreDatabase.BackupFilePath = db.BackupPath
+			restoreDatabase.BackupFilePaths = mediaSet
 
 			restoreDatabase.BackupFileInfo = append(restoreDatabase.BackupFileInfo, restoreDatabaseInfo)
 
@@ -425,3 +1014,315 @@ func (dr *DatabaseRepository) GetBackupFilesData(restoreDbList []model.ToBeResto
 	return restoreDatabaseInfoList, nil
 
 }
+
+// Performs a RESTORE HEADERONLY of the backup file (every stripe of the media set, when the backup is striped). Returns one header for each backup set of the file.
+// The columns are read by name, since their number changes between SQL Server versions
+func (dr *DatabaseRepository) GetBackupHeaders(mediaSet []string) ([]model.BackupHeader, error) {
+	query := fmt.Sprintf("RESTORE HEADERONLY FROM %s;", diskList(mediaSet))
+
+	rows, err := dr.connection.Query(query, diskArgs(mediaSet)...)
+	if err != nil {
+		slog.Error("Error executing RESTORE HEADERONLY query: ", "Query: ", query, "Files: ", mediaSet, "Error: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var headers []model.BackupHeader
+	for rows.Next() {
+		row, err := scanColumns(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		header := model.BackupHeader{
+			BackupPath:          mediaSet[0],
+			Position:            int(columnInt(row["Position"])),
+			BackupName:          columnString(row["BackupName"]),
+			BackupDescription:   columnString(row["BackupDescription"]),
+			BackupType:          int(columnInt(row["BackupType"])),
+			DatabaseName:        columnString(row["DatabaseName"]),
+			ServerName:          columnString(row["ServerName"]),
+			RecoveryModel:       columnString(row["RecoveryModel"]),
+			FirstLSN:            columnString(row["FirstLSN"]),
+			LastLSN:             columnString(row["LastLSN"]),
+			CheckpointLSN:       columnString(row["CheckpointLSN"]),
+			DatabaseBackupLSN:   columnString(row["DatabaseBackupLSN"]),
+			DifferentialBaseLSN: columnString(row["DifferentialBaseLSN"]),
+			BackupStartDate:     columnTime(row["BackupStartDate"]),
+			BackupFinishDate:    columnTime(row["BackupFinishDate"]),
+			IsCopyOnly:          columnInt(row["IsCopyOnly"]) != 0,
+			HasBackupChecksums:  columnInt(row["HasBackupChecksums"]) != 0,
+			BackupSize:          columnInt(row["BackupSize"]),
+			KeyAlgorithm:        columnString(row["KeyAlgorithm"]),
+			EncryptorThumbprint: columnHex(row["EncryptorThumbprint"]),
+			EncryptorType:       columnString(row["EncryptorType"]),
+			BackupTypeName:      columnString(row["BackupTypeDescription"]),
+			SoftwareVersion:     fmt.Sprintf("%d.%d.%d", columnInt(row["SoftwareVersionMajor"]), columnInt(row["SoftwareVersionMinor"]), columnInt(row["SoftwareVersionBuild"])),
+			CompatibilityLevel:  int(columnInt(row["CompatibilityLevel"])),
+			Collation:           columnString(row["Collation"]),
+			CompressedSize:      columnInt(row["CompressedBackupSize"]),
+			IsDamaged:           columnInt(row["IsDamaged"]) != 0,
+		}
+		header.Encrypted = header.EncryptorThumbprint != ""
+		if len(mediaSet) > 1 {
+			header.BackupPaths = mediaSet
+		}
+
+		headers = append(headers, header)
+	}
+
+	return headers, rows.Err()
+}
+
+// Reads the media of the backup with RESTORE LABELONLY. For a striped backup, the label of the first stripe is returned
+func (dr *DatabaseRepository) GetBackupLabel(mediaSet []string) (model.BackupLabel, error) {
+	query := fmt.Sprintf("RESTORE LABELONLY FROM %s;", diskList(mediaSet))
+
+	rows, err := dr.connection.Query(query, diskArgs(mediaSet)...)
+	if err != nil {
+		slog.Error("Error executing RESTORE LABELONLY query: ", "Query: ", query, "Files: ", mediaSet, "Error: ", err)
+		return model.BackupLabel{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return model.BackupLabel{}, err
+		}
+		return model.BackupLabel{}, fmt.Errorf("RESTORE LABELONLY returned no media for %v", mediaSet[0])
+	}
+
+	row, err := scanColumns(rows)
+	if err != nil {
+		return model.BackupLabel{}, err
+	}
+
+	return model.BackupLabel{
+		MediaName:            columnString(row["MediaName"]),
+		MediaSetID:           columnGUID(row["MediaSetId"]),
+		FamilyCount:          int(columnInt(row["FamilyCount"])),
+		FamilySequenceNumber: int(columnInt(row["FamilySequenceNumber"])),
+		MediaFamilyID:        columnGUID(row["MediaFamilyId"]),
+		MediaDate:            columnTime(row["MediaDate"]),
+		SoftwareName:         columnString(row["SoftwareName"]),
+		MirrorCount:          int(columnInt(row["MirrorCount"])),
+		IsCompressed:         columnInt(row["IsCompressed"]) != 0,
+	}, nil
+}
+
+// Scans the current row into a map, by the column name
+func scanColumns(rows *sql.Rows) (map[string]any, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for key := range values {
+		pointers[key] = &values[key]
+	}
+
+	err = rows.Scan(pointers...)
+	if err != nil {
+		return nil, err
+	}
+
+	row := make(map[string]any, len(columns))
+	for key, column := range columns {
+		row[column] = values[key]
+	}
+
+	return row, nil
+}
+
+// Converts a scanned column to string. Numeric and decimal columns are returned by the driver as []byte
+func columnString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Converts a scanned binary column to an uppercase hexadecimal string, without the 0x prefix. Returns an empty string if the column is NULL
+func columnHex(value any) string {
+	if v, ok := value.([]byte); ok {
+		return strings.ToUpper(hex.EncodeToString(v))
+	}
+
+	return columnString(value)
+}
+
+// Converts a scanned uniqueidentifier column to the GUID text, like 6F9619FF-8B86-D011-B42D-00C04FC964FF. Returns an empty string if the column is NULL
+func columnGUID(value any) string {
+	var guid mssql.UniqueIdentifier
+	if guid.Scan(value) != nil {
+		return columnString(value)
+	}
+
+	return guid.String()
+}
+
+// Returns the GUID text of a nullable uniqueidentifier column, or nil if it is NULL
+func nullGUID(value mssql.NullUniqueIdentifier) *string {
+	if !value.Valid {
+		return nil
+	}
+
+	guid := value.UUID.String()
+	return &guid
+}
+
+// Converts a scanned column to int64. Returns 0 if the column is NULL or not a number
+func columnInt(value any) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case []byte, string:
+		parsed, err := strconv.ParseInt(strings.Split(columnString(v), ".")[0], 10, 64)
+		if err != nil {
+			return 0
+		}
+		return parsed
+	}
+
+	return 0
+}
+
+// Converts a scanned column to time.Time. Returns the zero time if the column is NULL
+func columnTime(value any) time.Time {
+	if v, ok := value.(time.Time); ok {
+		return v
+	}
+
+	return time.Time{}
+}
+
+// RestoreChainStatement returns the RESTORE statement of the step, as it is executed by RestoreChain. The backup files and the file moves are passed as parameters (@Path1, @LogicalName1, @MoveTo1, ...)
+func RestoreChainStatement(chain model.RestoreChain, step model.RestoreChainStep) string {
+	query, _ := restoreChainStatement(chain, step)
+	return query
+}
+
+// Builds the RESTORE statement of one step of the chain. The full backup moves the database files and overwrites the existing database with REPLACE, when the chain has it;
+// the log backup of the last step stops at the STOPAT time, if it is set
+func restoreChainStatement(chain model.RestoreChain, step model.RestoreChainStep) (string, []any) {
+	mediaSet := step.MediaSet()
+	args := diskArgs(mediaSet)
+
+	var query string
+	if step.Kind() == model.BackupLog {
+		query = fmt.Sprintf("RESTORE LOG [%s] FROM %s WITH FILE = %d, ", chain.TargetName, diskList(mediaSet), step.Position)
+	} else {
+		query = fmt.Sprintf("RESTORE DATABASE [%s] FROM %s WITH FILE = %d, ", chain.TargetName, diskList(mediaSet), step.Position)
+	}
+
+	if step.Kind() == model.BackupFull {
+		moves, moveArgs := moveClause(chain.Moves)
+		query += moves
+		args = append(args, moveArgs...)
+
+		if chain.Replace {
+			query += "REPLACE, "
+		}
+	}
+
+	if !step.Recovery {
+		return query + "NORECOVERY;", args
+	}
+
+	if step.Kind() == model.BackupLog && chain.StopAt != "" {
+		args = append(args, sql.Named("StopAt", chain.StopAt))
+		return query + "RECOVERY, STOPAT = @StopAt;", args
+	}
+
+	return query + "RECOVERY;", args
+}
+
+// Performs every RESTORE statement of the restore chain, in order and in the same session: the full backup, the differential and the log backups WITH NORECOVERY,
+// and the last one WITH RECOVERY. It is the executor of the point-in-time restore jobs: each statement is limited by the timeout and the observer is notified when the database starts and finishes.
+// If a step fails, the next ones are not executed and the database is left in the RESTORING state
+func (dr *DatabaseRepository) RestoreChain(ctx context.Context, chain model.RestoreChain, timeout time.Duration, observer OperationObserver) error {
+	t0 := time.Now()
+	if observer == nil {
+		observer = noopObserver{}
+	}
+
+	restoreLogFile, err := os.OpenFile("restore.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		slog.Error("Cannot open restore log file: ", "Error: ", err)
+	}
+	defer restoreLogFile.Close()
+
+	restoreLogger := slog.New(slog.NewJSONHandler(restoreLogFile, &slog.HandlerOptions{
+		AddSource: true,
+		Level:     slog.LevelInfo,
+	}))
+
+	ctx, cancelDatabase := observer.DatabaseContext(ctx, chain.TargetName)
+	defer cancelDatabase()
+
+	// The database was cancelled while it was queued
+	if err := ctx.Err(); err != nil {
+		observer.DatabaseFinished(chain.TargetName, err)
+		return err
+	}
+
+	conn, spid, err := dr.openSession(ctx)
+	if err != nil {
+		restoreLogger.Error("Error opening a session for the restore chain: ", "Database: ", chain.TargetName, "Error: ", err)
+		observer.DatabaseFinished(chain.TargetName, err)
+		return err
+	}
+	defer conn.Close()
+
+	var files []string
+	for _, step := range chain.Steps {
+		files = append(files, step.MediaSet()...)
+	}
+	observer.DatabaseStarted(chain.TargetName, spid, files)
+
+	for key, step := range chain.Steps {
+		err = dr.restoreChainStep(ctx, conn, spid, chain, step, timeout)
+		if err != nil {
+			restoreLogger.Error("Error executing the restore chain: ", "Database: ", chain.TargetName, "Step: ", key+1, "Files: ", step.MediaSet(), "Error: ", err)
+			err = fmt.Errorf("Step %d of %d (%v backup %v) failed. The database is left in the RESTORING state: %w", key+1, len(chain.Steps), step.Kind(), step.BackupPath, err)
+			observer.DatabaseFinished(chain.TargetName, err)
+			return err
+		}
+		restoreLogger.Info(fmt.Sprintf("Step %d of %d of the restore chain of [%v] database completed", key+1, len(chain.Steps), chain.TargetName), "Files:", step.MediaSet())
+	}
+
+	restoreLogger.Info(fmt.Sprintf("Restore chain related to [%v] database completed", chain.TargetName), "Database:", chain.TargetName, "StopAt:", chain.StopAt)
+	restoreLogger.Info(fmt.Sprintf("Total Time: %v", time.Since(t0)))
+	observer.DatabaseFinished(chain.TargetName, nil)
+
+	return nil
+}
+
+// Executes one RESTORE statement of the restore chain, limited by the timeout
+func (dr *DatabaseRepository) restoreChainStep(ctx context.Context, conn *sql.Conn, spid int, chain model.RestoreChain, step model.RestoreChainStep, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	query, args := restoreChainStatement(chain, step)
+
+	stmt, err := conn.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	return dr.execOrKill(ctx, stmt, spid, args...)
+}
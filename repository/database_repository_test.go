@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+)
+
+func TestBackupStatementCredential(t *testing.T) {
+	tests := []struct {
+		name       string
+		credential string
+		want       string
+	}{
+		{name: "without credential", credential: "", want: "BACKUP DATABASE [sales] TO URL = @Path1"},
+		{name: "credential", credential: "storage_account", want: "BACKUP DATABASE [sales] TO URL = @Path1 WITH CREDENTIAL = [storage_account]"},
+		{name: "credential with a closing bracket", credential: "account]; DROP DATABASE sales; --", want: "BACKUP DATABASE [sales] TO URL = @Path1 WITH CREDENTIAL = [account]]; DROP DATABASE sales; --]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := backupStatement("sales", model.BackupFull, []string{"https://account.blob.core.windows.net/backups/sales.bak"}, model.BackupTarget{Type: model.StorageURL, Credential: tt.credential}, model.BackupOptions{})
+
+			if query != tt.want {
+				t.Errorf("backupStatement() = %v, want %v", query, tt.want)
+			}
+			// CREDENTIAL does not accept a variable, so it must not be sent as a parameter
+			for _, arg := range args {
+				if named, ok := arg.(sql.NamedArg); ok && strings.EqualFold(named.Name, "Credential") {
+					t.Errorf("backupStatement() sends the credential as the parameter @%v", named.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreChainStatementReplace(t *testing.T) {
+	full := model.RestoreChainStep{BackupHeader: model.BackupHeader{BackupPath: "/backups/sales.bak", Position: 1, BackupType: 1}}
+	log := model.RestoreChainStep{BackupHeader: model.BackupHeader{BackupPath: "/backups/sales.trn", Position: 1, BackupType: 2}, Recovery: true}
+	moves := []model.FileMove{{LogicalName: "sales", To: "/data/sales.mdf"}}
+
+	tests := []struct {
+		name    string
+		replace bool
+		step    model.RestoreChainStep
+		want    string
+	}{
+		{name: "full backup", replace: false, step: full, want: "RESTORE DATABASE [sales] FROM DISK = @Path1 WITH FILE = 1, MOVE @LogicalName1 TO @MoveTo1, NORECOVERY;"},
+		{name: "full backup with replace", replace: true, step: full, want: "RESTORE DATABASE [sales] FROM DISK = @Path1 WITH FILE = 1, MOVE @LogicalName1 TO @MoveTo1, REPLACE, NORECOVERY;"},
+		{name: "log backup with replace", replace: true, step: log, want: "RESTORE LOG [sales] FROM DISK = @Path1 WITH FILE = 1, RECOVERY;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := model.RestoreChain{Database: "sales", TargetName: "sales", Moves: moves, Replace: tt.replace}
+
+			if got := RestoreChainStatement(chain, tt.step); got != tt.want {
+				t.Errorf("RestoreChainStatement() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
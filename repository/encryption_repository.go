@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+)
+
+// ErrMasterKeyNotFound is returned when the master database has no master key, which encrypts the private keys of its certificates
+var ErrMasterKeyNotFound = errors.New("The master database has no master key. Create it with CREATE MASTER KEY ENCRYPTION BY PASSWORD = ...")
+
+// Gets the certificates and asymmetric keys of the master database (master.sys.certificates and master.sys.asymmetric_keys) which can encrypt the backups,
+// by type and name. The system ones (##MS_...##) are left out. The thumbprints are returned in hexadecimal, like RESTORE HEADERONLY reports them
+func (dr *DatabaseRepository) GetEncryptors() ([]model.BackupEncryptor, error) {
+	query := `SELECT name, 'certificate', CONVERT(varchar(66), thumbprint, 2), subject, expiry_date, pvt_key_encryption_type_desc, pvt_key_last_backup_date
+			FROM master.sys.certificates
+			WHERE name NOT LIKE '##%'
+		UNION ALL
+		SELECT name, 'asymmetric_key', CONVERT(varchar(66), thumbprint, 2), NULL, NULL, pvt_key_encryption_type_desc, NULL
+			FROM master.sys.asymmetric_keys
+			WHERE name NOT LIKE '##%'
+		ORDER BY 2, 1;`
+
+	rows, err := dr.connection.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	encryptors := []model.BackupEncryptor{}
+	for rows.Next() {
+		var encryptor model.BackupEncryptor
+		var subject sql.NullString
+		var expiryDate, lastBackup sql.NullTime
+		err = rows.Scan(&encryptor.Name, &encryptor.Type, &encryptor.Thumbprint, &subject, &expiryDate, &encryptor.PrivateKeyEncryption, &lastBackup)
+		if err != nil {
+			return nil, err
+		}
+
+		encryptor.Subject = subject.String
+		if expiryDate.Valid {
+			encryptor.ExpiryDate = &expiryDate.Time
+		}
+		if lastBackup.Valid {
+			encryptor.PrivateKeyLastBackup = &lastBackup.Time
+		}
+		encryptors = append(encryptors, encryptor)
+	}
+
+	return encryptors, rows.Err()
+}
+
+// Creates the certificate in the master database from the backup of the certificate and of its private key (CREATE CERTIFICATE ... FROM FILE ... WITH PRIVATE KEY),
+// like the certificate of a database encrypted with TDE. The private key is encrypted by the master key of master, so ErrMasterKeyNotFound is returned when it does not exist
+func (dr *DatabaseRepository) CreateCertificate(certificate model.TDECertificate) error {
+	query := `IF NOT EXISTS (SELECT 1 FROM master.sys.symmetric_keys WHERE name = '##MS_DatabaseMasterKey##')
+		BEGIN
+			SELECT CAST(0 AS bit);
+			RETURN;
+		END
+		DECLARE @Statement nvarchar(max) = N'CREATE CERTIFICATE ' + QUOTENAME(@Name)
+			+ N' FROM FILE = N''' + REPLACE(@CertificateFile, N'''', N'''''') + N''''
+			+ N' WITH PRIVATE KEY (FILE = N''' + REPLACE(@PrivateKeyFile, N'''', N'''''') + N''''
+			+ N', DECRYPTION BY PASSWORD = N''' + REPLACE(@Password, N'''', N'''''') + N''');';
+		EXEC master.sys.sp_executesql @Statement;
+		SELECT CAST(1 AS bit);`
+
+	var created bool
+	err := dr.connection.QueryRow(query, sql.Named("Name", certificate.Name), sql.Named("CertificateFile", certificate.CertificateFile),
+		sql.Named("PrivateKeyFile", certificate.PrivateKeyFile), sql.Named("Password", certificate.PrivateKeyPassword)).Scan(&created)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return ErrMasterKeyNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/config"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+)
+
+// ErrShipNotConfigured is returned when the keys to ship the backup files to the destination are not set (storage.* configuration)
+var ErrShipNotConfigured = errors.New("The keys to ship the backup files are not set")
+
+// ErrShipVerification is returned when the copy of a backup file does not have the size or the SHA-256 checksum of the source
+var ErrShipVerification = errors.New("The shipped file does not match the backup file")
+
+// ErrShipSourceUnreadable is returned when a directory the backup files are written to cannot be read by MaestroSQL, which reads the files to ship them
+var ErrShipSourceUnreadable = errors.New("The backup path cannot be read by MaestroSQL")
+
+// Struct responsible for shipping the backup files to a secondary location after the backup: a directory of MaestroSQL host (like a SMB or NFS mount),
+// an SFTP server or a S3-compatible bucket. The backup files are read by MaestroSQL, so the backup path must be reachable by it at the same path
+type FileShipper struct {
+	cfg     config.StorageConfig
+	storage ObjectStorage
+}
+
+// Creates an instance of FileShipper struct
+func NewFileShipper(cfg config.StorageConfig) FileShipper {
+	return FileShipper{cfg: cfg, storage: NewObjectStorage(cfg)}
+}
+
+// Checks if the destination can be shipped to, before the backup starts: the directory of a mount must exist, and the keys of the SFTP and S3 destinations must be set.
+// The servers are not contacted
+func (fs FileShipper) Check(destination model.ShipDestination) error {
+	switch destination.Type {
+	case model.ShipMount:
+		info, err := os.Stat(destination.Path)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%v is not a directory", destination.Path)
+		}
+	case model.ShipSFTP:
+		if fs.cfg.SFTP.KnownHostsPath == "" {
+			return fmt.Errorf("%w: storage.sftp.knownHostsPath", ErrShipNotConfigured)
+		}
+		if fs.cfg.SFTP.Password == "" && fs.cfg.SFTP.PrivateKeyPath == "" {
+			return fmt.Errorf("%w: storage.sftp.password or storage.sftp.privateKeyPath", ErrShipNotConfigured)
+		}
+	case model.ShipS3:
+		if fs.cfg.S3.AccessKey == "" || fs.cfg.S3.SecretKey == "" {
+			return fmt.Errorf("%w: storage.s3.accessKey and storage.s3.secretKey", ErrShipNotConfigured)
+		}
+	}
+
+	return nil
+}
+
+// Checks if the backup files written to the directories can be read to be shipped, before the backup starts. SQL Server writes the files on its own host,
+// so each directory must be reachable by MaestroSQL at the same path: a folder of the same host, or the same share mounted at the same path
+func (fs FileShipper) CheckSource(directories []string) error {
+	for _, directory := range directories {
+		dir, err := os.Open(directory)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrShipSourceUnreadable, err)
+		}
+
+		info, err := dir.Stat()
+		if err == nil && !info.IsDir() {
+			err = fmt.Errorf("%v is not a directory", directory)
+		}
+		if err == nil {
+			_, err = dir.Readdirnames(1)
+			if err == io.EOF {
+				err = nil
+			}
+		}
+		dir.Close()
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrShipSourceUnreadable, err)
+		}
+	}
+
+	return nil
+}
+
+// Ships the backup file to the destination, with the same file name, and verifies the copy: its size and SHA-256 checksum must match the ones of the source.
+// The copy is read back for mounts and SFTP servers; the S3 endpoints check the SHA-256 of each uploaded part themselves
+func (fs FileShipper) Ship(ctx context.Context, source string, destination model.ShipDestination) (model.ShippedFile, error) {
+	file, err := os.Open(source)
+	if err != nil {
+		return model.ShippedFile{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return model.ShippedFile{}, err
+	}
+
+	shipped := model.ShippedFile{Source: source, Size: info.Size()}
+	name := shipFileName(source)
+
+	switch destination.Type {
+	case model.ShipMount:
+		shipped.Destination = filepath.Join(destination.Path, name)
+		shipped.SHA256, err = copyToMount(ctx, file, shipped.Destination, info.Size())
+	case model.ShipSFTP:
+		var remotePath string
+		remotePath, shipped.SHA256, err = fs.copyToSFTP(ctx, file, destination, name, info.Size())
+		shipped.Destination = fmt.Sprintf("sftp://%s@%s/%s", destination.User, destination.Host, strings.TrimPrefix(remotePath, "/"))
+	case model.ShipS3:
+		var key string
+		key, shipped.SHA256, err = fs.storage.PutFile(ctx, destination.Location, name, file, info.Size())
+		shipped.Destination = fmt.Sprintf("s3://%s/%s/%s", destination.Location.Host, destination.Location.Bucket, key)
+	default:
+		err = fmt.Errorf("Unknown destination type %q", destination.Type)
+	}
+	if err != nil {
+		return model.ShippedFile{}, err
+	}
+
+	return shipped, nil
+}
+
+// Copies the file to the path, hashing it while it is written, then reads the copy back to check its size and SHA-256 checksum. Returns the checksum
+func copyToMount(ctx context.Context, file *os.File, path string, size int64) (string, error) {
+	destination, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer destination.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(destination, io.TeeReader(&contextReader{ctx: ctx, reader: file}, hash))
+	if err != nil {
+		return "", err
+	}
+	err = destination.Sync()
+	if err != nil {
+		return "", err
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	copied, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer copied.Close()
+
+	return checksum, verifyCopy(ctx, copied, size, checksum)
+}
+
+// Reads the copy to check its size and SHA-256 checksum against the ones of the source
+func verifyCopy(ctx context.Context, copied io.Reader, size int64, checksum string) error {
+	hash := sha256.New()
+	copiedSize, err := io.Copy(hash, &contextReader{ctx: ctx, reader: copied})
+	if err != nil {
+		return err
+	}
+
+	if copiedSize != size {
+		return fmt.Errorf("%w: the copy has %v bytes and the backup file %v", ErrShipVerification, copiedSize, size)
+	}
+	if copiedChecksum := hex.EncodeToString(hash.Sum(nil)); copiedChecksum != checksum {
+		return fmt.Errorf("%w: the copy has the SHA-256 %v and the backup file %v", ErrShipVerification, copiedChecksum, checksum)
+	}
+
+	return nil
+}
+
+// The file name of the backup file. The backup path may be a Windows path, even when MaestroSQL runs on Linux
+func shipFileName(source string) string {
+	return source[strings.LastIndexAny(source, `/\`)+1:]
+}
+
+// contextReader stops reading when the context is cancelled, so a cancelled job stops the copy of a large backup file
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.reader.Read(p)
+}
@@ -0,0 +1,349 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+)
+
+// The columns of msdb.dbo.backupset read into a model.BackupHistoryEntry by queryBackupHistory
+const backupHistoryColumns = `bs.backup_set_id, bs.database_name, bs.type, ISNULL(bs.name, ''), ISNULL(bs.server_name, ''), ISNULL(bs.user_name, ''), ISNULL(bs.recovery_model, ''),
+	bs.backup_start_date, bs.backup_finish_date, CAST(bs.backup_size AS bigint), CAST(ISNULL(bs.compressed_backup_size, bs.backup_size) AS bigint),
+	CAST(bs.first_lsn AS varchar(25)), CAST(bs.last_lsn AS varchar(25)), CAST(bs.checkpoint_lsn AS varchar(25)), ISNULL(CAST(bs.database_backup_lsn AS varchar(25)), ''),
+	bs.is_copy_only, bs.media_set_id`
+
+// Builds the WHERE clause of the backup history queries. Only database, differential and log backups are read, filtered by the finish date
+func backupHistoryWhere(filter model.HistoryFilter, byType bool, byDate bool) (string, []any) {
+	conditions := []string{"bs.type IN ('D', 'I', 'L')"}
+	var args []any
+
+	if filter.Database != "" {
+		conditions = append(conditions, "bs.database_name = @Database")
+		args = append(args, sql.Named("Database", filter.Database))
+	}
+	if byType && filter.BackupType != "" {
+		conditions = append(conditions, "bs.type = @Type")
+		args = append(args, sql.Named("Type", filter.BackupType.HistoryTypeCode()))
+	}
+	if byDate && filter.From != nil {
+		conditions = append(conditions, "bs.backup_finish_date >= @From")
+		args = append(args, sql.Named("From", *filter.From))
+	}
+	if byDate && filter.To != nil {
+		conditions = append(conditions, "bs.backup_finish_date <= @To")
+		args = append(args, sql.Named("To", *filter.To))
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// Gets one page of the backup history of msdb.dbo.backupset, from the newest to the oldest finish date, and the total of backup sets of the filter.
+// The devices of each backup set are read from msdb.dbo.backupmediafamily
+func (dr *DatabaseRepository) GetBackupHistory(filter model.HistoryFilter) ([]model.BackupHistoryEntry, int64, error) {
+	where, args := backupHistoryWhere(filter, true, true)
+
+	var total int64
+	err := dr.connection.QueryRow("SELECT COUNT(*) FROM msdb.dbo.backupset AS bs "+where+";", args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM msdb.dbo.backupset AS bs %s
+		ORDER BY bs.backup_finish_date DESC, bs.backup_set_id DESC
+		OFFSET @Offset ROWS FETCH NEXT @PageSize ROWS ONLY;`, backupHistoryColumns, where)
+	args = append(args, sql.Named("Offset", (filter.Page-1)*filter.PageSize), sql.Named("PageSize", filter.PageSize))
+
+	entries, err := dr.queryBackupHistory(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
+
+// Gets the last full, differential and log backup of each database of msdb.dbo.backupset, by their finish date. Copy-only backups are included.
+// Only the database of the filter is read, when it is set; the type and the dates of the filter are ignored
+func (dr *DatabaseRepository) GetLastBackups(filter model.HistoryFilter) ([]model.LastBackups, error) {
+	where, args := backupHistoryWhere(filter, false, false)
+
+	query := fmt.Sprintf(`SELECT %s FROM msdb.dbo.backupset AS bs
+		WHERE bs.backup_set_id IN (
+			SELECT latest.backup_set_id FROM (
+				SELECT bs.backup_set_id, ROW_NUMBER() OVER (PARTITION BY bs.database_name, bs.type ORDER BY bs.backup_finish_date DESC, bs.backup_set_id DESC) AS latest_order
+				FROM msdb.dbo.backupset AS bs %s
+			) AS latest WHERE latest.latest_order = 1
+		)
+		ORDER BY bs.database_name;`, backupHistoryColumns, where)
+
+	entries, err := dr.queryBackupHistory(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastBackups []model.LastBackups
+	for key := range entries {
+		entry := &entries[key]
+		if len(lastBackups) == 0 || lastBackups[len(lastBackups)-1].Database != entry.Database {
+			lastBackups = append(lastBackups, model.LastBackups{Database: entry.Database})
+		}
+
+		last := &lastBackups[len(lastBackups)-1]
+		switch entry.BackupType {
+		case model.BackupFull:
+			last.Full = entry
+		case model.BackupDifferential:
+			last.Differential = entry
+		case model.BackupLog:
+			last.Log = entry
+		}
+	}
+
+	return lastBackups, nil
+}
+
+// Gets the full backups of msdb.dbo.backupset which finished since the date, copy-only ones included, from the newest to the oldest finish date.
+// The devices of each backup set are read from msdb.dbo.backupmediafamily
+func (dr *DatabaseRepository) GetFullBackups(since time.Time) ([]model.BackupHistoryEntry, error) {
+	query := fmt.Sprintf(`SELECT %s FROM msdb.dbo.backupset AS bs
+		WHERE bs.type = 'D' AND bs.backup_finish_date >= @From
+		ORDER BY bs.backup_finish_date DESC, bs.backup_set_id DESC;`, backupHistoryColumns)
+
+	return dr.queryBackupHistory(query, sql.Named("From", since))
+}
+
+// Reads the backup sets of the query, which selects the backupHistoryColumns, and their devices
+func (dr *DatabaseRepository) queryBackupHistory(query string, args ...any) ([]model.BackupHistoryEntry, error) {
+	rows, err := dr.connection.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []model.BackupHistoryEntry{}
+	var mediaSetIDs []int64
+	for rows.Next() {
+		var entry model.BackupHistoryEntry
+		var typeCode string
+		var mediaSetID int64
+
+		err = rows.Scan(&entry.BackupSetID, &entry.Database, &typeCode, &entry.Name, &entry.ServerName, &entry.UserName, &entry.RecoveryModel,
+			&entry.StartDate, &entry.FinishDate, &entry.BackupSize, &entry.CompressedSize,
+			&entry.FirstLSN, &entry.LastLSN, &entry.CheckpointLSN, &entry.DatabaseBackupLSN,
+			&entry.IsCopyOnly, &mediaSetID)
+		if err != nil {
+			return nil, err
+		}
+		entry.BackupType = model.BackupTypeFromHistory(typeCode)
+		entry.DurationSeconds = int64(entry.FinishDate.Sub(entry.StartDate) / time.Second)
+
+		entries = append(entries, entry)
+		mediaSetIDs = append(mediaSetIDs, mediaSetID)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := dr.getBackupDevices(mediaSetIDs)
+	if err != nil {
+		return nil, err
+	}
+	for key := range entries {
+		entries[key].Devices = append([]string{}, devices[mediaSetIDs[key]]...)
+	}
+
+	return entries, nil
+}
+
+// Gets the physical device names (the backup files) of the media sets, from msdb.dbo.backupmediafamily, ordered by their family (stripe) and mirror.
+// The media sets are read in batches, since a query accepts up to 2100 parameters
+func (dr *DatabaseRepository) getBackupDevices(mediaSetIDs []int64) (map[int64][]string, error) {
+	const batchSize = 1000
+
+	devices := make(map[int64][]string, len(mediaSetIDs))
+
+	var pending []int64
+	for _, mediaSetID := range mediaSetIDs {
+		if _, ok := devices[mediaSetID]; !ok {
+			devices[mediaSetID] = nil
+			pending = append(pending, mediaSetID)
+		}
+	}
+
+	for start := 0; start < len(pending); start += batchSize {
+		batch := pending[start:min(start+batchSize, len(pending))]
+
+		params, args := idList("MediaSet", batch)
+		query := fmt.Sprintf(`SELECT media_set_id, ISNULL(physical_device_name, '') FROM msdb.dbo.backupmediafamily
+			WHERE media_set_id IN (%s)
+			ORDER BY media_set_id, mirror, family_sequence_number;`, params)
+
+		err := dr.scanIDStrings(query, args, devices)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return devices, nil
+}
+
+// Builds the parameters of an IN list of IDs, like "@Name1, @Name2", and their values
+func idList(name string, ids []int64) (string, []any) {
+	params := make([]string, len(ids))
+	args := make([]any, len(ids))
+	for key, id := range ids {
+		params[key] = fmt.Sprintf("@%s%d", name, key+1)
+		args[key] = sql.Named(fmt.Sprintf("%s%d", name, key+1), id)
+	}
+
+	return strings.Join(params, ", "), args
+}
+
+// Appends the rows of the query, an ID and a string, to the list of their ID. Used for the devices of a media set and the files of a restore
+func (dr *DatabaseRepository) scanIDStrings(query string, args []any, values map[int64][]string) error {
+	rows, err := dr.connection.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var value string
+
+		err = rows.Scan(&id, &value)
+		if err != nil {
+			return err
+		}
+
+		values[id] = append(values[id], value)
+	}
+
+	return rows.Err()
+}
+
+// Builds the WHERE clause of the restore history query. The restores are filtered by their date
+func restoreHistoryWhere(filter model.HistoryFilter) (string, []any) {
+	var conditions []string
+	var args []any
+
+	if filter.Database != "" {
+		conditions = append(conditions, "rh.destination_database_name = @Database")
+		args = append(args, sql.Named("Database", filter.Database))
+	}
+	if filter.UserName != "" {
+		conditions = append(conditions, "rh.user_name = @UserName")
+		args = append(args, sql.Named("UserName", filter.UserName))
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "rh.restore_date >= @From")
+		args = append(args, sql.Named("From", *filter.From))
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "rh.restore_date <= @To")
+		args = append(args, sql.Named("To", *filter.To))
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// Gets one page of the restore history of msdb.dbo.restorehistory, from the newest to the oldest restore date, and the total of restores of the filter.
+// The files of each restore are read from msdb.dbo.restorefile, and the restored backup sets from msdb.dbo.backupset
+func (dr *DatabaseRepository) GetRestoreHistory(filter model.HistoryFilter) ([]model.RestoreHistoryEntry, int64, error) {
+	where, args := restoreHistoryWhere(filter)
+
+	var total int64
+	err := dr.connection.QueryRow("SELECT COUNT(*) FROM msdb.dbo.restorehistory AS rh "+where+";", args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`SELECT rh.restore_history_id, rh.destination_database_name, rh.restore_date, ISNULL(rh.user_name, ''), ISNULL(rh.restore_type, ''),
+		ISNULL(rh.[replace], 0), ISNULL(rh.recovery, 0), ISNULL(rh.restart, 0), rh.stop_at, ISNULL(rh.stop_at_mark_name, ''), ISNULL(rh.stop_before, 0), rh.backup_set_id
+		FROM msdb.dbo.restorehistory AS rh %s
+		ORDER BY rh.restore_date DESC, rh.restore_history_id DESC
+		OFFSET @Offset ROWS FETCH NEXT @PageSize ROWS ONLY;`, where)
+	args = append(args, sql.Named("Offset", (filter.Page-1)*filter.PageSize), sql.Named("PageSize", filter.PageSize))
+
+	rows, err := dr.connection.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	restores := []model.RestoreHistoryEntry{}
+	var restoreIDs, backupSetIDs []int64
+	for rows.Next() {
+		var restore model.RestoreHistoryEntry
+		var typeCode string
+		var stopAt sql.NullTime
+		var backupSetID int64
+
+		err = rows.Scan(&restore.RestoreHistoryID, &restore.Database, &restore.RestoreDate, &restore.UserName, &typeCode,
+			&restore.Replace, &restore.Recovery, &restore.Restart, &stopAt, &restore.StopAtMarkName, &restore.StopBefore, &backupSetID)
+		if err != nil {
+			return nil, 0, err
+		}
+		restore.RestoreType = model.RestoreTypeFromHistory(typeCode)
+		if stopAt.Valid {
+			restore.StopAt = &stopAt.Time
+		}
+
+		restores = append(restores, restore)
+		restoreIDs = append(restoreIDs, restore.RestoreHistoryID)
+		backupSetIDs = append(backupSetIDs, backupSetID)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(restores) == 0 {
+		return restores, total, nil
+	}
+
+	// A page has up to model.MaxHistoryPageSize restores, so their IDs fit in a single IN list
+	files := make(map[int64][]string, len(restoreIDs))
+	params, fileArgs := idList("Restore", restoreIDs)
+	err = dr.scanIDStrings(fmt.Sprintf(`SELECT restore_history_id, ISNULL(destination_phys_name, '') FROM msdb.dbo.restorefile
+		WHERE restore_history_id IN (%s)
+		ORDER BY restore_history_id, file_number;`, params), fileArgs, files)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	params, backupArgs := idList("BackupSet", backupSetIDs)
+	backups, err := dr.queryBackupHistory(fmt.Sprintf("SELECT %s FROM msdb.dbo.backupset AS bs WHERE bs.backup_set_id IN (%s);", backupHistoryColumns, params), backupArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for key := range restores {
+		restores[key].Files = append([]string{}, files[restores[key].RestoreHistoryID]...)
+		for backupKey := range backups {
+			if backups[backupKey].BackupSetID == backupSetIDs[key] {
+				restores[key].Backup = &backups[backupKey]
+			}
+		}
+	}
+
+	return restores, total, nil
+}
+
+// Gets the current local time of the server, which is the time zone of the msdb history dates
+func (dr *DatabaseRepository) GetServerTime() (time.Time, error) {
+	var serverTime time.Time
+
+	err := dr.connection.QueryRow("SELECT SYSDATETIME();").Scan(&serverTime)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return serverTime, nil
+}
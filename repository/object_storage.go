@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/config"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// ErrObjectStorageNotConfigured is returned when the keys to list the objects of the provider are not set (storage.* configuration)
+var ErrObjectStorageNotConfigured = errors.New("The keys to list the object storage are not set")
+
+// The size of the parts of the S3 multipart uploads. S3 accepts up to 10000 parts, so a backup file can have up to 640 GB
+const s3PartSize = 64 << 20
+
+// Struct responsible for listing the backups of the object storages: the S3-compatible endpoints through the minio-go client, which also uploads the shipped
+// backup files, and Azure Blob Storage through its REST API (List Blobs), with a shared access signature. Requires the storage configuration.
+// The uploads have no timeout, since they are limited by the context of the job
+type ObjectStorage struct {
+	cfg    config.StorageConfig
+	client *http.Client
+}
+
+// Creates an instance of ObjectStorage struct
+func NewObjectStorage(cfg config.StorageConfig) ObjectStorage {
+	return ObjectStorage{cfg: cfg, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Lists the names of the objects of the folder of the location (its prefix), like the files of a directory: the objects of the sub folders are not listed
+func (st ObjectStorage) ListObjects(location model.ObjectLocation) ([]string, error) {
+	prefix := location.Prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var names []string
+	var err error
+	if location.Provider == model.ProviderS3 {
+		names, err = st.listS3(location, prefix)
+	} else {
+		names, err = st.listAzure(location, prefix)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, name := range names {
+		names[i] = strings.TrimPrefix(name, prefix)
+	}
+	slices.Sort(names)
+
+	return names, nil
+}
+
+// Lists the objects of the S3 bucket with the minio-go client (ListObjectsV2, following the continuation tokens). The folders of the prefix are not listed
+func (st ObjectStorage) listS3(location model.ObjectLocation, prefix string) ([]string, error) {
+	client, err := st.s3Client(location)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), st.client.Timeout)
+	defer cancel()
+
+	var names []string
+	for object := range client.ListObjects(ctx, location.Bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("Cannot list the objects of %v: %w", location.Bucket, object.Err)
+		}
+		if strings.HasSuffix(object.Key, "/") {
+			continue
+		}
+		names = append(names, object.Key)
+	}
+
+	return names, nil
+}
+
+// Creates the minio-go client of the S3-compatible endpoint of the location, through path-style URLs (host/bucket), with the keys of the storage.s3 configuration
+func (st ObjectStorage) s3Client(location model.ObjectLocation) (*minio.Client, error) {
+	if st.cfg.S3.AccessKey == "" || st.cfg.S3.SecretKey == "" {
+		return nil, fmt.Errorf("%w: storage.s3.accessKey and storage.s3.secretKey", ErrObjectStorageNotConfigured)
+	}
+
+	return minio.New(location.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(st.cfg.S3.AccessKey, st.cfg.S3.SecretKey, ""),
+		Secure:       !st.cfg.S3.PlainHTTP,
+		Region:       st.cfg.S3.Region,
+		BucketLookup: minio.BucketLookupPath,
+	})
+}
+
+// Uploads the file to the S3 folder of the location, named name, with the minio-go client: a multipart upload in parts of 64 MB, where the endpoint checks the checksum
+// of each part, and which is aborted when it fails. The size of the completed object is checked with HEAD. Returns the key of the object and the SHA-256 of the file
+func (st ObjectStorage) PutFile(ctx context.Context, location model.ObjectLocation, name string, file io.Reader, size int64) (string, string, error) {
+	client, err := st.s3Client(location)
+	if err != nil {
+		return "", "", err
+	}
+
+	key := name
+	if location.Prefix != "" {
+		key = location.Prefix + "/" + name
+	}
+
+	hash := sha256.New()
+	_, err = client.PutObject(ctx, location.Bucket, key, io.TeeReader(file, hash), size, minio.PutObjectOptions{PartSize: s3PartSize, AutoChecksum: minio.ChecksumSHA256})
+	if err != nil {
+		return "", "", fmt.Errorf("Cannot upload %v: %w", key, err)
+	}
+
+	object, err := client.StatObject(ctx, location.Bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("Cannot check the uploaded object %v: %w", key, err)
+	}
+	if object.Size != size {
+		return "", "", fmt.Errorf("%w: the object %v has %v bytes and the backup file %v", ErrShipVerification, key, object.Size, size)
+	}
+
+	return key, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// The response of the Azure List Blobs request
+type azureListResult struct {
+	Blobs []struct {
+		Name string `xml:"Name"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// Lists the blobs of the Azure container with List Blobs, authorized by the SAS token, following the markers
+func (st ObjectStorage) listAzure(location model.ObjectLocation, prefix string) ([]string, error) {
+	if st.cfg.Azure.SASToken == "" {
+		return nil, fmt.Errorf("%w: storage.azure.sasToken", ErrObjectStorageNotConfigured)
+	}
+
+	var names []string
+	marker := ""
+	for {
+		query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}, "delimiter": {"/"}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+
+		request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://%s/%s?%s&%s", location.Host, url.PathEscape(location.Bucket), query.Encode(), st.cfg.Azure.SASToken), nil)
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("x-ms-version", "2021-08-06")
+
+		var result azureListResult
+		err = st.do(st.client, request, &result)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, blob := range result.Blobs {
+			names = append(names, blob.Name)
+		}
+
+		if result.NextMarker == "" {
+			return names, nil
+		}
+		marker = result.NextMarker
+	}
+}
+
+// Sends the request and decodes its XML response
+func (st ObjectStorage) do(client *http.Client, request *http.Request, result any) error {
+	body, err := st.send(client, request)
+	if err != nil {
+		return err
+	}
+
+	err = xml.Unmarshal(body, result)
+	if err != nil {
+		return fmt.Errorf("Cannot read the object storage response: %w", err)
+	}
+
+	return nil
+}
+
+// Sends the request and reads its response. The errors of both providers are XML documents with their code and message
+func (st ObjectStorage) send(client *http.Client, request *http.Request) ([]byte, error) {
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 32<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode >= 300 {
+		var storageErr struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		if xml.Unmarshal(body, &storageErr) == nil && storageErr.Code != "" {
+			return nil, fmt.Errorf("The object storage returned %v: %v %v", response.Status, storageErr.Code, storageErr.Message)
+		}
+		return nil, fmt.Errorf("The object storage returned %v", response.Status)
+	}
+
+	return body, nil
+}
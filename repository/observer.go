@@ -0,0 +1,24 @@
+package repository
+
+import "context"
+
+// OperationObserver is notified about each database while a backup or restore runs. The job engine uses it to follow the progress of a job.
+// DatabaseContext derives the context of one database from the operation context, so a single database can be cancelled.
+// DatabaseStarted receives the SQL Server session ID (SPID) which executes the statement of the database, and the backup files (one per stripe) used by it.
+// DatabaseVerified is called, before DatabaseFinished, when a successful backup is checked with RESTORE VERIFYONLY.
+type OperationObserver interface {
+	DatabaseContext(ctx context.Context, database string) (context.Context, context.CancelFunc)
+	DatabaseStarted(database string, sessionID int, files []string)
+	DatabaseVerified(database string, err error)
+	DatabaseFinished(database string, err error)
+}
+
+// Observer used when the caller does not need to follow the operation
+type noopObserver struct{}
+
+func (noopObserver) DatabaseContext(ctx context.Context, _ string) (context.Context, context.CancelFunc) {
+	return context.WithCancel(ctx)
+}
+func (noopObserver) DatabaseStarted(string, int, []string) {}
+func (noopObserver) DatabaseVerified(string, error)        {}
+func (noopObserver) DatabaseFinished(string, error)        {}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrOperationNotFound is returned when the operation is not in the operation history
+var ErrOperationNotFound = errors.New("Operation not found")
+
+var (
+	operationsBucket       = []byte("operations")         // The operations (model.Job as JSON), by their ID
+	operationsByDateBucket = []byte("operations_by_date") // The IDs of the operations, by their creation time followed by their ID, so they are iterated in creation order
+)
+
+// Struct responsible for the operation history: every backup, restore and verify job, kept in an embedded bbolt database file, so it survives restarts.
+// The file is locked while it is open, so it cannot be shared by two instances of the application
+type OperationStore struct {
+	db *bolt.DB
+}
+
+// Opens (or creates) the operation history file. It waits up to 5 seconds for the lock of the file
+func NewOperationStore(path string) (*OperationStore, error) {
+	boltDb, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = boltDb.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{operationsBucket, operationsByDateBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		boltDb.Close()
+		return nil, err
+	}
+
+	return &OperationStore{db: boltDb}, nil
+}
+
+// Closes the operation history file
+func (st *OperationStore) Close() error {
+	return st.db.Close()
+}
+
+// Saves the operation, replacing its previous state
+func (st *OperationStore) Save(job model.Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	return st.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(operationsBucket).Put([]byte(job.ID), data)
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(operationsByDateBucket).Put(dateKey(job), []byte(job.ID))
+	})
+}
+
+// Gets the operation by its ID
+func (st *OperationStore) Get(id string) (model.Job, error) {
+	var job model.Job
+
+	err := st.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(operationsBucket).Get([]byte(id))
+		if data == nil {
+			return ErrOperationNotFound
+		}
+
+		return json.Unmarshal(data, &job)
+	})
+
+	return job, err
+}
+
+// Lists the operations which match the filter, from the newest to the oldest, skipping the first ones and returning up to limit operations.
+// Also returns the total of operations which match the filter
+func (st *OperationStore) List(filter model.OperationFilter, skip int, limit int) ([]model.Job, int, error) {
+	jobs := []model.Job{}
+	total := 0
+
+	err := st.db.View(func(tx *bolt.Tx) error {
+		operations := tx.Bucket(operationsBucket)
+
+		cursor := tx.Bucket(operationsByDateBucket).Cursor()
+		for _, id := cursor.Last(); id != nil; _, id = cursor.Prev() {
+			data := operations.Get(id)
+			if data == nil {
+				continue
+			}
+
+			var job model.Job
+			err := json.Unmarshal(data, &job)
+			if err != nil {
+				return err
+			}
+			if !filter.Match(job) {
+				continue
+			}
+
+			total++
+			if total > skip && len(jobs) < limit {
+				jobs = append(jobs, job)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return jobs, total, nil
+}
+
+// Lists the operations which did not reach a final state, like the ones which were running when the application stopped
+func (st *OperationStore) Unfinished() ([]model.Job, error) {
+	var jobs []model.Job
+
+	err := st.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(operationsBucket).ForEach(func(_ []byte, data []byte) error {
+			var job model.Job
+			err := json.Unmarshal(data, &job)
+			if err != nil {
+				return err
+			}
+
+			if !job.Finished() {
+				jobs = append(jobs, job)
+			}
+			return nil
+		})
+	})
+
+	return jobs, err
+}
+
+// The key of the operation in operationsByDateBucket: its creation time in nanoseconds, big-endian, followed by its ID
+func dateKey(job model.Job) []byte {
+	key := make([]byte, 8, 8+len(job.ID))
+	binary.BigEndian.PutUint64(key, uint64(job.CreatedAt.UnixNano()))
+
+	return append(key, job.ID...)
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrScheduleNotFound is returned when the backup schedule does not exist
+var ErrScheduleNotFound = errors.New("Schedule not found")
+
+var schedulesBucket = []byte("schedules") // The backup schedules (model.Schedule as JSON), by their ID
+
+// Struct responsible for keeping the backup schedules. They are kept in the file of the operation history, since the file cannot be opened twice
+type ScheduleStore struct {
+	db *bolt.DB
+}
+
+// Creates an instance of ScheduleStore, in the file of the operation history
+func NewScheduleStore(operations *OperationStore) (*ScheduleStore, error) {
+	err := operations.db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(schedulesBucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ScheduleStore{db: operations.db}, nil
+}
+
+// Saves the schedule, replacing its previous state
+func (st *ScheduleStore) Save(schedule model.Schedule) error {
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return err
+	}
+
+	return st.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(schedulesBucket).Put([]byte(schedule.ID), data)
+	})
+}
+
+// Deletes the schedule
+func (st *ScheduleStore) Delete(id string) error {
+	return st.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(schedulesBucket)
+		if bucket.Get([]byte(id)) == nil {
+			return ErrScheduleNotFound
+		}
+
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// Lists every schedule, by their ID
+func (st *ScheduleStore) List() ([]model.Schedule, error) {
+	var schedules []model.Schedule
+
+	err := st.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(schedulesBucket).ForEach(func(_ []byte, data []byte) error {
+			var schedule model.Schedule
+			err := json.Unmarshal(data, &schedule)
+			if err != nil {
+				return err
+			}
+
+			schedules = append(schedules, schedule)
+			return nil
+		})
+	})
+
+	return schedules, err
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Copies the file to the directory of the SFTP destination, hashing it while it is written, then reads the copy back to check its size and SHA-256 checksum.
+// The host key of the server must be in the known_hosts file of the configuration. Returns the path of the copy and the checksum
+func (fs FileShipper) copyToSFTP(ctx context.Context, file io.Reader, destination model.ShipDestination, name string, size int64) (string, string, error) {
+	conn, err := fs.dialSFTP(ctx, destination)
+	if err != nil {
+		return "", "", err
+	}
+	defer conn.Close()
+
+	// Closing the connection stops the transfer when the job is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	// The writes and reads are pipelined, so the transfer is not limited by the latency of the server. A failed concurrent write may leave a hole in the copy,
+	// which the read back finds
+	client, err := sftp.NewClient(conn, sftp.UseConcurrentWrites(true))
+	if err != nil {
+		return "", "", fmt.Errorf("Cannot start the SFTP session: %w", err)
+	}
+	defer client.Close()
+
+	remotePath := path.Join(destination.Path, name)
+
+	remote, err := client.Create(remotePath)
+	if err != nil {
+		return "", "", fmt.Errorf("Cannot create %v in the SFTP server: %w", remotePath, err)
+	}
+	hash := sha256.New()
+	// The limited reader tells the client the size of the file, which it needs to write concurrently
+	_, err = remote.ReadFrom(io.LimitReader(io.TeeReader(file, hash), size))
+	closeErr := remote.Close()
+	if err = errors.Join(err, closeErr); err != nil {
+		return "", "", fmt.Errorf("Cannot write %v in the SFTP server: %w", remotePath, err)
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	copied, err := client.Open(remotePath)
+	if err != nil {
+		return "", "", fmt.Errorf("Cannot open %v in the SFTP server: %w", remotePath, err)
+	}
+	defer copied.Close()
+
+	info, err := copied.Stat()
+	if err != nil {
+		return "", "", err
+	}
+	if info.Size() != size {
+		return "", "", fmt.Errorf("%w: the copy has %v bytes and the backup file %v", ErrShipVerification, info.Size(), size)
+	}
+
+	copiedHash := sha256.New()
+	_, err = copied.WriteTo(copiedHash)
+	if err != nil {
+		return "", "", fmt.Errorf("Cannot read %v in the SFTP server: %w", remotePath, err)
+	}
+	if copiedChecksum := hex.EncodeToString(copiedHash.Sum(nil)); copiedChecksum != checksum {
+		return "", "", fmt.Errorf("%w: the copy has the SHA-256 %v and the backup file %v", ErrShipVerification, copiedChecksum, checksum)
+	}
+
+	return remotePath, checksum, nil
+}
+
+// Opens the SSH connection to the SFTP server, authenticated by the private key or the password of the configuration
+func (fs FileShipper) dialSFTP(ctx context.Context, destination model.ShipDestination) (*ssh.Client, error) {
+	err := fs.Check(destination)
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeyCallback, err := knownhosts.New(fs.cfg.SFTP.KnownHostsPath)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read the known_hosts file: %w", err)
+	}
+
+	var auth ssh.AuthMethod
+	if fs.cfg.SFTP.PrivateKeyPath != "" {
+		key, err := os.ReadFile(fs.cfg.SFTP.PrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read the private key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read the private key: %w", err)
+		}
+		auth = ssh.PublicKeys(signer)
+	} else {
+		auth = ssh.Password(fs.cfg.SFTP.Password)
+	}
+
+	dialer := ssh.ClientConfig{User: destination.User, Auth: []ssh.AuthMethod{auth}, HostKeyCallback: hostKeyCallback, Timeout: 30 * time.Second}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	conn, err := ssh.Dial("tcp", destination.Host, &dialer)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot connect to the SFTP server %v: %w", destination.Host, err)
+	}
+
+	return conn, nil
+}
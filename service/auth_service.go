@@ -9,13 +9,24 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/RenanMonteiroS/MaestroSQLWeb/db"
 	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
 )
 
-type AuthService struct{}
+type AuthService struct {
+	connections *db.ConnRegistry
+}
+
+func NewAuthService(connections *db.ConnRegistry) AuthService {
+	return AuthService{connections: connections}
+}
 
-func NewAuthService() AuthService {
-	return AuthService{}
+// Closes all database connections owned by the session. Called on logout, so the connection pools do not outlive the user session.
+func (as *AuthService) CloseSessionConnections(sessionID string) {
+	closed := as.connections.CloseSession(sessionID)
+	if closed > 0 {
+		slog.Info("Session connections closed", "Connections", closed)
+	}
 }
 
 func (as *AuthService) GenerateStateOAuthCookie() string {
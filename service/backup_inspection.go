@@ -0,0 +1,83 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/db"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+)
+
+// InspectBackupFile reads what the backup file has, without restoring it: its media (RESTORE LABELONLY), its backup sets (RESTORE HEADERONLY) and the database files
+// of the first backup set (RESTORE FILELISTONLY). The stripes of a striped backup are resolved like in the restore. The backup file must be readable by the server.
+// Only the headers are required: when the label or the files cannot be read, like the files of an encrypted backup whose encryptor is not in the server, they are reported in the warnings
+func (ds *DatabaseService) InspectBackupFile(key db.ConnKey, request model.BackupInspectPostRequired) (model.BackupInspection, error) {
+	var err error
+	if len(request.BackupPaths) == 0 {
+		request.BackupPaths, err = resolveStripeSet(request.BackupPath)
+		if err != nil {
+			slog.Error("Cannot find the stripes of the backup", "Path", request.BackupPath, "Error", err)
+			return model.BackupInspection{}, fmt.Errorf("%w: %w", ErrInvalidBackupFile, err)
+		}
+	}
+	for _, backupPath := range request.MediaSet() {
+		ok, err := regexp.MatchString(`^[a-zA-Z0-9._\-/\\\s:(){}\[\]@#$%^&+=~]+$`, backupPath)
+		if err != nil {
+			slog.Error("Cannot search string with regexp", "Error", err)
+			return model.BackupInspection{}, err
+		}
+		if !ok {
+			slog.Error("There is an invalid character in the backup path", "Path", backupPath)
+			return model.BackupInspection{}, fmt.Errorf("%w: there is an invalid character in the backup path %v", ErrInvalidBackupFile, backupPath)
+		}
+	}
+
+	rp, err := ds.getRepository(key)
+	if err != nil {
+		slog.Error("Cannot connect to database: ", "Error: ", err)
+		return model.BackupInspection{}, fmt.Errorf("Connection failed. Try to /connect.\nDetails: %v", err)
+	}
+
+	mediaSet := request.MediaSet()
+	inspection := model.BackupInspection{BackupPath: request.BackupPath, Files: []model.BackupDataFile{}}
+	if len(mediaSet) > 1 {
+		inspection.BackupPaths = mediaSet
+	}
+
+	inspection.Headers, err = rp.GetBackupHeaders(mediaSet)
+	if err != nil {
+		slog.Error("Cannot read the header of the backup (RESTORE HEADERONLY)", "Path", request.BackupPath, "Error", err)
+		return model.BackupInspection{}, err
+	}
+	if len(inspection.Headers) == 0 {
+		return model.BackupInspection{}, fmt.Errorf("%w: %v has no backup sets", ErrInvalidBackupFile, request.BackupPath)
+	}
+	inspection.DatabaseName = inspection.Headers[0].DatabaseName
+
+	label, err := rp.GetBackupLabel(mediaSet)
+	if err != nil {
+		slog.Warn("Cannot read the label of the backup (RESTORE LABELONLY)", "Path", request.BackupPath, "Error", err)
+		inspection.Warnings = append(inspection.Warnings, fmt.Sprintf("Cannot read the media of the backup (RESTORE LABELONLY): %v", err))
+	} else {
+		inspection.Label = &label
+	}
+
+	backupFilesData, err := rp.GetBackupFilesData([]model.ToBeRestoredDb{{Name: inspection.DatabaseName, BackupPath: request.BackupPath, BackupPaths: inspection.BackupPaths}})
+	if err != nil {
+		slog.Warn("Cannot get backup files data (RESTORE FILELISTONLY): ", "Path", request.BackupPath, "Error: ", err)
+		inspection.Warnings = append(inspection.Warnings, fmt.Sprintf("Cannot read the database files of the backup (RESTORE FILELISTONLY): %v", err))
+	}
+	for _, backupFileData := range backupFilesData {
+		inspection.Files = append(inspection.Files, backupFileData.BackupFileInfo...)
+	}
+
+	if len(inspection.Headers) > 1 {
+		inspection.Warnings = append(inspection.Warnings, fmt.Sprintf("The backup file has %v backup sets. The restore reads the first one", len(inspection.Headers)))
+	}
+	if err = checkRestoreEncryptors(rp, inspection.Headers[:1]); err != nil {
+		inspection.Warnings = append(inspection.Warnings, err.Error())
+	}
+
+	return inspection, nil
+}
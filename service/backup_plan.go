@@ -0,0 +1,108 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/db"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/repository"
+)
+
+// PlanBackup is the dry-run of BackupDatabase: it runs the same options validation and database checks, without executing the backup.
+// For each allowed database, it returns the BACKUP statement, the files it would write and the estimated backup size; the rejected databases are returned with their reason.
+// The estimated size of the files is compared with the free space of the volumes of the backup path and stripe directories (sys.dm_os_volume_stats).
+// For a backup URL, the credential of the URL is checked instead. The encryptor of an encrypted backup is checked like in the backup, and reported in the warnings
+func (ds *DatabaseService) PlanBackup(key db.ConnKey, backupDbList []model.Database, backupPath string, target model.BackupTarget, backupType model.BackupType, options model.BackupOptions) (model.BackupPlan, error) {
+	err := options.Validate(backupType)
+	if err != nil {
+		slog.Error("Cannot plan the backup. Invalid backup options", "Error", err)
+		return model.BackupPlan{}, fmt.Errorf("%w: %w", ErrInvalidBackupOptions, err)
+	}
+	err = target.Validate(backupPath, options)
+	if err != nil {
+		slog.Error("Cannot plan the backup. Invalid backup target", "Error", err)
+		return model.BackupPlan{}, fmt.Errorf("%w: %w", ErrInvalidBackupTarget, err)
+	}
+	options = ds.withDefaultBackupOptions(backupType, backupPath, options)
+
+	rp, err := ds.getRepository(key)
+	if err != nil {
+		slog.Error("Cannot connect to database: ", "Error: ", err)
+		return model.BackupPlan{}, fmt.Errorf("Connection failed. Try to /connect.\nDetails: %v", err.Error())
+	}
+
+	allowedDbs, bannedDbs, err := ds.checkBackupDatabases(rp, backupDbList, backupType)
+	if err != nil {
+		slog.Error("Cannot plan the backup", "Error", err)
+		return model.BackupPlan{}, err
+	}
+
+	plan := model.BackupPlan{
+		BackupPath: backupPath,
+		Target:     target,
+		BackupType: backupType,
+		Options:    options,
+		Databases:  make([]model.BackupPlanDatabase, 0, len(allowedDbs)),
+		Rejected:   bannedDbs,
+	}
+
+	// The files are summed by directory, so a directory out of the known volumes is warned only once
+	var targets []volumeTarget
+	for _, database := range allowedDbs {
+		planned := model.BackupPlanDatabase{Database: database.Name}
+		planned.Statement, planned.Files = repository.BackupStatement(database.Name, backupPath, target, backupType, options)
+
+		planned.EstimatedBytes, err = rp.GetBackupSize(database.Name, backupType)
+		if err != nil {
+			slog.Warn("Cannot estimate the backup size: ", "Database", database.Name, "Error: ", err)
+			planned.Warnings = append(planned.Warnings, fmt.Sprintf("The backup size could not be estimated: %v", err))
+		}
+		plan.EstimatedBytes += planned.EstimatedBytes
+
+		// The stripes of a striped backup have about the same size
+		for _, file := range planned.Files {
+			directory := file[:strings.LastIndexAny(file, `/\`)+1]
+			index := slices.IndexFunc(targets, func(target volumeTarget) bool { return target.path == directory })
+			if index < 0 {
+				targets = append(targets, volumeTarget{path: directory})
+				index = len(targets) - 1
+			}
+			targets[index].size += planned.EstimatedBytes / int64(len(planned.Files))
+		}
+
+		plan.Databases = append(plan.Databases, planned)
+	}
+
+	encryptionWarnings, err := checkBackupEncryptor(rp, options)
+	if err != nil {
+		plan.Warnings = append(plan.Warnings, err.Error())
+	}
+	plan.Warnings = append(plan.Warnings, encryptionWarnings...)
+
+	if options.Compression != nil && *options.Compression {
+		plan.Warnings = append(plan.Warnings, "The estimated sizes are uncompressed. With COMPRESSION the backup files are usually smaller")
+	}
+
+	if target.Type == model.StorageURL {
+		if err := checkBackupCredential(rp, backupPath, target); err != nil {
+			plan.Warnings = append(plan.Warnings, err.Error())
+		}
+		return plan, nil
+	}
+
+	volumes, err := rp.GetVolumes()
+	if err != nil {
+		slog.Warn("Cannot get the volumes free space (sys.dm_os_volume_stats): ", "Error: ", err)
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("The free space of the volumes was not checked: %v", err))
+		return plan, nil
+	}
+
+	var warnings []string
+	plan.Volumes, warnings = volumeUsage(targets, volumes)
+	plan.Warnings = append(plan.Warnings, warnings...)
+
+	return plan, nil
+}
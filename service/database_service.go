@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -8,49 +9,149 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/RenanMonteiroS/MaestroSQLWeb/config"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/db"
 	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
 	"github.com/RenanMonteiroS/MaestroSQLWeb/repository"
 )
 
-// Struct responsible for manage authentication logic, business rules, data transformation and logs. Requires a DatabaseRepository.
+// Struct responsible for manage authentication logic, business rules, data transformation and logs. Requires a ConnRegistry, a JobService and the configuration.
 // Related to database objects
 type DatabaseService struct {
-	repository repository.DatabaseRepository
+	connections *db.ConnRegistry
+	jobs        *JobService
+	storage     repository.ObjectStorage
+	shipper     repository.FileShipper
+	cfg         config.DatabaseConfig
+	jobsCfg     config.JobsConfig
+	backupCfg   config.BackupConfig
 }
 
 // Creates an instance of DatabaseService struct
-func NewDatabaseService(rp repository.DatabaseRepository) DatabaseService {
-	return DatabaseService{repository: rp}
+func NewDatabaseService(connections *db.ConnRegistry, jobs *JobService, cfg config.DatabaseConfig, jobsCfg config.JobsConfig, backupCfg config.BackupConfig, storageCfg config.StorageConfig) DatabaseService {
+	return DatabaseService{connections: connections, jobs: jobs, storage: repository.NewObjectStorage(storageCfg), shipper: repository.NewFileShipper(storageCfg), cfg: cfg, jobsCfg: jobsCfg, backupCfg: backupCfg}
 }
 
-// ErrPortAndInstanceEmpty is returned when both instance and port are empty.
 var (
+	// ErrPortAndInstanceEmpty is returned when both instance and port are empty.
 	ErrPortAndInstanceEmpty = errors.New("Instance and port are both empty")
+	// ErrLogBackupSimpleRecovery is returned for log backups of databases in the SIMPLE recovery model, which have no log chain.
+	ErrLogBackupSimpleRecovery = errors.New("Transaction log backups are not allowed in the SIMPLE recovery model")
+	// ErrMasterFullBackupOnly is returned for differential and log backups of the master database, which only accepts full backups.
+	ErrMasterFullBackupOnly = errors.New("Only full backups are allowed for the master database")
+	// ErrTempdbBackup is returned for backups of tempdb, which is recreated on every server start and cannot be backed up.
+	ErrTempdbBackup = errors.New("The tempdb database cannot be backed up")
+	// ErrSnapshotBackup is returned for backups of database snapshots, which cannot be backed up.
+	ErrSnapshotBackup = errors.New("Database snapshots cannot be backed up")
+	// ErrDatabaseNotOnline is returned for backups of databases which are not ONLINE (OFFLINE, RESTORING, RECOVERING, SUSPECT, ...).
+	ErrDatabaseNotOnline = errors.New("Only ONLINE databases can be backed up")
+	// ErrTempdbRestore is returned for restores of tempdb, which is recreated on every server start and cannot be restored.
+	ErrTempdbRestore = errors.New("The tempdb database cannot be restored")
+	// ErrSystemDatabaseRestore is returned when master, model or msdb are restored by the common restore, instead of /api/restore/system.
+	ErrSystemDatabaseRestore = errors.New("System databases are only restored by /api/restore/system")
+	// ErrInvalidSystemRestore is returned when the /api/restore/system request is not a confirmed restore of master, model or msdb.
+	ErrInvalidSystemRestore = errors.New("Invalid system database restore")
+	// ErrInvalidBackupOptions is returned when the backup options of the request are not valid.
+	ErrInvalidBackupOptions = errors.New("Invalid backup options")
+	// ErrInvalidRestoreOptions is returned when the restore options of any database of the request are not valid, including a REPLACE which was not confirmed.
+	ErrInvalidRestoreOptions = errors.New("Invalid restore options")
+	// ErrInvalidRestoreChain is returned when the point-in-time restore request is not valid (database names, path or STOPAT time).
+	ErrInvalidRestoreChain = errors.New("Invalid point-in-time restore request")
+	// ErrNoFullBackup is returned when no full backup of the database, finished before the STOPAT time, is found to start the restore chain.
+	ErrNoFullBackup = errors.New("No full backup found to start the restore chain")
+	// ErrRestoreChainGap is returned when the log backups do not form a continuous chain from the full (or differential) backup.
+	ErrRestoreChainGap = errors.New("The log backup chain is broken")
+	// ErrStopAtNotCovered is returned when no log backup of the chain reaches the STOPAT time.
+	ErrStopAtNotCovered = errors.New("No log backup reaches the STOPAT time")
+	// ErrInvalidHistoryFilter is returned when the filter or the pagination of the history request are not valid.
+	ErrInvalidHistoryFilter = errors.New("Invalid history filter")
+	// ErrInvalidRetention is returned when the retention request is not valid (path or policy).
+	ErrInvalidRetention = errors.New("Invalid retention request")
+	// ErrInvalidBackupTarget is returned when the storage target does not match the backup path, or the options cannot be used with it.
+	ErrInvalidBackupTarget = errors.New("Invalid backup target")
+	// ErrBackupCredentialNotFound is returned when the server has no credential for the backup URL, so BACKUP ... TO URL would fail on every database.
+	ErrBackupCredentialNotFound = errors.New("No credential found for the backup URL")
+	// ErrInvalidCredential is returned when the /api/credentials request is not valid.
+	ErrInvalidCredential = errors.New("Invalid credential")
+	// ErrInvalidShipDestination is returned when the shipTo of the backup cannot be parsed, or is set for a backup to URL.
+	ErrInvalidShipDestination = errors.New("Invalid ship destination")
+	// ErrBackupEncryptorNotFound is returned when the certificate or asymmetric key which encrypts the backup is not in the master database of the server.
+	ErrBackupEncryptorNotFound = errors.New("Backup encryptor not found")
+	// ErrTDECertificateNotFound is returned when the certificate of a database encrypted with Transparent Data Encryption (TDE) is not in the master database of the server,
+	// and it cannot be created from the restore options.
+	ErrTDECertificateNotFound = errors.New("TDE certificate not found")
+	// ErrInvalidBackupFile is returned when the backup file to be inspected has an invalid path, misses stripes or has no backup sets.
+	ErrInvalidBackupFile = errors.New("Invalid backup file")
 )
 
-// Establish a connection with a database.
-// Args: connInfo -> A struct with connection params (host, port, user, password)
-func (ds *DatabaseService) ConnectDatabase(connInfo model.ConnInfo) (*sql.DB, error) {
+// Establish a connection with a database, and registers it for the caller session.
+// Args: key -> The session/connection ID pair which will own the connection; connInfo -> A struct with connection params (host, port, user, password)
+func (ds *DatabaseService) ConnectDatabase(key db.ConnKey, connInfo model.ConnInfo) (*sql.DB, error) {
 	if connInfo.Port == "" && connInfo.Instance == "" {
 		slog.Error("Cannot connect to database: ", "Error: ", ErrPortAndInstanceEmpty)
 		return nil, ErrPortAndInstanceEmpty
 	}
 
-	conn, err := ds.repository.ConnectDatabase(connInfo)
+	rp := repository.NewDatabaseRepository(nil)
+	conn, err := rp.ConnectDatabase(connInfo, ds.cfg)
 	if err != nil {
 		slog.Error("Cannot connect to database: ", "Error: ", err)
 		return nil, err
 	}
 
+	ds.connections.Register(key, conn, connInfo)
+
 	return conn, nil
 }
 
-// Checks if the connection poll is set and running
-func (ds *DatabaseService) CheckDbConn() error {
-	err := ds.repository.CheckDbConn()
+// Gets the repository bound to the connection of the caller session, checking if the connection poll is set and running
+func (ds *DatabaseService) getRepository(key db.ConnKey) (repository.DatabaseRepository, error) {
+	conn, err := ds.connections.Get(key)
+	if err != nil {
+		return repository.DatabaseRepository{}, err
+	}
+
+	rp := repository.NewDatabaseRepository(conn)
+	err = rp.CheckDbConn()
+	if err != nil {
+		return repository.DatabaseRepository{}, err
+	}
+
+	return rp, nil
+}
+
+// Gets the repository bound to the connection of the caller session for a background job, like getRepository. The connection is held open while the job runs,
+// even if the session logs out or the connection is evicted (see db.ConnRegistry.Acquire). The returned function releases it, once the job goroutine exits
+func (ds *DatabaseService) acquireRepository(key db.ConnKey) (repository.DatabaseRepository, func(), error) {
+	conn, release, err := ds.connections.Acquire(key)
+	if err != nil {
+		return repository.DatabaseRepository{}, nil, err
+	}
+
+	rp := repository.NewDatabaseRepository(conn)
+	err = rp.CheckDbConn()
+	if err != nil {
+		release()
+		return repository.DatabaseRepository{}, nil, err
+	}
+
+	return rp, release, nil
+}
+
+// Returns the template of the job of an operation requested by a session (see JobService.Create): the job is owned by the session of the key
+// and recorded with the SQL Server instance of its connection
+func (ds *DatabaseService) sessionJob(key db.ConnKey, jobType model.JobType, createdBy string, path string, options any) model.Job {
+	return model.Job{Type: jobType, SessionID: key.SessionID, CreatedBy: createdBy, Server: ds.connections.Server(key), Path: path, Options: options}
+}
+
+// Checks if the connection poll of the caller session is set and running
+func (ds *DatabaseService) CheckDbConn(key db.ConnKey) error {
+	_, err := ds.getRepository(key)
 	if err != nil {
 		slog.Error("Cannot connect to database: ", "Error: ", err)
 		return err
@@ -59,17 +160,22 @@ func (ds *DatabaseService) CheckDbConn() error {
 	return nil
 }
 
-// Gets all server databases
-// Before it calls the repository.GetDatabases() function, it checks if the connection is set.
-func (ds *DatabaseService) GetDatabases() ([]model.Database, error) {
-	err := ds.CheckDbConn()
+// Gets all server databases which pass the filter (see model.DatabaseFilter), with their metadata
+// Before it calls the repository.GetDatabases() function, it checks if the connection of the caller session is set.
+func (ds *DatabaseService) GetDatabases(key db.ConnKey, filter model.DatabaseFilter) ([]model.Database, error) {
+	rp, err := ds.getRepository(key)
 	if err != nil {
 		slog.Error("Cannot connect to database: ", "Error: ", err)
 		return []model.Database{}, err
 	}
 
+	return getDatabases(rp, filter)
+}
+
+// Gets the databases of the server of the repository, with their files, filtered
+func getDatabases(rp repository.DatabaseRepository, filter model.DatabaseFilter) ([]model.Database, error) {
 	slog.Info("Getting databases...")
-	dbListAux, err := ds.repository.GetDatabases()
+	dbListAux, err := rp.GetDatabases()
 	if err != nil {
 		slog.Error("Cannot get databases: ", "Error: ", err)
 		return nil, err
@@ -84,6 +190,13 @@ func (ds *DatabaseService) GetDatabases() ([]model.Database, error) {
 		dbObj = model.Database{}
 		dbObj.ID = dbData.DatabaseId
 		dbObj.Name = dbData.DatabaseName
+		dbObj.State = dbData.State
+		dbObj.RecoveryModel = dbData.RecoveryModel
+		dbObj.CompatibilityLevel = dbData.CompatibilityLevel
+		dbObj.IsReadOnly = dbData.IsReadOnly
+		dbObj.IsSnapshot = dbData.IsSnapshot
+		dbObj.IsSystem = model.IsSystemDatabase(dbData.DatabaseName)
+		dbObj.Owner = dbData.Owner
 
 		for key, dbListData := range dbList {
 			if dbListData.ID == dbData.DatabaseId {
@@ -91,133 +204,425 @@ func (ds *DatabaseService) GetDatabases() ([]model.Database, error) {
 				dbFile.LogicalName = dbData.LogicalName
 				dbFile.PhysicalName = dbData.PhysicalName
 				dbFile.FileType = dbData.FileType
+				dbFile.Size = dbData.Size
 				dbList[key].Files = append(dbList[key].Files, dbFile)
+				dbList[key].SizeBytes += dbData.Size
 			}
 		}
 		if found != true {
 			dbFile.LogicalName = dbData.LogicalName
 			dbFile.PhysicalName = dbData.PhysicalName
 			dbFile.FileType = dbData.FileType
+			dbFile.Size = dbData.Size
 			dbObj.Files = append(dbObj.Files, dbFile)
+			dbObj.SizeBytes = dbData.Size
 			dbList = append(dbList, dbObj)
 		}
 		found = false
 	}
 
-	return dbList, nil
+	filteredDbList := make([]model.Database, 0, len(dbList))
+	for _, database := range dbList {
+		if !filter.Match(database) {
+			continue
+		}
+		if filter.BackupType != "" && backupRejection(database, filter.BackupType) != nil {
+			continue
+		}
+		filteredDbList = append(filteredDbList, database)
+	}
+
+	return filteredDbList, nil
 }
 
-// Starts the backup, for each database selected, storing into the backup path chosen.
-// Before it calls the repository.BackupDatabase() function, it checks if the connection is set.
-func (ds *DatabaseService) BackupDatabase(backupDbList []model.Database, backupPath string, concurrentOpe *int) ([]model.Database, []model.SqlErr, error, string) {
-	t0 := time.Now()
-	err := ds.CheckDbConn()
+// Gets the default backup options, set by the backup.* configuration
+func (ds *DatabaseService) DefaultBackupOptions() model.BackupOptions {
+	return ds.backupCfg.Options()
+}
+
+// Fills the options which were not set in the request with the defaults. The COPY_ONLY default is not used by differential backups, which ignore it,
+// and the MAXTRANSFERSIZE default, sized for disks, is not used by S3 URLs
+func (ds *DatabaseService) withDefaultBackupOptions(backupType model.BackupType, backupPath string, options model.BackupOptions) model.BackupOptions {
+	defaults := ds.backupCfg.Options()
+
+	if options.Compression == nil {
+		options.Compression = defaults.Compression
+	}
+	if options.Checksum == nil {
+		options.Checksum = defaults.Checksum
+	}
+	if options.CopyOnly == nil && backupType != model.BackupDifferential {
+		options.CopyOnly = defaults.CopyOnly
+	}
+	if options.ContinueAfterError == nil {
+		options.ContinueAfterError = defaults.ContinueAfterError
+	}
+	if options.Stats == 0 {
+		options.Stats = defaults.Stats
+	}
+	if options.BufferCount == 0 {
+		options.BufferCount = defaults.BufferCount
+	}
+	if options.MaxTransferSize == 0 && !strings.HasPrefix(strings.ToLower(backupPath), "s3://") {
+		options.MaxTransferSize = defaults.MaxTransferSize
+	}
+	if options.BlockSize == 0 {
+		options.BlockSize = defaults.BlockSize
+	}
+	if options.Verify == nil {
+		options.Verify = defaults.Verify
+	}
+
+	return options
+}
+
+// Starts the backup job of the backup type, for each database selected, storing into the backup path chosen: a disk folder or a backup URL, by the target.
+// With shipTo, the backup files of each database are copied to the secondary location once its backup finishes (see shippingObserver).
+// Returns the queued job, while the backup runs in background.
+// Before it starts the job, it checks if the connection is set and if the databases can be backed up (see checkBackupDatabases).
+// Databases which cannot be backed up are registered as failed in the job.
+func (ds *DatabaseService) BackupDatabase(key db.ConnKey, createdBy string, backupDbList []model.Database, backupPath string, target model.BackupTarget, shipTo string, backupType model.BackupType, options model.BackupOptions, concurrentOpe *int) (model.Job, error) {
+	err := options.Validate(backupType)
+	if err != nil {
+		slog.Error("Backup database cannot start. Invalid backup options", "Error", err)
+		return model.Job{}, fmt.Errorf("%w: %w", ErrInvalidBackupOptions, err)
+	}
+	err = target.Validate(backupPath, options)
+	if err != nil {
+		slog.Error("Backup database cannot start. Invalid backup target", "Error", err)
+		return model.Job{}, fmt.Errorf("%w: %w", ErrInvalidBackupTarget, err)
+	}
+	err = model.ValidateShipTo(shipTo, target)
+	if err != nil {
+		slog.Error("Backup database cannot start. Invalid ship destination", "Error", err)
+		return model.Job{}, fmt.Errorf("%w: %w", ErrInvalidShipDestination, err)
+	}
+	options = ds.withDefaultBackupOptions(backupType, backupPath, options)
+
+	rp, release, err := ds.acquireRepository(key)
 	if err != nil {
 		slog.Error("Cannot connect to database: ", "Error: ", err)
-		return []model.Database{}, []model.SqlErr{}, fmt.Errorf("Connection failed. Try to /connect.\nDetails: %v", err.Error()), ""
+		return model.Job{}, fmt.Errorf("Connection failed. Try to /connect.\nDetails: %v", err.Error())
+	}
+
+	job, err := ds.startBackup(rp, release, ds.sessionJob(key, model.JobBackup, createdBy, "", nil), backupDbList, backupPath, target, shipTo, backupType, options, concurrentOpe)
+	if err != nil {
+		release()
+	}
+
+	return job, err
+}
+
+// Starts the backup job, with the validated options, target and ship destination, in the server of the repository. The template sets who created the job (see JobService.Create).
+// release is called once the job goroutine no longer uses the repository; when the job is not started, the caller releases it.
+// A ship destination which cannot be shipped to does not stop the backup: the job gets a warning and the files are not shipped
+func (ds *DatabaseService) startBackup(rp repository.DatabaseRepository, release func(), template model.Job, backupDbList []model.Database, backupPath string, target model.BackupTarget, shipTo string, backupType model.BackupType, options model.BackupOptions, concurrentOpe *int) (model.Job, error) {
+	err := checkBackupCredential(rp, backupPath, target)
+	if err != nil {
+		slog.Error("Backup database cannot start", "Error", err)
+		return model.Job{}, err
 	}
 
-	existingDatabases, err := ds.GetDatabases()
+	encryptionWarnings, err := checkBackupEncryptor(rp, options)
 	if err != nil {
-		slog.Error("Backup database cannot start. Cannot get databases", "Error", err)
-		return []model.Database{}, []model.SqlErr{}, fmt.Errorf("Cannot get databases. Details: %v", err.Error()), ""
+		slog.Error("Backup database cannot start", "Error", err)
+		return model.Job{}, err
 	}
+	template.Warnings = append(template.Warnings, encryptionWarnings...)
 
-	dbNamesSet := make(map[string]struct{})
+	allowedDbs, bannedDbs, err := ds.checkBackupDatabases(rp, backupDbList, backupType)
+	if err != nil {
+		slog.Error("Backup database cannot start", "Error", err)
+		return model.Job{}, err
+	}
+
+	jobDbNames := make([]string, 0, len(backupDbList))
+	for _, db := range backupDbList {
+		jobDbNames = append(jobDbNames, db.Name)
+	}
+
+	destination, err := ds.shipDestination(shipTo, append([]string{backupPath}, options.StripeDirectories...))
+	if err != nil {
+		slog.Warn("The backup files will not be shipped", "Ship to", shipTo, "Error", err)
+		template.Warnings = append(template.Warnings, fmt.Sprintf("The backup files will not be shipped to %v: %v", shipTo, err))
+	}
+
+	template.Path = backupPath
+	template.Options = map[string]any{"backupType": backupType, "target": target, "shipTo": shipTo, "options": options, "concurrentOpe": concurrentOpe}
+	job := ds.jobs.Create(template, jobDbNames)
+	for _, bannedDb := range bannedDbs {
+		ds.jobs.Reject(job.ID, bannedDb)
+	}
+
+	observer := ds.jobs.Observer(job.ID)
+	var shipping *shippingObserver
+	if destination != nil {
+		shipping = ds.shippingObserver(job.ID, *destination, observer, len(allowedDbs))
+		observer = shipping
+	}
+
+	go func() {
+		defer release()
+		ds.jobs.Start(job.ID)
+		stopProgress := ds.sampleProgress(rp, job.ID)
+
+		slog.Info("Starting backup...", "Job", job.ID, "Databases", backupDbList, "Backup path", backupPath, "Target", target.Type, "Backup type", backupType, "Options", options)
+		backupDbDoneList, errBackup := rp.BackupDatabase(ds.jobs.Context(job.ID), allowedDbs, backupPath, target, backupType, options, concurrentOpe, ds.jobsCfg.BackupTimeout.Std(), observer)
+		if len(bannedDbs) > 0 {
+			errBackup = append(errBackup, bannedDbs...)
+		}
+		stopProgress()
+		if shipping != nil {
+			shipping.wait()
+		}
+		ds.jobs.Finish(job.ID, errBackup)
+
+		if errBackup != nil {
+			slog.Warn("Backup completed with errors", "Job", job.ID, "Completed backups", backupDbDoneList, "Errors", errBackup)
+			return
+		}
+
+		slog.Info("Backup completed sucessfully", "Job", job.ID, "Completed backups", backupDbDoneList)
+	}()
+
+	return job, nil
+}
+
+// Checks if each database can be backed up with the backup type (see backupRejection). Returns the allowed databases and the rejected ones, with their typed reason
+func (ds *DatabaseService) checkBackupDatabases(rp repository.DatabaseRepository, backupDbList []model.Database, backupType model.BackupType) ([]model.Database, []model.SqlErr, error) {
+	existingDatabases, err := getDatabases(rp, model.DatabaseFilter{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("Cannot get databases. Details: %v", err.Error())
+	}
+
+	databases := make(map[string]model.Database, len(existingDatabases))
 	allowedDbs := make([]model.Database, 0, len(backupDbList))
 	bannedDbs := make([]model.SqlErr, 0, len(backupDbList))
 
 	for _, db := range existingDatabases {
-		dbNamesSet[db.Name] = struct{}{}
+		databases[db.Name] = db
 	}
 
 	for _, db := range backupDbList {
-		if _, ok := dbNamesSet[db.Name]; ok {
-			allowedDbs = append(allowedDbs, db)
-		} else {
-			bannedDbs = append(bannedDbs, *model.NewSqlErr(db.Name, fmt.Errorf("The database %v does not exists in the server", db.Name)))
+		database, ok := databases[db.Name]
+		if !ok {
+			bannedDbs = append(bannedDbs, *model.NewSqlErr(db.Name, model.NewRejectedErr(model.RejectNotFound, fmt.Errorf("The database %v does not exists in the server", db.Name))))
+			continue
 		}
+		if err := backupRejection(database, backupType); err != nil {
+			bannedDbs = append(bannedDbs, *model.NewSqlErr(db.Name, err))
+			continue
+		}
+		allowedDbs = append(allowedDbs, db)
 	}
 
-	slog.Info("Starting backup...", "Databases", backupDbList, "Backup path", backupPath)
-	backupDbDoneList, errBackup := ds.repository.BackupDatabase(allowedDbs, backupPath, concurrentOpe)
-	if len(bannedDbs) > 0 {
-		errBackup = append(errBackup, bannedDbs...)
+	return allowedDbs, bannedDbs, nil
+}
+
+// Returns why the database cannot be backed up with the backup type, or nil if it can. The database must be ONLINE; tempdb and database snapshots cannot be backed up,
+// master only accepts full backups and log backups require the FULL or BULK_LOGGED recovery model
+func backupRejection(database model.Database, backupType model.BackupType) error {
+	switch {
+	case database.Name == "tempdb":
+		return model.NewRejectedErr(model.RejectTempdb, ErrTempdbBackup)
+	case database.IsSnapshot:
+		return model.NewRejectedErr(model.RejectSnapshot, fmt.Errorf("%w. The database %v is a snapshot", ErrSnapshotBackup, database.Name))
+	case database.State != "ONLINE":
+		return model.NewRejectedErr(model.RejectNotOnline, fmt.Errorf("%w. The database %v is %v", ErrDatabaseNotOnline, database.Name, database.State))
+	case backupType != model.BackupFull && database.Name == "master":
+		return model.NewRejectedErr(model.RejectMasterFullOnly, ErrMasterFullBackupOnly)
+	case backupType == model.BackupLog && database.RecoveryModel == "SIMPLE":
+		return model.NewRejectedErr(model.RejectSimpleRecovery, fmt.Errorf("%w. The database %v uses the SIMPLE recovery model", ErrLogBackupSimpleRecovery, database.Name))
+	}
+
+	return nil
+}
+
+// Starts the restore job, for each backup file selected. Returns the queued job, while the restore runs in background.
+// Before it starts the job, it checks if the connection is set and plans the restore of each database (see planRestore). System databases are rejected
+func (ds *DatabaseService) RestoreDatabase(key db.ConnKey, createdBy string, restoreDbList []model.ToBeRestoredDb, concurrentOpe *int) (model.Job, error) {
+	return ds.startRestore(key, createdBy, restoreDbList, concurrentOpe, false)
+}
+
+// Starts the restore job of one system database (master, model or msdb), which must be confirmed by its name. Returns the queued job, while the restore runs in background.
+// Restoring master requires the server to be started in single-user mode, and restoring msdb requires the SQL Server Agent to be stopped
+func (ds *DatabaseService) RestoreSystemDatabase(key db.ConnKey, createdBy string, request model.RestoreSystemPostRequired) (model.Job, error) {
+	name := request.Database.Name
+	if !model.IsSystemDatabase(name) || strings.EqualFold(name, "tempdb") {
+		return model.Job{}, fmt.Errorf("%w: %v is not master, model or msdb", ErrInvalidSystemRestore, name)
 	}
-	if errBackup != nil {
-		slog.Warn("Backup completed with errors", "Completed backups", backupDbDoneList, "Errors", errBackup)
-		totalTime := fmt.Sprintf("%dh%dm%ds", int(time.Since(t0).Hours()), int(time.Since(t0).Minutes())%60, int(time.Since(t0).Seconds())%60)
-		return backupDbDoneList, errBackup, nil, totalTime
+	if request.ConfirmSystemDatabase != name {
+		return model.Job{}, fmt.Errorf("%w: confirmSystemDatabase must be %q to overwrite the system database", ErrInvalidSystemRestore, name)
 	}
 
-	slog.Info("Backup completed sucessfully", "Completed backups", backupDbDoneList)
-	totalTime := fmt.Sprintf("%dh%dm%ds", int(time.Since(t0).Hours()), int(time.Since(t0).Minutes())%60, int(time.Since(t0).Seconds())%60)
-	return backupDbDoneList, nil, nil, totalTime
+	return ds.startRestore(key, createdBy, []model.ToBeRestoredDb{request.Database}, nil, true)
 }
 
-// Starts the backup, for each database selected, storing into the backup path chosen.
-// Before it calls the repository.RestoreDatabase() function, it checks if the connection is set, gets the backup file data, mounts the database object and gets the default data files path
-func (ds *DatabaseService) RestoreDatabase(restoreDbList []model.ToBeRestoredDb, concurrentOpe *int) ([]model.RestoreDb, []model.SqlErr, error, string) {
-	t0 := time.Now()
-	err := ds.CheckDbConn()
+// Starts the restore job. The system databases are only restored when allowSystem is set
+func (ds *DatabaseService) startRestore(key db.ConnKey, createdBy string, restoreDbList []model.ToBeRestoredDb, concurrentOpe *int, allowSystem bool) (model.Job, error) {
+	err := validateRestoreOptions(restoreDbList)
+	if err != nil {
+		slog.Error("Restore database cannot start. Invalid restore options", "Error", err)
+		return model.Job{}, err
+	}
+
+	rp, release, err := ds.acquireRepository(key)
 	if err != nil {
 		slog.Error("Cannot connect to database: ", "Error: ", err)
-		return []model.RestoreDb{}, []model.SqlErr{}, fmt.Errorf("Connection failed. Try to /connect.\nDetails: %v", err), ""
+		return model.Job{}, fmt.Errorf("Connection failed. Try to /connect.\nDetails: %v", err)
+	}
+
+	restoreDatabaseList, sanitizedErrors, dataPath, logPath, err := planRestore(rp, restoreDbList, allowSystem)
+	if err != nil {
+		release()
+		return model.Job{}, err
+	}
+
+	restoreDatabaseList, tdeErrors := createTDECertificates(rp, restoreDatabaseList)
+	sanitizedErrors = append(sanitizedErrors, tdeErrors...)
+
+	jobDbNames := make([]string, 0, len(restoreDatabaseList)+len(sanitizedErrors))
+	for _, restoreDatabase := range restoreDatabaseList {
+		jobDbNames = append(jobDbNames, restoreDatabase.Database.Name)
+	}
+	for _, sanitizedError := range sanitizedErrors {
+		jobDbNames = append(jobDbNames, sanitizedError.Database)
+	}
+
+	job := ds.jobs.Create(ds.sessionJob(key, model.JobRestore, createdBy, "", map[string]any{"databases": restoreDbList, "concurrentOpe": concurrentOpe, "system": allowSystem}), jobDbNames)
+	for _, sanitizedError := range sanitizedErrors {
+		ds.jobs.Reject(job.ID, sanitizedError)
+	}
+
+	go func() {
+		defer release()
+		ds.jobs.Start(job.ID)
+		stopProgress := ds.sampleProgress(rp, job.ID)
+
+		slog.Info("Starting restore...", "Job", job.ID, "Databases: ", restoreDatabaseList, "Data path: ", dataPath, "Log path:", logPath)
+		restoredDatabases, errRestoreList := rp.RestoreDatabase(ds.jobs.Context(job.ID), restoreDatabaseList, concurrentOpe, ds.jobsCfg.RestoreTimeout.Std(), ds.jobs.Observer(job.ID))
+		errRestoreList = append(errRestoreList, sanitizedErrors...)
+		stopProgress()
+		ds.jobs.Finish(job.ID, errRestoreList)
+
+		if len(errRestoreList) > 0 {
+			slog.Warn("Restore completed with errors: ", "Job", job.ID, "Completed restores: ", restoredDatabases, "Errors: ", errRestoreList)
+			return
+		}
+
+		slog.Info("Restore completed sucessfully: ", "Job", job.ID, "Completed restores: ", restoredDatabases)
+	}()
+
+	return job, nil
+}
+
+// Validates the restore options of every database, returning all the problems found at once, wrapped in ErrInvalidRestoreOptions
+func validateRestoreOptions(restoreDbList []model.ToBeRestoredDb) error {
+	var optionsErrs []error
+	for _, db := range restoreDbList {
+		optionsErrs = append(optionsErrs, db.Options.Validate(db.Name))
+	}
+	if err := errors.Join(optionsErrs...); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidRestoreOptions, err)
+	}
+
+	return nil
+}
+
+// Plans the restore of each backup file selected, as executed by RestoreDatabase and previewed by PlanRestore: it sanitizes the database names and backup paths,
+// resolves the stripes of the striped backups, checks the encryptor of the encrypted backups (RESTORE HEADERONLY), gets the backup file data (RESTORE FILELISTONLY),
+// mounts the database object, checks the certificates of the databases encrypted with TDE, gets the default data files path and plans the file moves.
+// The databases which cannot be restored are returned as errors, and are not planned: tempdb, and master, model and msdb when allowSystem is not set
+func planRestore(rp repository.DatabaseRepository, restoreDbList []model.ToBeRestoredDb, allowSystem bool) ([]model.RestoreDb, []model.SqlErr, string, string, error) {
+	restoreOptions := make(map[string]model.RestoreOptions, len(restoreDbList))
+	for _, db := range restoreDbList {
+		restoreOptions[db.Name] = db.Options
 	}
 
 	var database model.RestoreDb
 	var restoreDatabaseList []model.RestoreDb
 	sanitizedErrors := make([]model.SqlErr, 0, len(restoreDbList))
+	sanitizedDbList := make([]model.ToBeRestoredDb, 0, len(restoreDbList))
 
 	for _, db := range restoreDbList {
 		ok, err := regexp.MatchString(`^[a-zA-Z0-9_#$@.-]+$`, db.Name)
 		if err != nil {
 			slog.Error("Cannot search string with regexp", "Error", err)
-			return nil, nil, err, ""
+			return nil, nil, "", "", err
 		}
 		if !ok {
 			slog.Error("There is an invalid character in the database name", "Database", db.Name)
 			sanitizedErrors = append(sanitizedErrors, model.SqlErr{Database: db.Name, Err: fmt.Errorf("There is an invalid character in the database name")})
+			continue
 		}
-		ok, err = regexp.MatchString(`^[a-zA-Z0-9._\-/\\\s:(){}\[\]@#$%^&+=~]+$`, db.BackupPath)
-		if err != nil {
-			slog.Error("Cannot search string with regexp", "Error", err)
-			return nil, nil, err, ""
+		if strings.EqualFold(db.Name, "tempdb") {
+			sanitizedErrors = append(sanitizedErrors, model.SqlErr{Database: db.Name, Err: model.NewRejectedErr(model.RejectTempdb, ErrTempdbRestore)})
+			continue
 		}
-		if !ok {
-			slog.Error("There is an invalid character in the backup path", "Path", db.BackupPath)
-			sanitizedErrors = append(sanitizedErrors, model.SqlErr{Database: db.Name, Err: fmt.Errorf("There is an invalid character in the backup path %v", db.BackupPath)})
+		if model.IsSystemDatabase(db.Name) && !allowSystem {
+			slog.Error("System databases are only restored by /api/restore/system", "Database", db.Name)
+			sanitizedErrors = append(sanitizedErrors, model.SqlErr{Database: db.Name, Err: model.NewRejectedErr(model.RejectSystemDatabase, ErrSystemDatabaseRestore)})
+			continue
+		}
+		if len(db.BackupPaths) == 0 {
+			db.BackupPaths, err = resolveStripeSet(db.BackupPath)
+			if err != nil {
+				slog.Error("Cannot find the stripes of the backup", "Path", db.BackupPath, "Error", err)
+				sanitizedErrors = append(sanitizedErrors, model.SqlErr{Database: db.Name, Err: err})
+				continue
+			}
+		}
+		var invalidPath string
+		for _, backupPath := range db.MediaSet() {
+			ok, err = regexp.MatchString(`^[a-zA-Z0-9._\-/\\\s:(){}\[\]@#$%^&+=~]+$`, backupPath)
+			if err != nil {
+				slog.Error("Cannot search string with regexp", "Error", err)
+				return nil, nil, "", "", err
+			}
+			if !ok {
+				invalidPath = backupPath
+				break
+			}
+		}
+		if invalidPath != "" {
+			slog.Error("There is an invalid character in the backup path", "Path", invalidPath)
+			sanitizedErrors = append(sanitizedErrors, model.SqlErr{Database: db.Name, Err: fmt.Errorf("There is an invalid character in the backup path %v", invalidPath)})
+			continue
 		}
+
+		// RESTORE FILELISTONLY cannot read an encrypted backup without its encryptor, and the restore would only fail at the end
+		headers, err := rp.GetBackupHeaders(db.MediaSet())
+		if err != nil {
+			slog.Warn("Cannot read the header of the backup (RESTORE HEADERONLY)", "Path", db.BackupPath, "Error", err)
+		} else if len(headers) > 0 {
+			err = checkRestoreEncryptors(rp, headers[:1])
+			if err != nil {
+				slog.Error("The encryptor of the backup is not in the server", "Database", db.Name, "Error", err)
+				sanitizedErrors = append(sanitizedErrors, model.SqlErr{Database: db.Name, Err: err})
+				continue
+			}
+		}
+
+		sanitizedDbList = append(sanitizedDbList, db)
 	}
 
-	backupFilesData, err := ds.repository.GetBackupFilesData(restoreDbList)
+	backupFilesData, err := rp.GetBackupFilesData(sanitizedDbList)
 	if err != nil {
 		slog.Warn("Cannot get backup files data (RESTORE FILELISTONLY): ", "Error: ", err)
-		return nil, nil, err, ""
+		return nil, nil, "", "", err
 	}
 
 	for _, backupFileData := range backupFilesData {
 		database.Database.Name = strings.Split(backupFileData.Name, ".bak")[0]
+		database.Options = restoreOptions[backupFileData.Name]
 		database.BackupPath = backupFileData.BackupFilePath
-
-		for _, backupFileInfo := range backupFileData.BackupFileInfo {
-			var databaseFile model.DatabaseFile
-			if backupFileInfo.FileType == "D" {
-				databaseFile.FileType = "ROWS"
-				databaseFile.LogicalName = backupFileInfo.LogicalName
-				databaseFile.PhysicalName = backupFileInfo.PhysicalName
-
-				database.Database.Files = append(database.Database.Files, databaseFile)
-			} else if backupFileInfo.FileType == "L" {
-				databaseFile.FileType = "LOG"
-				databaseFile.LogicalName = backupFileInfo.LogicalName
-				databaseFile.PhysicalName = backupFileInfo.PhysicalName
-
-				database.Database.Files = append(database.Database.Files, databaseFile)
-			}
-
+		if len(backupFileData.BackupFilePaths) > 1 {
+			database.BackupPaths = backupFileData.BackupFilePaths
 		}
 
+		database.Database.Files = databaseFiles(backupFileData.BackupFileInfo)
+		database.TDEThumbprint = tdeThumbprint(backupFileData.BackupFileInfo)
+
 		if len(restoreDatabaseList) > 0 {
 			if database.Database.Name == restoreDatabaseList[len(restoreDatabaseList)-1].Database.Name {
 				restoreDatabaseList[len(restoreDatabaseList)-1] = database
@@ -231,27 +636,263 @@ func (ds *DatabaseService) RestoreDatabase(restoreDbList []model.ToBeRestoredDb,
 		database = model.RestoreDb{}
 	}
 
-	dataPath, logPath, err := ds.repository.GetDefaultFilesPath()
+	restoreDatabaseList, tdeErrors := checkTDECertificates(rp, restoreDatabaseList)
+	sanitizedErrors = append(sanitizedErrors, tdeErrors...)
+
+	dataPath, logPath, err := rp.GetDefaultFilesPath()
 	if err != nil {
 		slog.Error("Cannot get default files path: ", "Error: ", err)
-		return nil, nil, err, ""
+		return nil, nil, "", "", err
+	}
+
+	plannedDatabaseList := make([]model.RestoreDb, 0, len(restoreDatabaseList))
+	for _, restoreDatabase := range restoreDatabaseList {
+		restoreDatabase.Moves, err = moveTargets(restoreDatabase.Database.Name, restoreDatabase.Database.Files, dataPath, logPath, restoreDatabase.Options.Move)
+		if err != nil {
+			slog.Error("Cannot plan the file moves of the database", "Database", restoreDatabase.Database.Name, "Error", err)
+			sanitizedErrors = append(sanitizedErrors, model.SqlErr{Database: restoreDatabase.Database.Name, Err: err})
+			continue
+		}
+		plannedDatabaseList = append(plannedDatabaseList, restoreDatabase)
+	}
+
+	return plannedDatabaseList, sanitizedErrors, dataPath, logPath, nil
+}
+
+// Starts the verify job, which checks each backup file with RESTORE VERIFYONLY (WITH CHECKSUM, if checksum is true). Returns the queued job, while the verification runs in background.
+// The stripes of a striped backup are resolved like in the restore. Files which cannot be verified are registered as failed in the job.
+func (ds *DatabaseService) VerifyBackups(key db.ConnKey, createdBy string, files []model.ToBeVerifiedFile, checksum bool, concurrentOpe *int) (model.Job, error) {
+	rp, release, err := ds.acquireRepository(key)
+	if err != nil {
+		slog.Error("Cannot connect to database: ", "Error: ", err)
+		return model.Job{}, fmt.Errorf("Connection failed. Try to /connect.\nDetails: %v", err)
+	}
+
+	sanitizedErrors := make([]model.SqlErr, 0, len(files))
+	sanitizedFiles := make([]model.ToBeVerifiedFile, 0, len(files))
+
+	for _, file := range files {
+		if len(file.BackupPaths) == 0 {
+			file.BackupPaths, err = resolveStripeSet(file.BackupPath)
+			if err != nil {
+				slog.Error("Cannot find the stripes of the backup", "Path", file.BackupPath, "Error", err)
+				sanitizedErrors = append(sanitizedErrors, model.SqlErr{Database: file.BackupPath, Err: err})
+				continue
+			}
+		}
+		var invalidPath string
+		for _, backupPath := range file.MediaSet() {
+			ok, err := regexp.MatchString(`^[a-zA-Z0-9._\-/\\\s:(){}\[\]@#$%^&+=~]+$`, backupPath)
+			if err != nil {
+				slog.Error("Cannot search string with regexp", "Error", err)
+				release()
+				return model.Job{}, err
+			}
+			if !ok {
+				invalidPath = backupPath
+				break
+			}
+		}
+		if invalidPath != "" {
+			slog.Error("There is an invalid character in the backup path", "Path", invalidPath)
+			sanitizedErrors = append(sanitizedErrors, model.SqlErr{Database: file.BackupPath, Err: fmt.Errorf("There is an invalid character in the backup path %v", invalidPath)})
+			continue
+		}
+		sanitizedFiles = append(sanitizedFiles, file)
+	}
+
+	jobFileNames := make([]string, 0, len(files))
+	for _, file := range sanitizedFiles {
+		jobFileNames = append(jobFileNames, file.BackupPath)
+	}
+	for _, sanitizedError := range sanitizedErrors {
+		jobFileNames = append(jobFileNames, sanitizedError.Database)
 	}
 
-	slog.Info("Starting restore...", "Databases: ", restoreDatabaseList, "Data path: ", dataPath, "Log path:", "Log path")
-	restoredDatabases, errRestoreList := ds.repository.RestoreDatabase(restoreDatabaseList, dataPath, logPath, concurrentOpe)
-	totalTime := fmt.Sprintf("%dh%dm%ds", int(time.Since(t0).Hours()), int(time.Since(t0).Minutes())%60, int(time.Since(t0).Seconds())%60)
-	errRestoreList = append(errRestoreList, sanitizedErrors...)
-	if errRestoreList != nil {
-		slog.Warn("Restore completed with errors: ", "Completed restores: ", restoredDatabases, "Errors: ", errRestoreList)
-		return restoredDatabases, errRestoreList, nil, totalTime
+	job := ds.jobs.Create(ds.sessionJob(key, model.JobVerify, createdBy, "", map[string]any{"checksum": checksum, "concurrentOpe": concurrentOpe}), jobFileNames)
+	for _, sanitizedError := range sanitizedErrors {
+		ds.jobs.Reject(job.ID, sanitizedError)
 	}
 
-	slog.Info("Restore completed sucessfully: ", "Completed restores: ", restoredDatabases)
-	return restoredDatabases, nil, nil, totalTime
+	go func() {
+		defer release()
+		ds.jobs.Start(job.ID)
+		stopProgress := ds.sampleProgress(rp, job.ID)
 
+		slog.Info("Starting verify...", "Job", job.ID, "Files: ", sanitizedFiles, "Checksum", checksum)
+		verifiedFiles, errVerifyList := rp.VerifyBackups(ds.jobs.Context(job.ID), sanitizedFiles, checksum, concurrentOpe, ds.jobsCfg.VerifyTimeout.Std(), ds.jobs.Observer(job.ID))
+		errVerifyList = append(errVerifyList, sanitizedErrors...)
+		stopProgress()
+		ds.jobs.Finish(job.ID, errVerifyList)
+
+		if len(errVerifyList) > 0 {
+			slog.Warn("Verify completed with errors: ", "Job", job.ID, "Verified files: ", verifiedFiles, "Errors: ", errVerifyList)
+			return
+		}
+
+		slog.Info("Verify completed sucessfully: ", "Job", job.ID, "Verified files: ", verifiedFiles)
+	}()
+
+	return job, nil
+}
+
+// Converts the files read by RESTORE FILELISTONLY to the data (ROWS), log (LOG), FILESTREAM and full-text catalog (FULLTEXT) files of the database
+func databaseFiles(backupFileInfo []model.BackupDataFile) []model.DatabaseFile {
+	fileTypes := map[string]string{"D": "ROWS", "L": "LOG", "S": "FILESTREAM", "F": "FULLTEXT"}
+
+	var files []model.DatabaseFile
+	for _, info := range backupFileInfo {
+		fileType, ok := fileTypes[info.FileType]
+		if !ok {
+			continue
+		}
+
+		databaseFile := model.DatabaseFile{LogicalName: info.LogicalName, PhysicalName: info.PhysicalName, FileType: fileType}
+		databaseFile.FileId, _ = strconv.Atoi(info.FileId)
+		databaseFile.Size, _ = strconv.ParseInt(info.Size, 10, 64)
+		if info.FileGroupName != nil {
+			databaseFile.FileGroupName = *info.FileGroupName
+		}
+
+		files = append(files, databaseFile)
+	}
+
+	return files
+}
+
+// Matches the characters of a logical file name which are not used in the target file names
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// Plans the MOVE target of each database file, with unique and deterministic names. The primary data file (FileId 1) is <dataPath><database>.mdf
+// and the first log file is <logPath><database>.ldf. The other data files are <dataPath><database>_<logical>.ndf, the other log files <logPath><database>_<logical>.ldf,
+// and the FILESTREAM containers and full-text catalogs are the directories <dataPath><database>_<logical>. A name already used gets the FileId as suffix.
+// The overrides replace the target of the files, by their logical name; an override of a file which is not in the backup returns an error
+func moveTargets(database string, files []model.DatabaseFile, dataPath string, logPath string, overrides map[string]string) ([]model.FileMove, error) {
+	for logicalName := range overrides {
+		if !slices.ContainsFunc(files, func(file model.DatabaseFile) bool { return file.LogicalName == logicalName }) {
+			return nil, fmt.Errorf("The file %q of the MOVE override is not in the backup", logicalName)
+		}
+	}
+
+	files = slices.Clone(files)
+	slices.SortStableFunc(files, func(a, b model.DatabaseFile) int {
+		return a.FileId - b.FileId
+	})
+
+	used := make(map[string]bool)
+	primaryLog := true
+	moves := make([]model.FileMove, 0, len(files))
+
+	for _, file := range files {
+		logical := unsafeFileNameChars.ReplaceAllString(file.LogicalName, "_")
+
+		var directory, name, extension string
+		switch {
+		case file.FileType == "ROWS" && file.FileId == 1:
+			directory, name, extension = dataPath, database, ".mdf"
+		case file.FileType == "ROWS":
+			directory, name, extension = dataPath, database+"_"+logical, ".ndf"
+		case file.FileType == "LOG" && primaryLog:
+			directory, name, extension = logPath, database, ".ldf"
+			primaryLog = false
+		case file.FileType == "LOG":
+			directory, name, extension = logPath, database+"_"+logical, ".ldf"
+		default:
+			directory, name = dataPath, database+"_"+logical
+		}
+
+		target := directory + name + extension
+		if used[strings.ToLower(target)] {
+			target = fmt.Sprintf("%s%s_%d%s", directory, name, file.FileId, extension)
+		}
+		used[strings.ToLower(target)] = true
+
+		move := model.FileMove{LogicalName: file.LogicalName, FileType: file.FileType, FileGroupName: file.FileGroupName, From: file.PhysicalName, To: target, Size: file.Size}
+		if override, ok := overrides[file.LogicalName]; ok {
+			move.To = override
+			move.Override = true
+		}
+
+		moves = append(moves, move)
+	}
+
+	return moves, nil
 }
 
-// ListBackupFiles gets all .bak files from a given path
+// Samples, in background, the progress of the running databases of the job from sys.dm_exec_requests, on every jobs.progressInterval.
+// Returns a function which stops the sampling and waits for the sampler to exit, so it must be called before the job finishes and its connection is closed.
+func (ds *DatabaseService) sampleProgress(rp repository.DatabaseRepository, jobID string) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(ds.jobsCfg.ProgressInterval.Std())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if len(ds.jobs.RunningSessions(jobID)) == 0 {
+				continue
+			}
+
+			progressList, err := rp.GetOperationsProgress(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Warn("Cannot get the operations progress (sys.dm_exec_requests)", "Job", jobID, "Error", err)
+				}
+				continue
+			}
+
+			ds.jobs.UpdateProgress(jobID, progressList)
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-stopped
+	}
+}
+
+// Finds the other stripes of a striped backup file (like name=date_time_1of4.bak) in its directory. If the path is not a stripe, only the path is returned.
+// Returns an error if any stripe of the set is missing. The stripes of a backup URL are not checked, since the objects are read by the server
+func resolveStripeSet(path string) ([]string, error) {
+	fileName := filepath.Base(path)
+	directory := strings.TrimSuffix(path, fileName)
+
+	baseName, _, count, extension, ok := model.ParseStripe(fileName)
+	if !ok {
+		return []string{path}, nil
+	}
+
+	paths := make([]string, 0, count)
+	var missing []string
+	for stripe := 1; stripe <= count; stripe++ {
+		stripePath := directory + model.StripeFileName(baseName, stripe, count, extension)
+		if model.IsURLPath(path) {
+			paths = append(paths, stripePath)
+			continue
+		}
+		if _, err := os.Stat(stripePath); err != nil {
+			missing = append(missing, stripePath)
+		}
+		paths = append(paths, stripePath)
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("The stripe set %v is incomplete. Missing stripes: %v. Send every stripe in backupPaths if they are in other directories", fileName, strings.Join(missing, ", "))
+	}
+
+	return paths, nil
+}
+
+// ListBackupFiles gets all .bak, .dif and .trn files from a given path, with the backup type of their extension: a disk folder or a backup URL, whose objects are listed through the object storage (see repository.ObjectStorage).
+// The stripes of a striped backup are listed once, as a stripe set
 func (ds *DatabaseService) ListBackupFiles(path string) ([]model.BackupFileInfo, error) {
 	ok, err := regexp.MatchString(`^[a-zA-Z0-9._\-/\\s:(){}\[\]@#$%^&+=~]`, path)
 	if err != nil {
@@ -264,7 +905,7 @@ func (ds *DatabaseService) ListBackupFiles(path string) ([]model.BackupFileInfo,
 		return nil, fmt.Errorf("There is an invalid character in the filesystem path %v", path)
 	}
 
-	dir, err := os.ReadDir(path)
+	dir, err := ds.backupFolderFiles(path)
 	if err != nil {
 		slog.Error("Cannot get the directory: ", "Path", path, "Error: ", err)
 		return nil, err
@@ -276,16 +917,41 @@ func (ds *DatabaseService) ListBackupFiles(path string) ([]model.BackupFileInfo,
 	}
 
 	var backupFiles []model.BackupFileInfo
+	// Position of each stripe set in backupFiles, by its base name, stripe count and extension
+	stripeSets := make(map[string]int)
 
-	for _, file := range dir {
-		if filepath.Ext(file.Name()) == ".bak" || filepath.Ext(file.Name()) == ".BAK" {
-			fileName := file.Name()
+	for _, fileName := range dir {
+		if slices.Contains(restoreChainExtensions, strings.ToLower(filepath.Ext(fileName))) {
 			dbName := strings.TrimSuffix(fileName, filepath.Ext(fileName))
 			dbName = strings.Split(dbName, "=")[0]
-			backupFiles = append(backupFiles, model.BackupFileInfo{
-				FileName:      fileName,
-				DefaultDbName: dbName,
+			backupType, _ := model.BackupTypeFromExtension(filepath.Ext(fileName))
+
+			baseName, _, count, extension, ok := model.ParseStripe(fileName)
+			if !ok {
+				backupFiles = append(backupFiles, model.BackupFileInfo{
+					FileName:      fileName,
+					DefaultDbName: dbName,
+					BackupType:    backupType,
+				})
+				continue
+			}
+
+			setKey := fmt.Sprintf("%s|%d|%s", baseName, count, extension)
+			position, found := stripeSets[setKey]
+			if !found {
+				position = len(backupFiles)
+				stripeSets[setKey] = position
+				backupFiles = append(backupFiles, model.BackupFileInfo{DefaultDbName: dbName, BackupType: backupType, Stripes: count})
+			}
+
+			// The stripes are sorted by their number, since the name order puts 10of12 before 2of12
+			backupFiles[position].StripeFiles = append(backupFiles[position].StripeFiles, fileName)
+			slices.SortFunc(backupFiles[position].StripeFiles, func(a, b string) int {
+				_, stripeA, _, _, _ := model.ParseStripe(a)
+				_, stripeB, _, _, _ := model.ParseStripe(b)
+				return stripeA - stripeB
 			})
+			backupFiles[position].FileName = backupFiles[position].StripeFiles[0]
 		}
 	}
 
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+)
+
+func TestMoveTargets(t *testing.T) {
+	const dataPath, logPath = "D:/Data/", "L:/Logs/"
+
+	tests := []struct {
+		name      string
+		files     []model.DatabaseFile
+		overrides map[string]string
+		want      map[string]string // The target of each file, by its logical name
+		wantErr   bool
+	}{
+		{
+			name: "primary data and log files",
+			files: []model.DatabaseFile{
+				{LogicalName: "Sales_log", FileType: "LOG", FileId: 2},
+				{LogicalName: "Sales", FileType: "ROWS", FileId: 1},
+			},
+			want: map[string]string{"Sales": "D:/Data/sales.mdf", "Sales_log": "L:/Logs/sales.ldf"},
+		},
+		{
+			name: "secondary data and log files",
+			files: []model.DatabaseFile{
+				{LogicalName: "Sales", FileType: "ROWS", FileId: 1},
+				{LogicalName: "Sales_log", FileType: "LOG", FileId: 2},
+				{LogicalName: "Archive", FileType: "ROWS", FileId: 3},
+				{LogicalName: "Sales_log2", FileType: "LOG", FileId: 4},
+			},
+			want: map[string]string{"Sales": "D:/Data/sales.mdf", "Sales_log": "L:/Logs/sales.ldf", "Archive": "D:/Data/sales_Archive.ndf", "Sales_log2": "L:/Logs/sales_Sales_log2.ldf"},
+		},
+		{
+			name: "FILESTREAM and full-text directories",
+			files: []model.DatabaseFile{
+				{LogicalName: "Sales", FileType: "ROWS", FileId: 1},
+				{LogicalName: "Documents", FileType: "FILESTREAM", FileId: 65537},
+				{LogicalName: "Catalog", FileType: "FULLTEXT", FileId: 65538},
+			},
+			want: map[string]string{"Sales": "D:/Data/sales.mdf", "Documents": "D:/Data/sales_Documents", "Catalog": "D:/Data/sales_Catalog"},
+		},
+		{
+			name: "unsafe characters of the logical name",
+			files: []model.DatabaseFile{
+				{LogicalName: "Sales", FileType: "ROWS", FileId: 1},
+				{LogicalName: "Archive 2024/Q1", FileType: "ROWS", FileId: 3},
+			},
+			want: map[string]string{"Sales": "D:/Data/sales.mdf", "Archive 2024/Q1": "D:/Data/sales_Archive_2024_Q1.ndf"},
+		},
+		{
+			name: "names which collide once sanitized get the file ID",
+			files: []model.DatabaseFile{
+				{LogicalName: "Sales", FileType: "ROWS", FileId: 1},
+				{LogicalName: "Archive 1", FileType: "ROWS", FileId: 3},
+				{LogicalName: "Archive_1", FileType: "ROWS", FileId: 4},
+			},
+			want: map[string]string{"Sales": "D:/Data/sales.mdf", "Archive 1": "D:/Data/sales_Archive_1.ndf", "Archive_1": "D:/Data/sales_Archive_1_4.ndf"},
+		},
+		{
+			name: "names which differ only in case collide",
+			files: []model.DatabaseFile{
+				{LogicalName: "Sales", FileType: "ROWS", FileId: 1},
+				{LogicalName: "archive", FileType: "ROWS", FileId: 3},
+				{LogicalName: "ARCHIVE", FileType: "ROWS", FileId: 4},
+			},
+			want: map[string]string{"Sales": "D:/Data/sales.mdf", "archive": "D:/Data/sales_archive.ndf", "ARCHIVE": "D:/Data/sales_ARCHIVE_4.ndf"},
+		},
+		{
+			name: "FILESTREAM directory colliding with a data file name",
+			files: []model.DatabaseFile{
+				{LogicalName: "Sales", FileType: "ROWS", FileId: 1},
+				{LogicalName: "Docs", FileType: "FILESTREAM", FileId: 65537},
+				{LogicalName: "docs", FileType: "FILESTREAM", FileId: 65538},
+			},
+			want: map[string]string{"Sales": "D:/Data/sales.mdf", "Docs": "D:/Data/sales_Docs", "docs": "D:/Data/sales_docs_65538"},
+		},
+		{
+			name: "overrides",
+			files: []model.DatabaseFile{
+				{LogicalName: "Sales", FileType: "ROWS", FileId: 1},
+				{LogicalName: "Sales_log", FileType: "LOG", FileId: 2},
+			},
+			overrides: map[string]string{"Sales_log": "E:/FastLogs/sales.ldf"},
+			want:      map[string]string{"Sales": "D:/Data/sales.mdf", "Sales_log": "E:/FastLogs/sales.ldf"},
+		},
+		{
+			name:      "override of a file which is not in the backup",
+			files:     []model.DatabaseFile{{LogicalName: "Sales", FileType: "ROWS", FileId: 1}},
+			overrides: map[string]string{"Missing": "E:/missing.ndf"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			moves, err := moveTargets("sales", tt.files, dataPath, logPath, tt.overrides)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("moveTargets() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("moveTargets() error = %v", err)
+			}
+
+			if len(moves) != len(tt.want) {
+				t.Fatalf("moveTargets() = %v moves, want %v", len(moves), len(tt.want))
+			}
+			for _, move := range moves {
+				if move.To != tt.want[move.LogicalName] {
+					t.Errorf("target of %v = %q, want %q", move.LogicalName, move.To, tt.want[move.LogicalName])
+				}
+				if _, overridden := tt.overrides[move.LogicalName]; move.Override != overridden {
+					t.Errorf("override of %v = %v, want %v", move.LogicalName, move.Override, overridden)
+				}
+			}
+		})
+	}
+}
+
+func TestListBackupFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"sales.BAK", "sales=2025-07-18_02-00-00.dif", "sales=2025-07-18_03-00-00.TRN", "large=2025-07-18_02-00-00_1of2.trn", "large=2025-07-18_02-00-00_2of2.trn", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := (&DatabaseService{}).ListBackupFiles(dir)
+	if err != nil {
+		t.Fatalf("ListBackupFiles() error = %v", err)
+	}
+
+	want := map[string]model.BackupType{
+		"large=2025-07-18_02-00-00_1of2.trn": model.BackupLog,
+		"sales.BAK":                          model.BackupFull,
+		"sales=2025-07-18_02-00-00.dif":      model.BackupDifferential,
+		"sales=2025-07-18_03-00-00.TRN":      model.BackupLog,
+	}
+	got := make(map[string]model.BackupType, len(files))
+	for _, file := range files {
+		got[file.FileName] = file.BackupType
+	}
+	if len(got) != len(want) {
+		t.Errorf("ListBackupFiles() listed %v, want %v", got, want)
+	}
+	for fileName, backupType := range want {
+		if got[fileName] != backupType {
+			t.Errorf("ListBackupFiles() type of %v = %q, want %q", fileName, got[fileName], backupType)
+		}
+	}
+	for _, file := range files {
+		if file.Stripes > 0 && !slices.Equal(file.StripeFiles, []string{"large=2025-07-18_02-00-00_1of2.trn", "large=2025-07-18_02-00-00_2of2.trn"}) {
+			t.Errorf("ListBackupFiles() stripes = %v, want both stripes of the log backup", file.StripeFiles)
+		}
+	}
+}
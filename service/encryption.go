@@ -0,0 +1,225 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/db"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/repository"
+)
+
+// ListEncryptors gets the certificates and asymmetric keys of the master database which can encrypt the backups, with the warnings of each one:
+// no private key in the server, an expired certificate, or a private key which was never backed up
+func (ds *DatabaseService) ListEncryptors(key db.ConnKey) ([]model.BackupEncryptor, error) {
+	rp, err := ds.getRepository(key)
+	if err != nil {
+		slog.Error("Cannot connect to database: ", "Error: ", err)
+		return nil, fmt.Errorf("Connection failed. Try to /connect.\nDetails: %v", err.Error())
+	}
+
+	encryptors, err := rp.GetEncryptors()
+	if err != nil {
+		slog.Error("Cannot get the encryptors: ", "Error: ", err)
+		return nil, err
+	}
+
+	now := time.Now()
+	for key := range encryptors {
+		encryptors[key].Warnings = encryptorWarnings(encryptors[key], now)
+	}
+
+	return encryptors, nil
+}
+
+// Returns the warnings of the encryptor. Without a backup of its private key, the backups encrypted by it cannot be restored once the server is lost
+func encryptorWarnings(encryptor model.BackupEncryptor, now time.Time) []string {
+	var warnings []string
+
+	if encryptor.PrivateKeyEncryption == "NO_PRIVATE_KEY" {
+		warnings = append(warnings, fmt.Sprintf("The %v %v has no private key in the server, so it cannot encrypt the backups", encryptor.Type, encryptor.Name))
+	}
+	if encryptor.ExpiryDate != nil && encryptor.ExpiryDate.Before(now) {
+		warnings = append(warnings, fmt.Sprintf("The %v %v expired at %v", encryptor.Type, encryptor.Name, encryptor.ExpiryDate.Format(time.RFC3339)))
+	}
+	if encryptor.Type == model.EncryptorCertificate && encryptor.PrivateKeyEncryption != "NO_PRIVATE_KEY" && encryptor.PrivateKeyLastBackup == nil {
+		warnings = append(warnings, fmt.Sprintf("The private key of the certificate %v was never backed up. Without it, the backups encrypted by the certificate cannot be restored "+
+			"in another server, or once this one is lost. Back it up with BACKUP CERTIFICATE ... WITH PRIVATE KEY", encryptor.Name))
+	}
+
+	return warnings
+}
+
+// Checks if the encryptor of the backup options is in the master database and has its private key. Returns its warnings, like a private key which was never backed up.
+// The backup is not stopped when the encryptors cannot be read (VIEW DEFINITION on master)
+func checkBackupEncryptor(rp repository.DatabaseRepository, options model.BackupOptions) ([]string, error) {
+	if options.Encryption == nil {
+		return nil, nil
+	}
+
+	encryptorType, name := options.Encryption.Encryptor()
+	encryptors, err := rp.GetEncryptors()
+	if err != nil {
+		slog.Warn("Cannot check the encryptor of the backup", "Encryptor", name, "Error", err)
+		return nil, nil
+	}
+
+	for _, encryptor := range encryptors {
+		if encryptor.Type != encryptorType || encryptor.Name != name {
+			continue
+		}
+		if encryptor.PrivateKeyEncryption == "NO_PRIVATE_KEY" {
+			return nil, fmt.Errorf("%w: the %v %v has no private key in the server", ErrBackupEncryptorNotFound, encryptorType, name)
+		}
+
+		return encryptorWarnings(encryptor, time.Now()), nil
+	}
+
+	return nil, fmt.Errorf("%w: the %v %v does not exist in the master database. See /api/backup/encryptors", ErrBackupEncryptorNotFound, encryptorType, name)
+}
+
+// Checks if the encryptors of the encrypted backup sets are in the master database of the server, which RESTORE requires to read them.
+// Returns the error of the first backup set whose encryptor is missing. The restore is not stopped when the encryptors cannot be read
+func checkRestoreEncryptors(rp repository.DatabaseRepository, headers []model.BackupHeader) error {
+	encrypted := false
+	for _, header := range headers {
+		encrypted = encrypted || header.EncryptorThumbprint != ""
+	}
+	if !encrypted {
+		return nil
+	}
+
+	encryptors, err := rp.GetEncryptors()
+	if err != nil {
+		slog.Warn("Cannot check the encryptors of the backups", "Error", err)
+		return nil
+	}
+
+	thumbprints := make(map[string]bool, len(encryptors))
+	for _, encryptor := range encryptors {
+		thumbprints[strings.ToUpper(encryptor.Thumbprint)] = true
+	}
+
+	for _, header := range headers {
+		if header.EncryptorThumbprint == "" || thumbprints[header.EncryptorThumbprint] {
+			continue
+		}
+
+		return model.NewRejectedErr(model.RejectEncryptorNotFound, fmt.Errorf("%w: the backup %v is encrypted (%v) by the %v with the thumbprint 0x%v, which is not in the master database of the server. "+
+			"Create it from the backup of the certificate and its private key (CREATE CERTIFICATE ... FROM FILE = ... WITH PRIVATE KEY (...)) before the restore",
+			ErrBackupEncryptorNotFound, header.BackupPath, header.KeyAlgorithm, strings.ToLower(header.EncryptorType), header.EncryptorThumbprint))
+	}
+
+	return nil
+}
+
+// Returns the thumbprint of the certificate which encrypts the database of the backup with TDE, read from its files with RESTORE FILELISTONLY.
+// It is empty when the database is not encrypted with TDE
+func tdeThumbprint(files []model.BackupDataFile) string {
+	for _, file := range files {
+		if file.TDEThumbprint != nil {
+			return *file.TDEThumbprint
+		}
+	}
+
+	return ""
+}
+
+// Checks if the TDE certificates of the databases are in the master database of the server, which RESTORE DATABASE requires to recover them: without it, the restore
+// only fails at the end, after the data is copied. The databases whose certificate is missing are rejected, unless their restore options have the backup of the certificate,
+// which is then created before the restore (CreateTDECertificate). The restore is not stopped when the certificates cannot be read
+func checkTDECertificates(rp repository.DatabaseRepository, restoreDatabaseList []model.RestoreDb) ([]model.RestoreDb, []model.SqlErr) {
+	encrypted := false
+	for _, restoreDatabase := range restoreDatabaseList {
+		encrypted = encrypted || restoreDatabase.TDEThumbprint != ""
+	}
+	if !encrypted {
+		return restoreDatabaseList, nil
+	}
+
+	encryptors, err := rp.GetEncryptors()
+	if err != nil {
+		slog.Warn("Cannot check the TDE certificates of the backups", "Error", err)
+		return restoreDatabaseList, nil
+	}
+
+	thumbprints := make(map[string]bool, len(encryptors))
+	for _, encryptor := range encryptors {
+		thumbprints[strings.ToUpper(encryptor.Thumbprint)] = true
+	}
+
+	var rejected []model.SqlErr
+	checkedDatabaseList := make([]model.RestoreDb, 0, len(restoreDatabaseList))
+	for _, restoreDatabase := range restoreDatabaseList {
+		if restoreDatabase.TDEThumbprint != "" && !thumbprints[restoreDatabase.TDEThumbprint] {
+			if restoreDatabase.Options.TDECertificate == nil {
+				rejected = append(rejected, model.SqlErr{Database: restoreDatabase.Database.Name, Err: model.NewRejectedErr(model.RejectTDECertificateNotFound,
+					fmt.Errorf("%w: the database %v is encrypted with TDE by the certificate with the thumbprint 0x%v, which is not in the master database of the server. "+
+						"Send the backup of the certificate and its private key in the tdeCertificate restore option, or create it (CREATE CERTIFICATE ... FROM FILE = ... WITH PRIVATE KEY (...)) before the restore",
+						ErrTDECertificateNotFound, restoreDatabase.Database.Name, restoreDatabase.TDEThumbprint))})
+				continue
+			}
+			restoreDatabase.CreateTDECertificate = true
+		}
+		checkedDatabaseList = append(checkedDatabaseList, restoreDatabase)
+	}
+
+	return checkedDatabaseList, rejected
+}
+
+// Creates the TDE certificates which are not in the server from the restore options (see checkTDECertificates), and checks if each created certificate has the thumbprint
+// of the backup. The databases whose certificate cannot be created are rejected. A certificate shared by many databases is created once
+func createTDECertificates(rp repository.DatabaseRepository, restoreDatabaseList []model.RestoreDb) ([]model.RestoreDb, []model.SqlErr) {
+	var rejected []model.SqlErr
+	created := make(map[string]bool)
+	createdDatabaseList := make([]model.RestoreDb, 0, len(restoreDatabaseList))
+
+	for _, restoreDatabase := range restoreDatabaseList {
+		if !restoreDatabase.CreateTDECertificate || created[restoreDatabase.TDEThumbprint] {
+			createdDatabaseList = append(createdDatabaseList, restoreDatabase)
+			continue
+		}
+
+		err := createTDECertificate(rp, restoreDatabase.TDEThumbprint, *restoreDatabase.Options.TDECertificate)
+		if err != nil {
+			slog.Error("Cannot create the TDE certificate of the database", "Database", restoreDatabase.Database.Name, "Certificate", restoreDatabase.Options.TDECertificate.Name, "Error", err)
+			rejected = append(rejected, model.SqlErr{Database: restoreDatabase.Database.Name, Err: model.NewRejectedErr(model.RejectTDECertificateNotFound, err)})
+			continue
+		}
+
+		slog.Info("TDE certificate created", "Database", restoreDatabase.Database.Name, "Certificate", restoreDatabase.Options.TDECertificate.Name, "Thumbprint", restoreDatabase.TDEThumbprint)
+		created[restoreDatabase.TDEThumbprint] = true
+		createdDatabaseList = append(createdDatabaseList, restoreDatabase)
+	}
+
+	return createdDatabaseList, rejected
+}
+
+// Creates the certificate from its backup and checks if its thumbprint is the one of the TDE backup
+func createTDECertificate(rp repository.DatabaseRepository, thumbprint string, certificate model.TDECertificate) error {
+	err := rp.CreateCertificate(certificate)
+	if err != nil {
+		return fmt.Errorf("%w: cannot create the certificate %v from %v: %w", ErrTDECertificateNotFound, certificate.Name, certificate.CertificateFile, err)
+	}
+
+	encryptors, err := rp.GetEncryptors()
+	if err != nil {
+		return fmt.Errorf("%w: the certificate %v was created, but cannot be checked: %w", ErrTDECertificateNotFound, certificate.Name, err)
+	}
+
+	for _, encryptor := range encryptors {
+		if encryptor.Type != model.EncryptorCertificate || encryptor.Name != certificate.Name {
+			continue
+		}
+		if strings.ToUpper(encryptor.Thumbprint) != thumbprint {
+			return fmt.Errorf("%w: the certificate %v was created with the thumbprint 0x%v, but the backup is encrypted by the thumbprint 0x%v. "+
+				"Drop it (DROP CERTIFICATE) and send the backup of the certificate of the database", ErrTDECertificateNotFound, certificate.Name, encryptor.Thumbprint, thumbprint)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: the certificate %v was not found after it was created", ErrTDECertificateNotFound, certificate.Name)
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/db"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+)
+
+// GetBackupHistory reads the backup history which SQL Server records in msdb: one page of the backup sets of the filter, from the newest to the oldest,
+// and the last full, differential and log backups of each database. The backups are read from the history, not from the files, so they may have been moved or deleted
+func (ds *DatabaseService) GetBackupHistory(key db.ConnKey, filter model.HistoryFilter) (model.BackupHistory, error) {
+	err := filter.Validate()
+	if err != nil {
+		slog.Error("Cannot get the backup history. Invalid filter", "Error", err)
+		return model.BackupHistory{}, fmt.Errorf("%w: %w", ErrInvalidHistoryFilter, err)
+	}
+
+	rp, err := ds.getRepository(key)
+	if err != nil {
+		slog.Error("Cannot connect to database: ", "Error: ", err)
+		return model.BackupHistory{}, fmt.Errorf("Connection failed. Try to /connect.\nDetails: %v", err)
+	}
+
+	history := model.BackupHistory{Page: filter.Page, PageSize: filter.PageSize}
+
+	history.Backups, history.Total, err = rp.GetBackupHistory(filter)
+	if err != nil {
+		slog.Error("Cannot get the backup history (msdb.dbo.backupset): ", "Error: ", err)
+		return model.BackupHistory{}, err
+	}
+
+	history.LastBackups, err = rp.GetLastBackups(filter)
+	if err != nil {
+		slog.Error("Cannot get the last backups (msdb.dbo.backupset): ", "Error: ", err)
+		return model.BackupHistory{}, err
+	}
+	if history.LastBackups == nil {
+		history.LastBackups = []model.LastBackups{}
+	}
+
+	history.ServerTime, err = rp.GetServerTime()
+	if err != nil {
+		slog.Error("Cannot get the server time: ", "Error: ", err)
+		return model.BackupHistory{}, err
+	}
+
+	return history, nil
+}
+
+// GetRestoreHistory reads the restore history which SQL Server records in msdb: one page of the restores of the filter, from the newest to the oldest,
+// with who executed them, their options, the files restored and the restored backup set
+func (ds *DatabaseService) GetRestoreHistory(key db.ConnKey, filter model.HistoryFilter) (model.RestoreHistory, error) {
+	err := filter.Validate()
+	if err != nil {
+		slog.Error("Cannot get the restore history. Invalid filter", "Error", err)
+		return model.RestoreHistory{}, fmt.Errorf("%w: %w", ErrInvalidHistoryFilter, err)
+	}
+
+	rp, err := ds.getRepository(key)
+	if err != nil {
+		slog.Error("Cannot connect to database: ", "Error: ", err)
+		return model.RestoreHistory{}, fmt.Errorf("Connection failed. Try to /connect.\nDetails: %v", err)
+	}
+
+	history := model.RestoreHistory{Page: filter.Page, PageSize: filter.PageSize}
+
+	history.Restores, history.Total, err = rp.GetRestoreHistory(filter)
+	if err != nil {
+		slog.Error("Cannot get the restore history (msdb.dbo.restorehistory): ", "Error: ", err)
+		return model.RestoreHistory{}, err
+	}
+
+	history.ServerTime, err = rp.GetServerTime()
+	if err != nil {
+		slog.Error("Cannot get the server time: ", "Error: ", err)
+		return model.RestoreHistory{}, err
+	}
+
+	return history, nil
+}
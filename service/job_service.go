@@ -0,0 +1,687 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/repository"
+)
+
+var (
+	// ErrJobNotFound is returned when the job does not exist, already expired or belongs to another session.
+	ErrJobNotFound = errors.New("Job not found")
+	// ErrJobFinished is returned when a job which already finished is cancelled.
+	ErrJobFinished = errors.New("The job is already finished")
+	// ErrDatabaseNotCancellable is returned when the database to be cancelled is not part of the job, or already finished.
+	ErrDatabaseNotCancellable = errors.New("The database is not part of the job or is already finished")
+	// ErrOperationCancelled replaces the driver error of the databases which were cancelled.
+	ErrOperationCancelled = errors.New("The operation was cancelled")
+	// ErrInvalidOperationFilter is returned when the filter or the pagination of the operation history request are not valid.
+	ErrInvalidOperationFilter = errors.New("Invalid operation filter")
+)
+
+// Struct responsible for the job engine state. Backup and restore operations are registered as jobs, executed in background,
+// and their per-database state is kept in memory until the retention time after they finish.
+// Every job is also saved in the operation history when it is created, started and finished, by a single writer goroutine (see persist).
+type JobService struct {
+	mu        sync.RWMutex
+	jobs      map[string]*model.Job
+	controls  map[string]*jobControl
+	retention time.Duration
+	history   *repository.OperationStore
+
+	pendingMu sync.Mutex
+	pending   map[string]model.Job // The newest snapshot of each job which is not saved in the history yet
+	wake      chan struct{}
+}
+
+// jobControl holds the cancellation state of an unfinished job: the context of the whole job,
+// the cancel functions of the databases which already got their context, the databases which were cancelled and the channel closed when the job finishes
+type jobControl struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	databases map[string]context.CancelFunc
+	cancelled map[string]bool
+	done      chan struct{}
+}
+
+// Creates an instance of JobService struct. The operations of the history which were not finished, since the application stopped while they ran, are marked as finished
+func NewJobService(retention time.Duration, history *repository.OperationStore) *JobService {
+	js := &JobService{
+		jobs:      make(map[string]*model.Job),
+		controls:  make(map[string]*jobControl),
+		retention: retention,
+		history:   history,
+		pending:   make(map[string]model.Job),
+		wake:      make(chan struct{}, 1),
+	}
+	js.finishInterrupted()
+	go js.persist()
+
+	return js
+}
+
+// Creates a queued job, with one queued entry for each database. The template sets who and what created the job: its type, session, user, server, path, options and schedule
+func (js *JobService) Create(template model.Job, databases []string) model.Job {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	js.purge()
+
+	job := &model.Job{
+		ID:         newJobID(),
+		Type:       template.Type,
+		State:      model.JobQueued,
+		CreatedBy:  template.CreatedBy,
+		SessionID:  template.SessionID,
+		Server:     template.Server,
+		ScheduleID: template.ScheduleID,
+		Path:       template.Path,
+		Options:    template.Options,
+		Warnings:   append([]string(nil), template.Warnings...),
+		CreatedAt:  time.Now(),
+		Databases:  make([]model.JobDatabase, 0, len(databases)),
+	}
+
+	for _, database := range databases {
+		job.Databases = append(job.Databases, model.JobDatabase{Name: database, State: model.JobQueued})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	js.jobs[job.ID] = job
+	js.controls[job.ID] = &jobControl{ctx: ctx, cancel: cancel, databases: make(map[string]context.CancelFunc), cancelled: make(map[string]bool), done: make(chan struct{})}
+	slog.Info("Job created", "Job", job.ID, "Type", job.Type, "Databases", databases)
+	js.record(job)
+
+	return copyJob(job)
+}
+
+// Gets a snapshot of the job. Jobs created by a session are only visible to the same session.
+func (js *JobService) Get(id string, sessionID string) (model.Job, error) {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+
+	job, ok := js.jobs[id]
+	if !ok || (job.SessionID != "" && job.SessionID != sessionID) {
+		return model.Job{}, ErrJobNotFound
+	}
+
+	return copyJob(job), nil
+}
+
+// Lists snapshots of the jobs visible to the session, from the newest to the oldest
+func (js *JobService) List(sessionID string) []model.Job {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+
+	jobs := make([]model.Job, 0, len(js.jobs))
+	for _, job := range js.jobs {
+		if job.SessionID == "" || job.SessionID == sessionID {
+			jobs = append(jobs, copyJob(job))
+		}
+	}
+
+	slices.SortFunc(jobs, func(a, b model.Job) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+
+	return jobs
+}
+
+// Gets the context of the job, which is cancelled when the whole job is cancelled. It must be passed to the executor
+func (js *JobService) Context(id string) context.Context {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+
+	control, ok := js.controls[id]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return ctx
+	}
+
+	return control.ctx
+}
+
+// Done returns a channel which is closed when the job finishes. The channel of an unknown or finished job is already closed
+func (js *JobService) Done(id string) <-chan struct{} {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+
+	control, ok := js.controls[id]
+	if !ok {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+
+	return control.done
+}
+
+// Cancels the job, or only the given databases of the job. Queued databases are marked as cancelled at once, while the running ones are
+// marked when the executor returns, after their statement is aborted. Returns a snapshot of the job.
+// Jobs created by a session can only be cancelled by the same session.
+func (js *JobService) Cancel(id string, sessionID string, cancelledBy string, databases []string) (model.Job, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	job, ok := js.jobs[id]
+	if !ok || (job.SessionID != "" && job.SessionID != sessionID) {
+		return model.Job{}, ErrJobNotFound
+	}
+
+	control, ok := js.controls[id]
+	if !ok || job.Finished() {
+		return model.Job{}, ErrJobFinished
+	}
+
+	var entries []*model.JobDatabase
+	if len(databases) == 0 {
+		now := time.Now()
+		job.CancelledBy = cancelledBy
+		job.CancelledAt = &now
+		control.cancel()
+
+		for key := range job.Databases {
+			if job.Databases[key].FinishedAt == nil {
+				entries = append(entries, &job.Databases[key])
+			}
+		}
+	} else {
+		// Every database is checked before any of them is cancelled, so an invalid request does not cancel anything
+		for _, database := range databases {
+			entry := js.findDatabase(id, database)
+			if entry == nil {
+				return model.Job{}, fmt.Errorf("%w: %v", ErrDatabaseNotCancellable, database)
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	for _, entry := range entries {
+		control.cancelled[entry.Name] = true
+		entry.CancelledBy = cancelledBy
+
+		if cancel, ok := control.databases[entry.Name]; ok {
+			cancel()
+		}
+
+		if entry.State == model.JobQueued {
+			now := time.Now()
+			entry.State = model.JobCancelled
+			entry.Error = ErrOperationCancelled.Error()
+			entry.FinishedAt = &now
+		}
+	}
+
+	slog.Info("Job cancelled", "Job", job.ID, "Cancelled by", cancelledBy, "Databases", databases)
+
+	return copyJob(job), nil
+}
+
+// Marks the job as running
+func (js *JobService) Start(id string) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	job, ok := js.jobs[id]
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	job.State = model.JobRunning
+	job.StartedAt = &now
+	js.record(job)
+}
+
+// Marks a database which cannot be executed (e.g. it failed the validation) as failed, before the job starts
+func (js *JobService) Reject(id string, sqlErr model.SqlErr) {
+	js.databaseFinished(id, sqlErr.Database, sqlErr.Err)
+}
+
+// Marks the job as finished. Its final state depends on the state of its databases. The errors are the SqlErr list returned by the executor.
+func (js *JobService) Finish(id string, errs []model.SqlErr) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	job, ok := js.jobs[id]
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if job.StartedAt == nil {
+		job.StartedAt = &now
+	}
+	job.FinishedAt = &now
+	job.TotalTime = formatDuration(now.Sub(*job.StartedAt))
+
+	control := js.controls[id]
+	if control != nil {
+		// The driver errors of the cancelled databases are replaced, since they only describe the aborted statement
+		for key := range errs {
+			if control.cancelled[errs[key].Database] {
+				errs[key].Err = ErrOperationCancelled
+			}
+		}
+		control.cancel()
+		close(control.done)
+		delete(js.controls, id)
+	}
+	job.Errors = errs
+
+	var succeeded, failed, cancelled int
+	for key, database := range job.Databases {
+		switch database.State {
+		case model.JobSucceeded:
+			succeeded++
+		case model.JobFailed:
+			failed++
+		case model.JobCancelled:
+			cancelled++
+		default:
+			// A database which never reported its end cannot be considered done
+			if job.CancelledAt != nil || (control != nil && control.cancelled[database.Name]) {
+				job.Databases[key].State = model.JobCancelled
+				cancelled++
+			} else {
+				job.Databases[key].State = model.JobFailed
+				failed++
+			}
+		}
+	}
+
+	switch {
+	case job.CancelledAt != nil || (cancelled > 0 && succeeded == 0 && failed == 0):
+		job.State = model.JobCancelled
+	case failed == 0 && cancelled == 0:
+		job.State = model.JobSucceeded
+	case succeeded == 0:
+		job.State = model.JobFailed
+	default:
+		job.State = model.JobPartial
+	}
+
+	slog.Info("Job finished", "Job", job.ID, "State", job.State, "Succeeded", succeeded, "Failed", failed, "Cancelled", cancelled, "Total time", job.TotalTime)
+	js.record(job)
+}
+
+// Lists one page of the operation history, filtered, from the newest to the oldest operation
+func (js *JobService) Operations(filter model.OperationFilter) (model.Operations, error) {
+	err := filter.Validate()
+	if err != nil {
+		return model.Operations{}, fmt.Errorf("%w: %w", ErrInvalidOperationFilter, err)
+	}
+
+	jobs, total, err := js.history.List(filter, (filter.Page-1)*filter.PageSize, filter.PageSize)
+	if err != nil {
+		slog.Error("Cannot read the operation history", "Error", err)
+		return model.Operations{}, err
+	}
+
+	return model.Operations{Operations: jobs, Total: total, Page: filter.Page, PageSize: filter.PageSize}, nil
+}
+
+// Gets an operation of the history. The operations of every session are visible. While the job is kept in memory, its current state is returned,
+// since the history only records it when it is created, started and finished
+func (js *JobService) Operation(id string) (model.Job, error) {
+	js.mu.RLock()
+	job, ok := js.jobs[id]
+	var snapshot model.Job
+	if ok {
+		snapshot = copyJob(job)
+	}
+	js.mu.RUnlock()
+
+	if ok {
+		return snapshot, nil
+	}
+
+	return js.history.Get(id)
+}
+
+// Queues a snapshot of the job to be saved in the operation history by persist, so the lock of the jobs is never held while the history is written.
+// It is called with the lock held, so a newer snapshot of the job always replaces an older one which is still queued
+func (js *JobService) record(job *model.Job) {
+	js.pendingMu.Lock()
+	js.pending[job.ID] = copyJob(job)
+	js.pendingMu.Unlock()
+
+	select {
+	case js.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Saves the queued snapshots of the jobs in the operation history, one batch at a time, since it is the only writer of the jobs. Only the newest snapshot
+// of each job is saved, so the history never goes back to an older state. A failure is only logged, since the job itself is not affected
+func (js *JobService) persist() {
+	for range js.wake {
+		js.pendingMu.Lock()
+		snapshots := js.pending
+		js.pending = make(map[string]model.Job)
+		js.pendingMu.Unlock()
+
+		for _, job := range snapshots {
+			err := js.history.Save(job)
+			if err != nil {
+				slog.Error("Cannot save the job in the operation history", "Job", job.ID, "Error", err)
+			}
+		}
+	}
+}
+
+// Marks the operations of the history which were queued or running when the application stopped as finished: their unfinished databases
+// failed with model.ErrOperationInterrupted, and the operation state depends on the state of its databases, like in Finish
+func (js *JobService) finishInterrupted() {
+	jobs, err := js.history.Unfinished()
+	if err != nil {
+		slog.Error("Cannot read the unfinished operations of the history", "Error", err)
+		return
+	}
+
+	for _, job := range jobs {
+		var succeeded, failed, cancelled int
+		for key, database := range job.Databases {
+			switch database.State {
+			case model.JobSucceeded:
+				succeeded++
+			case model.JobCancelled:
+				cancelled++
+			case model.JobFailed:
+				failed++
+			default:
+				job.Databases[key].State = model.JobFailed
+				job.Databases[key].Error = model.ErrOperationInterrupted.Error()
+				job.Errors = append(job.Errors, model.SqlErr{Database: database.Name, Err: model.ErrOperationInterrupted})
+				failed++
+			}
+		}
+
+		switch {
+		case failed == 0 && cancelled == 0:
+			job.State = model.JobSucceeded
+		case succeeded == 0 && failed == 0:
+			job.State = model.JobCancelled
+		case succeeded == 0:
+			job.State = model.JobFailed
+		default:
+			job.State = model.JobPartial
+		}
+
+		// The real finish time is unknown, so the job is not given one
+		slog.Warn("Operation interrupted by the application stop", "Job", job.ID, "State", job.State)
+		js.record(&job)
+	}
+}
+
+// Observer returns the repository.OperationObserver which updates the per-database state of the job
+func (js *JobService) Observer(id string) repository.OperationObserver {
+	return jobObserver{jobs: js, jobID: id}
+}
+
+// Gets the SQL Server session ID of each running database of the job
+func (js *JobService) RunningSessions(id string) map[int]string {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+
+	sessions := make(map[int]string)
+
+	job, ok := js.jobs[id]
+	if !ok {
+		return sessions
+	}
+
+	for _, database := range job.Databases {
+		if database.State == model.JobRunning && database.SessionID != 0 {
+			sessions[database.SessionID] = database.Name
+		}
+	}
+
+	return sessions
+}
+
+// Updates the percent complete and the estimated remaining time of the running databases of the job, matching them by the SQL Server session ID
+func (js *JobService) UpdateProgress(id string, progressList []model.OperationProgress) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	job, ok := js.jobs[id]
+	if !ok {
+		return
+	}
+
+	for _, progress := range progressList {
+		for key := range job.Databases {
+			database := &job.Databases[key]
+			if database.State != model.JobRunning || database.SessionID != progress.SessionID {
+				continue
+			}
+
+			database.PercentComplete = progress.PercentComplete
+			database.EstimatedRemaining = formatDuration(time.Duration(progress.EstimatedCompletionTime) * time.Millisecond)
+		}
+	}
+}
+
+// Derives the context of a database from the executor context. If the database was already cancelled, the context is returned cancelled
+func (js *JobService) databaseContext(id string, ctx context.Context, database string) (context.Context, context.CancelFunc) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	control, ok := js.controls[id]
+	if !ok {
+		return ctx, cancel
+	}
+
+	if control.cancelled[database] {
+		cancel()
+	}
+	control.databases[database] = cancel
+
+	return ctx, func() {
+		cancel()
+
+		js.mu.Lock()
+		defer js.mu.Unlock()
+		delete(control.databases, database)
+	}
+}
+
+func (js *JobService) databaseStarted(id string, database string, sessionID int, files []string) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	entry := js.findDatabase(id, database)
+	if entry == nil {
+		return
+	}
+
+	now := time.Now()
+	entry.State = model.JobRunning
+	entry.SessionID = sessionID
+	entry.Files = files
+	entry.StartedAt = &now
+}
+
+// Records the RESTORE VERIFYONLY result of the database, which does not change its backup state
+func (js *JobService) databaseVerified(id string, database string, err error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	entry := js.findDatabase(id, database)
+	if entry == nil {
+		return
+	}
+
+	verified := err == nil
+	entry.Verified = &verified
+	if err != nil {
+		entry.VerifyError = err.Error()
+	}
+}
+
+func (js *JobService) databaseFinished(id string, database string, err error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	entry := js.findDatabase(id, database)
+	if entry == nil {
+		return
+	}
+
+	now := time.Now()
+	entry.FinishedAt = &now
+	if entry.StartedAt != nil {
+		entry.Duration = formatDuration(now.Sub(*entry.StartedAt))
+	}
+
+	entry.EstimatedRemaining = ""
+	control, ok := js.controls[id]
+	if err != nil && ok && control.cancelled[database] {
+		entry.State = model.JobCancelled
+		entry.Error = ErrOperationCancelled.Error()
+
+		// An aborted BACKUP may leave the files it was writing, which must be cleaned up
+		if js.jobs[id].Type == model.JobBackup && len(entry.Files) > 0 {
+			entry.PartialFiles = entry.Files
+			slog.Warn("Backup cancelled. The backup files may be partially written", "Job", id, "Database", database, "Files", entry.Files)
+		}
+	} else if err != nil {
+		entry.State = model.JobFailed
+		entry.Error = err.Error()
+		entry.Reason = model.Reason(err)
+	} else {
+		entry.State = model.JobSucceeded
+		entry.PercentComplete = 100
+	}
+}
+
+// Marks the database as waiting for the shipping of its backup files. Called before the database is reported as finished, so databaseShipped
+// finds the entry afterwards
+func (js *JobService) databaseShipping(id string, database string) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	entry := js.findDatabase(id, database)
+	if entry == nil {
+		return
+	}
+
+	entry.Shipping = true
+}
+
+// Records the shipping of the backup files of the database to the secondary location, which does not change its backup state.
+// A shipping error is also added to the warnings of the job
+func (js *JobService) databaseShipped(id string, database string, shipped []model.ShippedFile, err error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	job, ok := js.jobs[id]
+	if !ok {
+		return
+	}
+
+	var entry *model.JobDatabase
+	for key := range job.Databases {
+		if job.Databases[key].Name == database && job.Databases[key].Shipping {
+			entry = &job.Databases[key]
+			break
+		}
+	}
+	if entry == nil {
+		return
+	}
+
+	entry.Shipping = false
+	entry.Shipped = shipped
+	if err != nil {
+		entry.ShipError = err.Error()
+		js.jobs[id].Warnings = append(js.jobs[id].Warnings, fmt.Sprintf("The backup files of %v were not shipped: %v", database, err))
+	}
+}
+
+// Finds the first database entry with the name which is not finished yet. Must be called with the lock held
+func (js *JobService) findDatabase(id string, database string) *model.JobDatabase {
+	job, ok := js.jobs[id]
+	if !ok {
+		return nil
+	}
+
+	for key := range job.Databases {
+		if job.Databases[key].Name == database && job.Databases[key].FinishedAt == nil {
+			return &job.Databases[key]
+		}
+	}
+
+	return nil
+}
+
+// Removes the jobs finished before the retention time. Must be called with the lock held
+func (js *JobService) purge() {
+	for id, job := range js.jobs {
+		if job.FinishedAt != nil && time.Since(*job.FinishedAt) > js.retention {
+			delete(js.jobs, id)
+		}
+	}
+}
+
+// jobObserver implements repository.OperationObserver for one job
+type jobObserver struct {
+	jobs  *JobService
+	jobID string
+}
+
+func (jo jobObserver) DatabaseContext(ctx context.Context, database string) (context.Context, context.CancelFunc) {
+	return jo.jobs.databaseContext(jo.jobID, ctx, database)
+}
+
+func (jo jobObserver) DatabaseStarted(database string, sessionID int, files []string) {
+	jo.jobs.databaseStarted(jo.jobID, database, sessionID, files)
+}
+
+func (jo jobObserver) DatabaseVerified(database string, err error) {
+	jo.jobs.databaseVerified(jo.jobID, database, err)
+}
+
+func (jo jobObserver) DatabaseFinished(database string, err error) {
+	jo.jobs.databaseFinished(jo.jobID, database, err)
+}
+
+// Copies the job, so the snapshot returned to the caller is not changed by the running executor
+func copyJob(job *model.Job) model.Job {
+	snapshot := *job
+	snapshot.Databases = append([]model.JobDatabase(nil), job.Databases...)
+	for key := range snapshot.Databases {
+		snapshot.Databases[key].Files = append([]string(nil), job.Databases[key].Files...)
+		snapshot.Databases[key].PartialFiles = append([]string(nil), job.Databases[key].PartialFiles...)
+		snapshot.Databases[key].Shipped = append([]model.ShippedFile(nil), job.Databases[key].Shipped...)
+	}
+	snapshot.Errors = append([]model.SqlErr(nil), job.Errors...)
+	snapshot.Warnings = append([]string(nil), job.Warnings...)
+
+	return snapshot
+}
+
+func newJobID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Formats a duration as used in the API responses, like 0h2m8s
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%dh%dm%ds", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
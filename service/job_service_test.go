@@ -0,0 +1,51 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/repository"
+)
+
+func TestJobHistory(t *testing.T) {
+	history, err := repository.NewOperationStore(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+
+	js := NewJobService(time.Hour, history)
+
+	tests := []struct {
+		name   string
+		finish func(id string)
+		want   model.JobState
+	}{
+		{name: "created", finish: func(id string) {}, want: model.JobQueued},
+		{name: "started", finish: js.Start, want: model.JobRunning},
+		{name: "finished", finish: func(id string) {
+			js.Start(id)
+			js.Observer(id).DatabaseFinished("sales", nil)
+			js.Finish(id, nil)
+		}, want: model.JobSucceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := js.Create(model.Job{Type: model.JobBackup}, []string{"sales"})
+			tt.finish(job.ID)
+
+			// The snapshots are saved by the writer goroutine, so the newest one is only read once it is saved
+			var saved model.Job
+			for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+				saved, err = history.Get(job.ID)
+				if err == nil && saved.State == tt.want {
+					return
+				}
+			}
+			t.Errorf("history.Get() state = %v (error %v), want %v", saved.State, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/db"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/repository"
+)
+
+// Extensions of the backup files read by the restore chain planner
+var restoreChainExtensions = []string{".bak", ".dif", ".trn"}
+
+// PlanRestoreChain plans the point-in-time restore of the database, without executing it. It reads RESTORE HEADERONLY of each .bak/.dif/.trn file in the path,
+// orders the backups of the database by LSN and returns the chain: the latest full backup, the latest differential based on it and the log backups up to the STOPAT time.
+// Each step has the RESTORE statement which will be executed.
+func (ds *DatabaseService) PlanRestoreChain(key db.ConnKey, request model.RestoreChainPostRequired) (model.RestoreChain, error) {
+	rp, err := ds.getRepository(key)
+	if err != nil {
+		slog.Error("Cannot connect to database: ", "Error: ", err)
+		return model.RestoreChain{}, fmt.Errorf("Connection failed. Try to /connect.\nDetails: %v", err)
+	}
+
+	return ds.planRestoreChain(rp, request)
+}
+
+// RestoreChain plans the point-in-time restore of the database (see PlanRestoreChain) and starts its job. Returns the queued job and the chain, while the restore runs in background.
+// The whole chain is a single database of the job.
+func (ds *DatabaseService) RestoreChain(key db.ConnKey, createdBy string, request model.RestoreChainPostRequired) (model.Job, model.RestoreChain, error) {
+	rp, release, err := ds.acquireRepository(key)
+	if err != nil {
+		slog.Error("Cannot connect to database: ", "Error: ", err)
+		return model.Job{}, model.RestoreChain{}, fmt.Errorf("Connection failed. Try to /connect.\nDetails: %v", err)
+	}
+
+	chain, err := ds.planRestoreChain(rp, request)
+	if err != nil {
+		release()
+		return model.Job{}, model.RestoreChain{}, err
+	}
+
+	if chain.CreateTDECertificate {
+		err = createTDECertificate(rp, chain.TDEThumbprint, *chain.TDECertificate)
+		if err != nil {
+			release()
+			slog.Error("Cannot create the TDE certificate of the database", "Database", chain.TargetName, "Certificate", chain.TDECertificate.Name, "Error", err)
+			return model.Job{}, model.RestoreChain{}, err
+		}
+		slog.Info("TDE certificate created", "Database", chain.TargetName, "Certificate", chain.TDECertificate.Name, "Thumbprint", chain.TDEThumbprint)
+	}
+
+	job := ds.jobs.Create(ds.sessionJob(key, model.JobRestore, createdBy, request.Path, request), []string{chain.TargetName})
+
+	go func() {
+		defer release()
+		ds.jobs.Start(job.ID)
+		stopProgress := ds.sampleProgress(rp, job.ID)
+
+		slog.Info("Starting point-in-time restore...", "Job", job.ID, "Database", chain.TargetName, "Steps", len(chain.Steps), "StopAt", chain.StopAt)
+		err := rp.RestoreChain(ds.jobs.Context(job.ID), chain, ds.jobsCfg.RestoreTimeout.Std(), ds.jobs.Observer(job.ID))
+		stopProgress()
+		if err != nil {
+			ds.jobs.Finish(job.ID, []model.SqlErr{{Database: chain.TargetName, Err: err}})
+			slog.Warn("Point-in-time restore completed with errors: ", "Job", job.ID, "Database", chain.TargetName, "Error", err)
+			return
+		}
+
+		ds.jobs.Finish(job.ID, nil)
+		slog.Info("Point-in-time restore completed sucessfully: ", "Job", job.ID, "Database", chain.TargetName)
+	}()
+
+	return job, chain, nil
+}
+
+// Plans the restore chain. The database files are moved to the default data path and log path
+func (ds *DatabaseService) planRestoreChain(rp repository.DatabaseRepository, request model.RestoreChainPostRequired) (model.RestoreChain, error) {
+	if request.TargetName == "" {
+		request.TargetName = request.Database
+	}
+
+	for _, name := range []string{request.Database, request.TargetName} {
+		ok, err := regexp.MatchString(`^[a-zA-Z0-9_#$@.-]+$`, name)
+		if err != nil {
+			slog.Error("Cannot search string with regexp", "Error", err)
+			return model.RestoreChain{}, err
+		}
+		if !ok {
+			slog.Error("There is an invalid character in the database name", "Database", name)
+			return model.RestoreChain{}, fmt.Errorf("%w: there is an invalid character in the database name %v", ErrInvalidRestoreChain, name)
+		}
+	}
+
+	if model.IsSystemDatabase(request.TargetName) {
+		return model.RestoreChain{}, fmt.Errorf("%w: %w", ErrInvalidRestoreChain, ErrSystemDatabaseRestore)
+	}
+
+	if request.Replace && request.ConfirmReplace != request.TargetName {
+		return model.RestoreChain{}, fmt.Errorf("%w: confirmReplace: must be %q to overwrite the existing database with REPLACE", ErrInvalidRestoreChain, request.TargetName)
+	}
+	if request.TDECertificate != nil {
+		if err := request.TDECertificate.Validate(); err != nil {
+			return model.RestoreChain{}, fmt.Errorf("%w: tdeCertificate: %w", ErrInvalidRestoreChain, err)
+		}
+	}
+
+	ok, err := regexp.MatchString(`^[a-zA-Z0-9._\-/\\\s:(){}\[\]@#$%^&+=~]+$`, request.Path)
+	if err != nil {
+		slog.Error("Cannot search string with regexp", "Error", err)
+		return model.RestoreChain{}, err
+	}
+	if !ok {
+		slog.Error("There is an invalid character in the filesystem path", "Path", request.Path)
+		return model.RestoreChain{}, fmt.Errorf("%w: there is an invalid character in the filesystem path %v", ErrInvalidRestoreChain, request.Path)
+	}
+
+	stopAt, err := model.ParseStopAt(request.StopAt)
+	if err != nil {
+		return model.RestoreChain{}, fmt.Errorf("%w: %w", ErrInvalidRestoreChain, err)
+	}
+
+	chain := model.RestoreChain{Database: request.Database, TargetName: request.TargetName, Replace: request.Replace}
+	if stopAt != nil {
+		chain.StopAt = stopAt.Format(model.StopAtLayout)
+	}
+
+	// Without REPLACE, the restore of an existing database only fails at the first step, since the tail of its log has not been backed up
+	exists, err := rp.DatabaseExists(chain.TargetName)
+	if err != nil {
+		slog.Error("Cannot check if the database exists: ", "Database", chain.TargetName, "Error: ", err)
+		return model.RestoreChain{}, err
+	}
+	if exists && !chain.Replace {
+		return model.RestoreChain{}, fmt.Errorf("%w: the database %v already exists. Set replace, confirmed by the database name in confirmReplace, to overwrite it", ErrInvalidRestoreChain, chain.TargetName)
+	}
+
+	fileNames, err := ds.backupFolderFiles(request.Path)
+	if err != nil {
+		slog.Error("Cannot list the backup files of the restore chain", "Path", request.Path, "Error", err)
+		return model.RestoreChain{}, err
+	}
+
+	mediaSets, warnings := chainMediaSets(request.Path, fileNames)
+	chain.Warnings = warnings
+	if exists {
+		chain.Warnings = append(chain.Warnings, fmt.Sprintf("The existing database %v will be overwritten (REPLACE)", chain.TargetName))
+	}
+
+	var headers []model.BackupHeader
+	for _, mediaSet := range mediaSets {
+		fileHeaders, err := rp.GetBackupHeaders(mediaSet)
+		if err != nil {
+			chain.Warnings = append(chain.Warnings, fmt.Sprintf("Cannot read the header of %v: %v", mediaSet[0], err))
+			continue
+		}
+
+		for _, header := range fileHeaders {
+			if strings.EqualFold(header.DatabaseName, request.Database) {
+				headers = append(headers, header)
+			}
+		}
+	}
+
+	chain.Steps, err = buildRestoreChain(headers, stopAt)
+	if err != nil {
+		slog.Error("Cannot plan the restore chain", "Database", request.Database, "Path", request.Path, "Error", err)
+		return model.RestoreChain{}, err
+	}
+
+	stepHeaders := make([]model.BackupHeader, 0, len(chain.Steps))
+	for _, step := range chain.Steps {
+		stepHeaders = append(stepHeaders, step.BackupHeader)
+	}
+	err = checkRestoreEncryptors(rp, stepHeaders)
+	if err != nil {
+		slog.Error("Cannot plan the restore chain", "Database", request.Database, "Path", request.Path, "Error", err)
+		return model.RestoreChain{}, err
+	}
+
+	full := chain.Steps[0]
+	backupFilesData, err := rp.GetBackupFilesData([]model.ToBeRestoredDb{{Name: chain.TargetName, BackupPath: full.BackupPath, BackupPaths: full.BackupPaths}})
+	if err != nil {
+		slog.Warn("Cannot get backup files data (RESTORE FILELISTONLY): ", "Error: ", err)
+		return model.RestoreChain{}, err
+	}
+	for _, backupFileData := range backupFilesData {
+		chain.Files = append(chain.Files, databaseFiles(backupFileData.BackupFileInfo)...)
+
+		checked, tdeErrors := checkTDECertificates(rp, []model.RestoreDb{{
+			Database:      model.Database{Name: chain.TargetName},
+			Options:       model.RestoreOptions{TDECertificate: request.TDECertificate},
+			TDEThumbprint: tdeThumbprint(backupFileData.BackupFileInfo),
+		}})
+		if len(tdeErrors) > 0 {
+			slog.Error("Cannot plan the restore chain", "Database", request.Database, "Path", request.Path, "Error", tdeErrors[0].Err)
+			return model.RestoreChain{}, tdeErrors[0].Err
+		}
+
+		if checked[0].CreateTDECertificate {
+			chain.TDEThumbprint = checked[0].TDEThumbprint
+			chain.TDECertificate = request.TDECertificate
+			chain.CreateTDECertificate = true
+			chain.Warnings = append(chain.Warnings, fmt.Sprintf("The database is encrypted with TDE by the certificate with the thumbprint 0x%v, which is not in the server. "+
+				"The certificate %v will be created from %v before the restore", chain.TDEThumbprint, chain.TDECertificate.Name, chain.TDECertificate.CertificateFile))
+		}
+	}
+
+	dataPath, logPath, err := rp.GetDefaultFilesPath()
+	if err != nil {
+		slog.Error("Cannot get default files path: ", "Error: ", err)
+		return model.RestoreChain{}, err
+	}
+
+	chain.Moves, err = moveTargets(chain.TargetName, chain.Files, dataPath, logPath, nil)
+	if err != nil {
+		return model.RestoreChain{}, err
+	}
+
+	for key := range chain.Steps {
+		chain.Steps[key].Statement = repository.RestoreChainStatement(chain, chain.Steps[key])
+	}
+
+	return chain, nil
+}
+
+// Groups the .bak, .dif and .trn files of the path as media sets: the stripes of a striped backup are one media set. Incomplete stripe sets are skipped, with a warning
+func chainMediaSets(path string, fileNames []string) ([][]string, []string) {
+	if !strings.HasSuffix(path, "/") && !strings.HasSuffix(path, "\\") {
+		path += "/"
+	}
+
+	var mediaSets [][]string
+	var warnings []string
+	// Stripes found of each stripe set, by its base name, stripe count and extension
+	stripeSets := make(map[string][]string)
+	var stripeSetKeys []string
+
+	for _, fileName := range fileNames {
+		if !slices.Contains(restoreChainExtensions, strings.ToLower(filepath.Ext(fileName))) {
+			continue
+		}
+
+		baseName, _, count, extension, ok := model.ParseStripe(fileName)
+		if !ok {
+			mediaSets = append(mediaSets, []string{path + fileName})
+			continue
+		}
+
+		setKey := fmt.Sprintf("%s|%d|%s", baseName, count, extension)
+		if _, found := stripeSets[setKey]; !found {
+			stripeSetKeys = append(stripeSetKeys, setKey)
+		}
+		stripeSets[setKey] = append(stripeSets[setKey], fileName)
+	}
+
+	for _, setKey := range stripeSetKeys {
+		stripeFiles := stripeSets[setKey]
+		baseName, _, count, extension, _ := model.ParseStripe(stripeFiles[0])
+		if len(stripeFiles) != count {
+			warnings = append(warnings, fmt.Sprintf("The stripe set %v has %d of %d stripes and was skipped", baseName+extension, len(stripeFiles), count))
+			continue
+		}
+
+		mediaSet := make([]string, 0, count)
+		for stripe := 1; stripe <= count; stripe++ {
+			mediaSet = append(mediaSet, path+model.StripeFileName(baseName, stripe, count, extension))
+		}
+		mediaSets = append(mediaSets, mediaSet)
+	}
+
+	return mediaSets, warnings
+}
+
+// Builds the restore chain from the backup headers of one database: the latest full backup finished before stopAt, the latest differential based on it
+// (its DifferentialBaseLSN is the CheckpointLSN of the full backup) and the log backups which continue the LSN chain, up to the first one finished after stopAt.
+// Without stopAt, every log backup of the chain is restored. The last step is restored WITH RECOVERY.
+func buildRestoreChain(headers []model.BackupHeader, stopAt *time.Time) ([]model.RestoreChainStep, error) {
+	finishedBeforeStopAt := func(header model.BackupHeader) bool {
+		return stopAt == nil || !header.BackupFinishDate.After(*stopAt)
+	}
+
+	var full, differential *model.BackupHeader
+	var logs []model.BackupHeader
+
+	for key, header := range headers {
+		switch header.Kind() {
+		case model.BackupFull:
+			if finishedBeforeStopAt(header) && (full == nil || model.CompareLSN(header.LastLSN, full.LastLSN) > 0) {
+				full = &headers[key]
+			}
+		case model.BackupLog:
+			logs = append(logs, header)
+		}
+	}
+
+	if full == nil {
+		if stopAt != nil {
+			return nil, fmt.Errorf("%w (before %v)", ErrNoFullBackup, stopAt.Format(model.StopAtLayout))
+		}
+		return nil, ErrNoFullBackup
+	}
+
+	for key, header := range headers {
+		if header.Kind() != model.BackupDifferential || !finishedBeforeStopAt(header) {
+			continue
+		}
+
+		base := header.DifferentialBaseLSN
+		if base == "" {
+			base = header.DatabaseBackupLSN
+		}
+		if model.CompareLSN(base, full.CheckpointLSN) == 0 && (differential == nil || model.CompareLSN(header.LastLSN, differential.LastLSN) > 0) {
+			differential = &headers[key]
+		}
+	}
+
+	steps := []model.RestoreChainStep{{BackupHeader: *full}}
+	lastLSN := full.LastLSN
+	if differential != nil {
+		steps = append(steps, model.RestoreChainStep{BackupHeader: *differential})
+		lastLSN = differential.LastLSN
+	}
+
+	slices.SortFunc(logs, func(a, b model.BackupHeader) int {
+		return model.CompareLSN(a.FirstLSN, b.FirstLSN)
+	})
+
+	// Each log backup must contain the last LSN restored so far: FirstLSN <= lastLSN < LastLSN
+	reachedStopAt := false
+	for _, logBackup := range logs {
+		if reachedStopAt {
+			break
+		}
+		if model.CompareLSN(logBackup.LastLSN, lastLSN) <= 0 {
+			continue
+		}
+		if model.CompareLSN(logBackup.FirstLSN, lastLSN) > 0 {
+			return nil, fmt.Errorf("%w after LSN %v: the next log backup (%v) starts at LSN %v", ErrRestoreChainGap, lastLSN, logBackup.BackupPath, logBackup.FirstLSN)
+		}
+
+		steps = append(steps, model.RestoreChainStep{BackupHeader: logBackup})
+		lastLSN = logBackup.LastLSN
+		reachedStopAt = stopAt != nil && !logBackup.BackupFinishDate.Before(*stopAt)
+	}
+
+	if stopAt != nil && !reachedStopAt {
+		last := steps[len(steps)-1]
+		return nil, fmt.Errorf("%w %v. The chain ends at %v (%v)", ErrStopAtNotCovered, stopAt.Format(model.StopAtLayout), last.BackupFinishDate.Format(model.StopAtLayout), last.BackupPath)
+	}
+
+	steps[len(steps)-1].Recovery = true
+	return steps, nil
+}
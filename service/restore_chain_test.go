@@ -0,0 +1,95 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+)
+
+// The backup finish times of the tests, on 2025-07-18
+func at(hour int, minute int) time.Time {
+	return time.Date(2025, 7, 18, hour, minute, 0, 0, time.UTC)
+}
+
+func fullHeader(path string, firstLSN string, lastLSN string, checkpointLSN string, finish time.Time) model.BackupHeader {
+	return model.BackupHeader{BackupPath: path, BackupType: 1, FirstLSN: firstLSN, LastLSN: lastLSN, CheckpointLSN: checkpointLSN, BackupFinishDate: finish}
+}
+
+func differentialHeader(path string, baseLSN string, firstLSN string, lastLSN string, finish time.Time) model.BackupHeader {
+	return model.BackupHeader{BackupPath: path, BackupType: 5, DifferentialBaseLSN: baseLSN, FirstLSN: firstLSN, LastLSN: lastLSN, BackupFinishDate: finish}
+}
+
+func logHeader(path string, firstLSN string, lastLSN string, finish time.Time) model.BackupHeader {
+	return model.BackupHeader{BackupPath: path, BackupType: 2, FirstLSN: firstLSN, LastLSN: lastLSN, BackupFinishDate: finish}
+}
+
+func TestBuildRestoreChain(t *testing.T) {
+	full := fullHeader("full.bak", "100", "200", "150", at(1, 0))
+	olderFull := fullHeader("older.bak", "10", "60", "50", at(0, 0))
+	laterFull := fullHeader("later.bak", "500", "600", "550", at(5, 0))
+	differential := differentialHeader("diff.dif", "150", "250", "350", at(2, 0))
+	olderDifferential := differentialHeader("older.dif", "150", "220", "260", at(1, 30))
+	otherBaseDifferential := differentialHeader("other.dif", "50", "240", "380", at(2, 30))
+	log1 := logHeader("log1.trn", "100", "300", at(1, 45))
+	log2 := logHeader("log2.trn", "300", "400", at(3, 0))
+	log3 := logHeader("log3.trn", "400", "500", at(4, 0))
+	gapLog := logHeader("gap.trn", "450", "520", at(4, 0))
+
+	stopAt := func(hour int, minute int) *time.Time {
+		value := at(hour, minute)
+		return &value
+	}
+
+	tests := []struct {
+		name      string
+		headers   []model.BackupHeader
+		stopAt    *time.Time
+		wantSteps []string
+		wantErr   error
+	}{
+		{"full backup only", []model.BackupHeader{full}, nil, []string{"full.bak"}, nil},
+		{"full backup and every log", []model.BackupHeader{log3, full, log1, log2}, nil, []string{"full.bak", "log1.trn", "log2.trn", "log3.trn"}, nil},
+		{"latest full backup", []model.BackupHeader{olderFull, full, log1}, nil, []string{"full.bak", "log1.trn"}, nil},
+		{"latest differential of the full backup", []model.BackupHeader{full, olderDifferential, differential, log1, log2, log3}, nil, []string{"full.bak", "diff.dif", "log2.trn", "log3.trn"}, nil},
+		{"differential of another full backup", []model.BackupHeader{full, otherBaseDifferential, log1, log2}, nil, []string{"full.bak", "log1.trn", "log2.trn"}, nil},
+		{"differential base read from the database backup LSN", []model.BackupHeader{full, {BackupPath: "diff.dif", BackupType: 5, DatabaseBackupLSN: "150", FirstLSN: "250", LastLSN: "350", BackupFinishDate: at(2, 0)}, log2},
+			nil, []string{"full.bak", "diff.dif", "log2.trn"}, nil},
+		{"no full backup", []model.BackupHeader{differential, log1}, nil, nil, ErrNoFullBackup},
+		{"gap in the log chain", []model.BackupHeader{full, log1, log2, gapLog}, nil, nil, ErrRestoreChainGap},
+		{"gap right after the full backup", []model.BackupHeader{full, log2}, nil, nil, ErrRestoreChainGap},
+		{"stop at a log backup", []model.BackupHeader{full, log1, log2, log3}, stopAt(2, 30), []string{"full.bak", "log1.trn", "log2.trn"}, nil},
+		{"stop at skips later full and differential backups", []model.BackupHeader{full, laterFull, differential, log1, log2, log3}, stopAt(1, 50), []string{"full.bak", "log1.trn", "log2.trn"}, nil},
+		{"stop at before every full backup", []model.BackupHeader{full, log1}, stopAt(0, 30), nil, ErrNoFullBackup},
+		{"stop at after the last log backup", []model.BackupHeader{full, log1, log2}, stopAt(3, 30), nil, ErrStopAtNotCovered},
+		{"stop at without log backups", []model.BackupHeader{full}, stopAt(1, 30), nil, ErrStopAtNotCovered},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, err := buildRestoreChain(slices.Clone(tt.headers), tt.stopAt)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("buildRestoreChain() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildRestoreChain() error = %v", err)
+			}
+
+			var paths []string
+			for key, step := range steps {
+				paths = append(paths, step.BackupPath)
+				if step.Recovery != (key == len(steps)-1) {
+					t.Errorf("step %v (%v) recovery = %v, only the last step is restored WITH RECOVERY", key, step.BackupPath, step.Recovery)
+				}
+			}
+			if !slices.Equal(paths, tt.wantSteps) {
+				t.Fatalf("buildRestoreChain() = %v, want %v", paths, tt.wantSteps)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/db"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/repository"
+)
+
+// PlanRestore is the dry-run of RestoreDatabase: it runs the same validation, RESTORE FILELISTONLY and default paths steps, without executing the restore.
+// For each database, it returns the RESTORE DATABASE statement, the file moves, if the database already exists and the space required by its files against the free space
+// of their volumes (sys.dm_os_volume_stats), with warnings about what may fail or be overwritten. The databases which cannot be restored are returned as errors
+func (ds *DatabaseService) PlanRestore(key db.ConnKey, restoreDbList []model.ToBeRestoredDb) ([]model.RestorePlan, []model.SqlErr, error) {
+	err := validateRestoreOptions(restoreDbList)
+	if err != nil {
+		slog.Error("Cannot plan the restore. Invalid restore options", "Error", err)
+		return nil, nil, err
+	}
+
+	rp, err := ds.getRepository(key)
+	if err != nil {
+		slog.Error("Cannot connect to database: ", "Error: ", err)
+		return nil, nil, fmt.Errorf("Connection failed. Try to /connect.\nDetails: %v", err)
+	}
+
+	restoreDatabaseList, sanitizedErrors, dataPath, logPath, err := planRestore(rp, restoreDbList, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Without the VIEW SERVER STATE permission the volumes cannot be read, which only skips the free space check
+	volumes, volumesErr := rp.GetVolumes()
+	if volumesErr != nil {
+		slog.Warn("Cannot get the volumes free space (sys.dm_os_volume_stats): ", "Error: ", volumesErr)
+	}
+
+	plans := make([]model.RestorePlan, 0, len(restoreDatabaseList))
+	for _, restoreDatabase := range restoreDatabaseList {
+		plan := model.RestorePlan{
+			Database:    restoreDatabase.Database.Name,
+			BackupPaths: restoreDatabase.MediaSet(),
+			Options:     restoreDatabase.Options,
+			Statement:   repository.RestoreStatement(restoreDatabase),
+			Moves:       restoreDatabase.Moves,
+			DataPath:    dataPath,
+			LogPath:     logPath,
+		}
+
+		plan.Exists, err = rp.DatabaseExists(plan.Database)
+		if err != nil {
+			slog.Error("Cannot check if the database exists: ", "Database", plan.Database, "Error: ", err)
+			return nil, nil, err
+		}
+		switch {
+		case plan.Exists && plan.Options.Replace:
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("The existing database %v will be overwritten (REPLACE)", plan.Database))
+		case plan.Exists:
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("The database %v already exists. The restore will fail unless REPLACE is set", plan.Database))
+		}
+
+		if restoreDatabase.CreateTDECertificate {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("The database is encrypted with TDE by the certificate with the thumbprint 0x%v, which is not in the server. "+
+				"The certificate %v will be created from %v before the restore", restoreDatabase.TDEThumbprint, plan.Options.TDECertificate.Name, plan.Options.TDECertificate.CertificateFile))
+		}
+
+		switch plan.Options.Mode() {
+		case model.RestoreNoRecovery:
+			plan.Warnings = append(plan.Warnings, "The database will be left RESTORING (NORECOVERY) until more backups are restored or it is recovered")
+		case model.RestoreStandby:
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("The database will be left read-only (STANDBY), with the undo file %v", plan.Options.StandbyFile))
+		}
+
+		targets := make([]volumeTarget, 0, len(plan.Moves))
+		for _, move := range plan.Moves {
+			plan.RequiredBytes += move.Size
+			targets = append(targets, volumeTarget{path: move.To, size: move.Size})
+		}
+
+		if volumesErr != nil {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("The free space of the volumes was not checked: %v", volumesErr))
+		} else {
+			var warnings []string
+			plan.Volumes, warnings = volumeUsage(targets, volumes)
+			plan.Warnings = append(plan.Warnings, warnings...)
+		}
+
+		plans = append(plans, plan)
+	}
+
+	return plans, sanitizedErrors, nil
+}
+
+// A file written by a planned restore or backup, and its size in bytes
+type volumeTarget struct {
+	path string
+	size int64
+}
+
+// Sums the size of the files by the volume of their path, returning the volumes used, with RequiredBytes set, and a warning for each volume without enough free space.
+// The volume of a path is the one with the longest mount point which contains it; paths out of the known volumes are warned too
+func volumeUsage(targets []volumeTarget, volumes []model.VolumeSpace) ([]model.VolumeSpace, []string) {
+	var used []model.VolumeSpace
+	var warnings []string
+
+	for _, target := range targets {
+		index := -1
+		for i, volume := range volumes {
+			if volumeContains(volume.MountPoint, target.path) && (index < 0 || len(volume.MountPoint) > len(volumes[index].MountPoint)) {
+				index = i
+			}
+		}
+		if index < 0 {
+			warnings = append(warnings, fmt.Sprintf("The volume of %v is unknown, so its free space was not checked", target.path))
+			continue
+		}
+
+		usedIndex := -1
+		for i, volume := range used {
+			if volume.MountPoint == volumes[index].MountPoint {
+				usedIndex = i
+			}
+		}
+		if usedIndex < 0 {
+			used = append(used, volumes[index])
+			usedIndex = len(used) - 1
+		}
+		used[usedIndex].RequiredBytes += target.size
+	}
+
+	for _, volume := range used {
+		if volume.RequiredBytes > volume.AvailableBytes {
+			warnings = append(warnings, fmt.Sprintf("Not enough free space on %v: %v required, %v available", volume.MountPoint, formatBytes(volume.RequiredBytes), formatBytes(volume.AvailableBytes)))
+		}
+	}
+
+	return used, warnings
+}
+
+// Reports if the path is inside the mount point. Windows paths are compared case-insensitively and with any separator
+func volumeContains(mountPoint string, path string) bool {
+	normalize := func(value string) string {
+		value = strings.ToLower(strings.ReplaceAll(value, `\`, "/"))
+		if !strings.HasSuffix(value, "/") {
+			value += "/"
+		}
+		return value
+	}
+
+	return strings.HasPrefix(normalize(path), normalize(mountPoint))
+}
+
+// Formats a size in bytes with a binary unit, like 1.5 GB
+func formatBytes(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	return fmt.Sprintf("%.1f %v", value, units[unit])
+}
@@ -0,0 +1,355 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/db"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/repository"
+)
+
+// ApplyRetention deletes the backup sets of the backup folder which are not kept by the retention policy (see model.RetentionPolicy), or only lists them in a dry run.
+// The backups are found by the name of their files (see model.ParseBackupFileName), so the files which do not follow the naming are never deleted,
+// nor the striped backups whose stripes are not all in the folder. The connection of the session tells the copy-only full backups apart, which are not the base of the differential and log backups.
+// Every deletion is recorded in the audit log (audit.log)
+func (ds *DatabaseService) ApplyRetention(key db.ConnKey, requestedBy string, request model.RetentionPostRequired) (model.Retention, error) {
+	err := request.Policy.Validate()
+	if err == nil && request.Path == "" {
+		err = errors.New("path: required")
+	}
+	if err == nil && model.IsURLPath(request.Path) {
+		err = errors.New("path: only the backups of disk folders are deleted by the retention")
+	}
+	if err != nil {
+		slog.Error("Cannot apply the retention. Invalid request", "Error", err)
+		return model.Retention{}, fmt.Errorf("%w: %w", ErrInvalidRetention, err)
+	}
+
+	rp, err := ds.getRepository(key)
+	if err != nil {
+		slog.Error("Cannot connect to database: ", "Error: ", err)
+		return model.Retention{}, fmt.Errorf("Connection failed. Try to /connect.\nDetails: %v", err)
+	}
+
+	match := func(database string) bool {
+		return len(request.Databases) == 0 || slices.Contains(request.Databases, database)
+	}
+
+	return ds.applyRetention(&rp, request.Path, request.Policy, match, request.DryRun, requestedBy, "")
+}
+
+// Applies the retention policy to the backups of the folder whose database is matched. The repository reads which full backups are copy-only
+// (see readCopyOnly). The scheduleID is recorded in the audit log of the retention of a schedule
+func (ds *DatabaseService) applyRetention(rp *repository.DatabaseRepository, path string, policy model.RetentionPolicy, match func(database string) bool, dryRun bool, requestedBy string, scheduleID string) (model.Retention, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		slog.Error("Cannot apply the retention. Cannot read the backup folder", "Path", path, "Error", err)
+		return model.Retention{}, fmt.Errorf("%w: path: %w", ErrInvalidRetention, err)
+	}
+
+	retention := model.Retention{Path: path, DryRun: dryRun, Policy: policy, Kept: []model.RetentionBackupSet{}, Deleted: []model.RetentionBackupSet{}}
+
+	// The stripes of a striped backup have the same database, backup type and time, so they are grouped in one backup set
+	sets := make(map[string]*model.RetentionBackupSet)
+	stripeCounts := make(map[string]int)
+	fileSizes := make(map[string]int64)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		database, backupType, backupTime, ok := model.ParseBackupFileName(entry.Name())
+		if !ok {
+			if slices.Contains([]string{".bak", ".dif", ".trn"}, strings.ToLower(filepath.Ext(entry.Name()))) {
+				retention.Ignored = append(retention.Ignored, entry.Name())
+			}
+			continue
+		}
+		if !match(database) {
+			continue
+		}
+
+		var size int64
+		if info, err := entry.Info(); err == nil {
+			size = info.Size()
+		}
+		fileSizes[entry.Name()] = size
+
+		key := fmt.Sprintf("%s|%s|%s", database, backupType, backupTime.Format(model.BackupFileTimeLayout))
+		set, found := sets[key]
+		if !found {
+			set = &model.RetentionBackupSet{Database: database, BackupType: backupType, BackupTime: backupTime}
+			sets[key] = set
+		}
+		set.Files = append(set.Files, entry.Name())
+		set.Size += size
+		if _, _, count, _, striped := model.ParseStripe(entry.Name()); striped {
+			stripeCounts[key] = count
+		}
+	}
+
+	ordered := make([]*model.RetentionBackupSet, 0, len(sets))
+	for key, set := range sets {
+		slices.Sort(set.Files)
+		// The other stripes were written to other directories (see model.BackupOptions.StripeDirectories), which the retention does not read,
+		// so the set is never deleted: deleting only the stripes of the folder would leave the others behind
+		if len(set.Files) < stripeCounts[key] {
+			retention.Ignored = append(retention.Ignored, set.Files...)
+			continue
+		}
+		ordered = append(ordered, set)
+	}
+	slices.Sort(retention.Ignored)
+	// By database, from the newest to the oldest backup
+	slices.SortFunc(ordered, func(a, b *model.RetentionBackupSet) int {
+		if a.Database != b.Database {
+			return strings.Compare(a.Database, b.Database)
+		}
+		return b.BackupTime.Compare(a.BackupTime)
+	})
+
+	readCopyOnly(rp, path, ordered)
+	retainBackupSets(ordered, policy, time.Now())
+
+	var auditLogger *slog.Logger
+	if !dryRun {
+		auditLogFile, err := os.OpenFile("audit.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			slog.Error("Cannot open audit log file: ", "Error: ", err)
+		}
+		defer auditLogFile.Close()
+
+		auditLogger = slog.New(slog.NewJSONHandler(auditLogFile, &slog.HandlerOptions{
+			AddSource: true,
+			Level:     slog.LevelInfo,
+		}))
+	}
+
+	for _, set := range ordered {
+		if len(set.Reasons) > 0 {
+			retention.Kept = append(retention.Kept, *set)
+			continue
+		}
+
+		if dryRun {
+			retention.Deleted = append(retention.Deleted, *set)
+			retention.FreedBytes += set.Size
+			continue
+		}
+
+		var errs []string
+		for _, file := range set.Files {
+			filePath := filepath.Join(path, file)
+			err := os.Remove(filePath)
+			if err != nil {
+				errs = append(errs, err.Error())
+				auditLogger.Error("Backup file cannot be deleted by the retention", "File", filePath, "Database", set.Database, "Backup type", set.BackupType, "Backup time", set.BackupTime, "Requested by", requestedBy, "Schedule", scheduleID, "Error", err)
+				continue
+			}
+
+			retention.FreedBytes += fileSizes[file]
+			auditLogger.Info("Backup file deleted by the retention", "File", filePath, "Database", set.Database, "Backup type", set.BackupType, "Backup time", set.BackupTime, "Size", fileSizes[file], "Policy", policy, "Requested by", requestedBy, "Schedule", scheduleID)
+		}
+
+		if len(errs) > 0 {
+			set.Error = strings.Join(errs, "; ")
+			retention.Failed = append(retention.Failed, *set)
+			continue
+		}
+		retention.Deleted = append(retention.Deleted, *set)
+	}
+
+	slog.Info("Retention applied", "Path", path, "Dry run", dryRun, "Policy", policy, "Kept", len(retention.Kept), "Deleted", len(retention.Deleted), "Failed", len(retention.Failed), "Freed bytes", retention.FreedBytes, "Requested by", requestedBy, "Schedule", scheduleID)
+	return retention, nil
+}
+
+// Reads whether each full backup set is copy-only: from msdb.dbo.backupset, by the name of its files, or from RESTORE HEADERONLY for the backups which msdb
+// has no record of, like the ones of another server or whose history was deleted. The sets which cannot be read are left unknown (nil CopyOnly)
+func readCopyOnly(rp *repository.DatabaseRepository, path string, sets []*model.RetentionBackupSet) {
+	var fulls []*model.RetentionBackupSet
+	since := time.Now()
+	for _, set := range sets {
+		if set.BackupType == model.BackupFull {
+			fulls = append(fulls, set)
+			if set.BackupTime.Before(since) {
+				since = set.BackupTime
+			}
+		}
+	}
+	if len(fulls) == 0 {
+		return
+	}
+
+	// The names have the time of the MaestroSQL host, which may be in another time zone than the server, so the history of the day before is read too
+	copyOnly := make(map[string]bool)
+	entries, err := rp.GetFullBackups(since.AddDate(0, 0, -1))
+	if err != nil {
+		slog.Warn("Cannot read the full backups of msdb. The backup files are read instead", "Error", err)
+	}
+	for _, entry := range entries {
+		for _, device := range entry.Devices {
+			name := strings.ToLower(device[strings.LastIndexAny(device, `/\`)+1:])
+			if _, found := copyOnly[name]; !found {
+				copyOnly[name] = entry.IsCopyOnly
+			}
+		}
+	}
+
+	for _, set := range fulls {
+		var value, found bool
+		for _, file := range set.Files {
+			if value, found = copyOnly[strings.ToLower(file)]; found {
+				break
+			}
+		}
+
+		if !found {
+			mediaSet := make([]string, 0, len(set.Files))
+			for _, file := range set.Files {
+				mediaSet = append(mediaSet, filepath.Join(path, file))
+			}
+
+			headers, err := rp.GetBackupHeaders(mediaSet)
+			if err != nil || len(headers) == 0 {
+				slog.Warn("Cannot read if the full backup is copy-only. It is kept as a base of the later backups", "Database", set.Database, "Files", set.Files, "Error", err)
+				continue
+			}
+			// The last backup set of the media is the newest one
+			value = headers[len(headers)-1].IsCopyOnly
+		}
+
+		set.CopyOnly = &value
+	}
+}
+
+// Sets the reasons why each backup set is kept. The backup sets must be sorted by database, from the newest to the oldest backup.
+// The rules are applied to each database and backup type; then the newest full backup of each database is kept, and the backups a kept differential
+// or log backup depends on: its base, the newest full backup before it which is not copy-only and, for a log backup, the newest differential backup
+// between that full backup and it, and every log backup between that base (the differential backup, or the full backup without one) and it, since the log
+// chain cannot have gaps. When it is not known if a full backup is copy-only, it is kept as a base too, along with the older ones up to a known base
+func retainBackupSets(sets []*model.RetentionBackupSet, policy model.RetentionPolicy, now time.Time) {
+	keep := func(set *model.RetentionBackupSet, reason string) {
+		if !slices.Contains(set.Reasons, reason) {
+			set.Reasons = append(set.Reasons, reason)
+		}
+	}
+
+	for start := 0; start < len(sets); {
+		end := start
+		for end < len(sets) && sets[end].Database == sets[start].Database {
+			end++
+		}
+		database := sets[start:end]
+		start = end
+
+		for _, backupType := range model.BackupTypes {
+			var series []*model.RetentionBackupSet
+			for _, set := range database {
+				if set.BackupType == backupType {
+					series = append(series, set)
+				}
+			}
+
+			for position, set := range series {
+				if position < policy.KeepLast {
+					keep(set, model.RetainLast)
+				}
+				if policy.KeepDays > 0 && set.BackupTime.After(now.AddDate(0, 0, -policy.KeepDays)) {
+					keep(set, model.RetainDays)
+				}
+			}
+
+			retainPeriods(series, policy.KeepDaily, model.RetainDaily, func(t time.Time) string { return t.Format(model.DateLayout) }, keep)
+			retainPeriods(series, policy.KeepWeekly, model.RetainWeekly, func(t time.Time) string {
+				year, week := t.ISOWeek()
+				return fmt.Sprintf("%d-%02d", year, week)
+			}, keep)
+			retainPeriods(series, policy.KeepMonthly, model.RetainMonthly, func(t time.Time) string { return t.Format("2006-01") }, keep)
+		}
+
+		// The sets are sorted from the newest to the oldest, so the first full backup found is the newest one
+		newest := func(backupType model.BackupType, from time.Time, to time.Time) *model.RetentionBackupSet {
+			for _, set := range database {
+				if set.BackupType == backupType && !set.BackupTime.After(to) && !set.BackupTime.Before(from) {
+					return set
+				}
+			}
+			return nil
+		}
+
+		if full := newest(model.BackupFull, time.Time{}, database[0].BackupTime); full != nil {
+			keep(full, model.RetainNewestFull)
+		}
+
+		// The full backups which may be the base of a backup of the time, from the newest to the oldest: the copy-only ones are never a base,
+		// and the ones not known to be copy-only or not are candidates up to the newest one known not to be copy-only
+		bases := func(to time.Time) []*model.RetentionBackupSet {
+			var fulls []*model.RetentionBackupSet
+			for _, set := range database {
+				if set.BackupType != model.BackupFull || set.BackupTime.After(to) {
+					continue
+				}
+				if set.CopyOnly == nil {
+					fulls = append(fulls, set)
+				} else if !*set.CopyOnly {
+					return append(fulls, set)
+				}
+			}
+			return fulls
+		}
+
+		for _, set := range database {
+			if set.BackupType == model.BackupFull || len(set.Reasons) == 0 || slices.Equal(set.Reasons, []string{model.RetainChain}) {
+				continue
+			}
+
+			fulls := bases(set.BackupTime)
+			if len(fulls) == 0 {
+				continue
+			}
+			for _, full := range fulls {
+				keep(full, model.RetainChain)
+			}
+
+			if set.BackupType == model.BackupLog {
+				// The oldest candidate, so no log backup of the chain is left out
+				base := fulls[len(fulls)-1]
+				if differential := newest(model.BackupDifferential, base.BackupTime, set.BackupTime); differential != nil {
+					keep(differential, model.RetainChain)
+					base = differential
+				}
+
+				for _, log := range database {
+					if log.BackupType == model.BackupLog && log.BackupTime.After(base.BackupTime) && log.BackupTime.Before(set.BackupTime) {
+						keep(log, model.RetainChain)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Keeps the newest backup set of each of the last count periods which have backups. The series must be sorted from the newest to the oldest backup
+func retainPeriods(series []*model.RetentionBackupSet, count int, reason string, period func(time.Time) string, keep func(*model.RetentionBackupSet, string)) {
+	seen := make(map[string]bool)
+
+	for _, set := range series {
+		if len(seen) == count {
+			return
+		}
+
+		key := period(set.BackupTime)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		keep(set, reason)
+	}
+}
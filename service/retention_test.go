@@ -0,0 +1,174 @@
+package service
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+)
+
+// The full backups are known not to be copy-only (see copyOnlyFull and unknownFull)
+func backupSet(database string, backupType model.BackupType, file string, backupTime time.Time) *model.RetentionBackupSet {
+	set := &model.RetentionBackupSet{Database: database, BackupType: backupType, BackupTime: backupTime, Files: []string{file}}
+	if backupType == model.BackupFull {
+		set.CopyOnly = new(bool)
+	}
+	return set
+}
+
+func copyOnlyFull(database string, file string, backupTime time.Time) *model.RetentionBackupSet {
+	copyOnly := true
+	return &model.RetentionBackupSet{Database: database, BackupType: model.BackupFull, BackupTime: backupTime, Files: []string{file}, CopyOnly: &copyOnly}
+}
+
+func unknownFull(database string, file string, backupTime time.Time) *model.RetentionBackupSet {
+	return &model.RetentionBackupSet{Database: database, BackupType: model.BackupFull, BackupTime: backupTime, Files: []string{file}}
+}
+
+func TestRetainBackupSets(t *testing.T) {
+	nextDay := func(hour int, minute int) time.Time { return at(hour, minute).AddDate(0, 0, 1) }
+
+	// The sets of each test are sorted by database, from the newest to the oldest backup, like applyRetention sorts them
+	withDifferential := func() []*model.RetentionBackupSet {
+		return []*model.RetentionBackupSet{
+			backupSet("sales", model.BackupLog, "log3.trn", at(3, 0)),
+			backupSet("sales", model.BackupLog, "log2.trn", at(2, 30)),
+			backupSet("sales", model.BackupDifferential, "diff.dif", at(2, 0)),
+			backupSet("sales", model.BackupLog, "log1.trn", at(1, 30)),
+			backupSet("sales", model.BackupFull, "full.bak", at(1, 0)),
+			backupSet("sales", model.BackupLog, "oldLog.trn", at(0, 30)),
+			backupSet("sales", model.BackupFull, "oldFull.bak", at(0, 0)),
+		}
+	}
+	withoutDifferential := func() []*model.RetentionBackupSet {
+		return []*model.RetentionBackupSet{
+			backupSet("sales", model.BackupLog, "log3.trn", at(2, 30)),
+			backupSet("sales", model.BackupLog, "log2.trn", at(2, 0)),
+			backupSet("sales", model.BackupLog, "log1.trn", at(1, 30)),
+			backupSet("sales", model.BackupFull, "full.bak", at(1, 0)),
+			backupSet("sales", model.BackupLog, "oldLog.trn", at(0, 30)),
+			backupSet("sales", model.BackupFull, "oldFull.bak", at(0, 0)),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		sets   []*model.RetentionBackupSet
+		policy model.RetentionPolicy
+		now    time.Time
+		want   []string // The files of the kept sets, sorted
+	}{
+		{
+			name:   "keepLast keeps the logs between the differential backup and the kept log",
+			sets:   withDifferential(),
+			policy: model.RetentionPolicy{KeepLast: 1},
+			now:    at(4, 0),
+			want:   []string{"diff.dif", "full.bak", "log2.trn", "log3.trn"},
+		},
+		{
+			name:   "keepLast keeps the logs between the full backup and the kept log",
+			sets:   withoutDifferential(),
+			policy: model.RetentionPolicy{KeepLast: 1},
+			now:    at(4, 0),
+			want:   []string{"full.bak", "log1.trn", "log2.trn", "log3.trn"},
+		},
+		{
+			name:   "keepDays keeps the logs between the full backup and the kept logs",
+			sets:   withoutDifferential(),
+			policy: model.RetentionPolicy{KeepDays: 1},
+			now:    nextDay(1, 45),
+			want:   []string{"full.bak", "log1.trn", "log2.trn", "log3.trn"},
+		},
+		{
+			name:   "keepDays keeps the logs between the differential backup and the kept logs",
+			sets:   withDifferential(),
+			policy: model.RetentionPolicy{KeepDays: 1},
+			now:    nextDay(2, 45),
+			want:   []string{"diff.dif", "full.bak", "log2.trn", "log3.trn"},
+		},
+		{
+			name: "a kept log of an older full backup keeps its own chain",
+			sets: []*model.RetentionBackupSet{
+				backupSet("sales", model.BackupLog, "newLog.trn", nextDay(0, 30)),
+				backupSet("sales", model.BackupFull, "newFull.bak", nextDay(0, 0)),
+				backupSet("sales", model.BackupLog, "log2.trn", at(1, 0)),
+				backupSet("sales", model.BackupLog, "log1.trn", at(0, 30)),
+				backupSet("sales", model.BackupFull, "full.bak", at(0, 0)),
+			},
+			policy: model.RetentionPolicy{KeepDaily: 2},
+			now:    nextDay(1, 0),
+			want:   []string{"full.bak", "log1.trn", "log2.trn", "newFull.bak", "newLog.trn"},
+		},
+		{
+			name: "the logs of other databases are not kept",
+			sets: []*model.RetentionBackupSet{
+				backupSet("hr", model.BackupFull, "hrFull.bak", at(3, 0)),
+				backupSet("hr", model.BackupLog, "hrLog.trn", at(1, 45)),
+				backupSet("hr", model.BackupFull, "hrOldFull.bak", at(0, 0)),
+				backupSet("sales", model.BackupLog, "log2.trn", at(2, 0)),
+				backupSet("sales", model.BackupLog, "log1.trn", at(1, 30)),
+				backupSet("sales", model.BackupFull, "full.bak", at(1, 0)),
+			},
+			policy: model.RetentionPolicy{KeepLast: 1},
+			now:    at(4, 0),
+			want:   []string{"full.bak", "hrFull.bak", "hrLog.trn", "hrOldFull.bak", "log1.trn", "log2.trn"},
+		},
+		{
+			name: "a copy-only full backup is not the base of the later logs",
+			sets: []*model.RetentionBackupSet{
+				backupSet("sales", model.BackupLog, "log2.trn", at(2, 30)),
+				copyOnlyFull("sales", "copy.bak", at(2, 0)),
+				backupSet("sales", model.BackupLog, "log1.trn", at(1, 30)),
+				backupSet("sales", model.BackupFull, "full.bak", at(1, 0)),
+				backupSet("sales", model.BackupFull, "oldFull.bak", at(0, 0)),
+			},
+			policy: model.RetentionPolicy{KeepLast: 1},
+			now:    at(4, 0),
+			want:   []string{"copy.bak", "full.bak", "log1.trn", "log2.trn"},
+		},
+		{
+			name: "a copy-only full backup is not the base of the later differential backups",
+			sets: []*model.RetentionBackupSet{
+				backupSet("sales", model.BackupDifferential, "diff.dif", at(2, 0)),
+				copyOnlyFull("sales", "copy.bak", at(1, 30)),
+				backupSet("sales", model.BackupFull, "full.bak", at(1, 0)),
+				backupSet("sales", model.BackupFull, "oldFull.bak", at(0, 0)),
+			},
+			policy: model.RetentionPolicy{KeepLast: 1},
+			now:    at(4, 0),
+			want:   []string{"copy.bak", "diff.dif", "full.bak"},
+		},
+		{
+			name: "a full backup not known to be copy-only keeps the older ones up to a known base",
+			sets: []*model.RetentionBackupSet{
+				backupSet("sales", model.BackupLog, "log2.trn", at(2, 30)),
+				unknownFull("sales", "unknown.bak", at(2, 0)),
+				backupSet("sales", model.BackupLog, "log1.trn", at(1, 30)),
+				backupSet("sales", model.BackupFull, "full.bak", at(1, 0)),
+				backupSet("sales", model.BackupFull, "oldFull.bak", at(0, 0)),
+			},
+			policy: model.RetentionPolicy{KeepLast: 1},
+			now:    at(4, 0),
+			want:   []string{"full.bak", "log1.trn", "log2.trn", "unknown.bak"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retainBackupSets(tt.sets, tt.policy, tt.now)
+
+			var kept []string
+			for _, set := range tt.sets {
+				if len(set.Reasons) > 0 {
+					kept = append(kept, set.Files...)
+				}
+			}
+			slices.Sort(kept)
+
+			if !slices.Equal(kept, tt.want) {
+				t.Errorf("retainBackupSets() kept %v, want %v", kept, tt.want)
+			}
+		})
+	}
+}
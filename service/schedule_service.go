@@ -0,0 +1,655 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/db"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/repository"
+)
+
+var (
+	// ErrInvalidSchedule is returned when the schedule request is not valid (name, cron expressions, connection, databases, backup type, options or path).
+	ErrInvalidSchedule = errors.New("Invalid schedule")
+	// ErrSchedulesSecretNotSet is returned when a schedule password must be encrypted, but the schedules.secret configuration is not set.
+	ErrSchedulesSecretNotSet = errors.New("The schedules.secret configuration is not set, so the password of the connection cannot be kept")
+	// ErrNoScheduledDatabases is returned when a scheduled run finds no database of the server which matches the pattern of the schedule and can be backed up.
+	ErrNoScheduledDatabases = errors.New("No database of the server matches the schedule")
+)
+
+// Struct responsible for the backup schedules: it keeps them in the ScheduleStore and starts their backups, through the DatabaseService, on their cron expressions.
+// A schedule does not start a run while its previous run is still going: the run is skipped and the reason is recorded in the schedule.
+// Each run opens its own connection, with the connection of the schedule, which is closed once the job finishes
+type ScheduleService struct {
+	mu        sync.Mutex
+	store     *repository.ScheduleStore
+	databases *DatabaseService
+	secret    []byte
+	schedules map[string]*model.Schedule
+	running   map[string]string // The job of each schedule with a run going, by the schedule ID. Empty while the run connects
+	wake      chan struct{}
+}
+
+// Creates an instance of ScheduleService struct, loading the schedules of the store. The runs missed while the application was stopped are run once
+// or skipped, by the missed run policy of each schedule, once Start is called. The secret encrypts the passwords of the schedules (see config.SchedulesConfig)
+func NewScheduleService(store *repository.ScheduleStore, databases *DatabaseService, secret string) (*ScheduleService, error) {
+	ss := &ScheduleService{
+		store:     store,
+		databases: databases,
+		schedules: make(map[string]*model.Schedule),
+		running:   make(map[string]string),
+		wake:      make(chan struct{}, 1),
+	}
+	if secret != "" {
+		key := sha256.Sum256([]byte(secret))
+		ss.secret = key[:]
+	}
+
+	schedules, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, schedule := range schedules {
+		// The last run was interrupted by the application stop, and its job was already marked as finished by the JobService
+		if schedule.LastJobID != "" && (schedule.LastState == model.JobQueued || schedule.LastState == model.JobRunning) {
+			job, err := databases.jobs.Operation(schedule.LastJobID)
+			if err == nil {
+				schedule.LastState = job.State
+			} else {
+				schedule.LastState = model.JobFailed
+				schedule.LastError = model.ErrOperationInterrupted.Error()
+			}
+		}
+
+		if !schedule.Paused && schedule.NextRunAt != nil && schedule.NextRunAt.Before(now) {
+			switch schedule.MissedRunPolicy {
+			case model.MissedRunRunOnce:
+				slog.Info("Schedule missed a run while the application was stopped. It runs once", "Schedule", schedule.ID, "Missed run", *schedule.NextRunAt)
+			default:
+				slog.Info("Schedule missed a run while the application was stopped. It is skipped", "Schedule", schedule.ID, "Missed run", *schedule.NextRunAt)
+				schedule.LastSkippedAt = &now
+				schedule.LastSkipReason = fmt.Sprintf("The run of %v was missed while the application was stopped", schedule.NextRunAt.Format(time.RFC3339))
+				schedule.NextRunAt = nextRun(schedule.Cron, now)
+			}
+		}
+
+		ss.schedules[schedule.ID] = &schedule
+		ss.save(&schedule)
+	}
+
+	return ss, nil
+}
+
+// Starts the scheduler, which runs in background and starts the backups of the schedules when they are due
+func (ss *ScheduleService) Start() {
+	go func() {
+		for {
+			timer := time.NewTimer(ss.untilNextRun())
+			select {
+			case <-timer.C:
+			case <-ss.wake:
+				timer.Stop()
+			}
+
+			ss.runDue(time.Now())
+		}
+	}()
+}
+
+// Lists the schedules, from the oldest to the newest
+func (ss *ScheduleService) List() []model.Schedule {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	schedules := make([]model.Schedule, 0, len(ss.schedules))
+	for _, schedule := range ss.schedules {
+		schedules = append(schedules, ss.view(schedule))
+	}
+
+	slices.SortFunc(schedules, func(a, b model.Schedule) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return schedules
+}
+
+// Gets the schedule by its ID
+func (ss *ScheduleService) Get(id string) (model.Schedule, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	schedule, ok := ss.schedules[id]
+	if !ok {
+		return model.Schedule{}, repository.ErrScheduleNotFound
+	}
+
+	return ss.view(schedule), nil
+}
+
+// Creates a schedule. Its first run is the next time of its cron expressions, unless it is created paused
+func (ss *ScheduleService) Create(createdBy string, request model.SchedulePostRequired) (model.Schedule, error) {
+	err := request.Validate()
+	if err != nil {
+		slog.Error("Cannot create the schedule. Invalid request", "Error", err)
+		return model.Schedule{}, fmt.Errorf("%w: %w", ErrInvalidSchedule, err)
+	}
+
+	password, err := ss.encryptPassword(request.Connection.Password)
+	if err != nil {
+		slog.Error("Cannot create the schedule. The password cannot be encrypted", "Error", err)
+		return model.Schedule{}, err
+	}
+
+	now := time.Now()
+	schedule := &model.Schedule{ID: newJobID(), CreatedBy: createdBy, CreatedAt: now}
+	applyScheduleRequest(schedule, request, now)
+	schedule.Connection.Password = password
+
+	ss.mu.Lock()
+	err = ss.store.Save(*schedule)
+	if err != nil {
+		ss.mu.Unlock()
+		slog.Error("Cannot save the schedule", "Error", err)
+		return model.Schedule{}, err
+	}
+	ss.schedules[schedule.ID] = schedule
+	created := ss.view(schedule)
+	ss.mu.Unlock()
+
+	slog.Info("Schedule created", "Schedule", schedule.ID, "Name", schedule.Name, "Cron", schedule.Cron, "Created by", createdBy)
+	ss.notify()
+
+	return created, nil
+}
+
+// Replaces the plan of the schedule. An empty password keeps the password of the schedule, unless the user is empty too, which switches the connection
+// to the integrated authentication. A run which is going is not affected.
+// The run history (the Last* fields) is kept, and the next run is calculated again
+func (ss *ScheduleService) Update(id string, request model.SchedulePostRequired) (model.Schedule, error) {
+	err := request.Validate()
+	if err != nil {
+		slog.Error("Cannot update the schedule. Invalid request", "Schedule", id, "Error", err)
+		return model.Schedule{}, fmt.Errorf("%w: %w", ErrInvalidSchedule, err)
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	current, ok := ss.schedules[id]
+	if !ok {
+		return model.Schedule{}, repository.ErrScheduleNotFound
+	}
+
+	password := current.Connection.Password
+	if request.Connection.Password != "" || request.Connection.User == "" {
+		password, err = ss.encryptPassword(request.Connection.Password)
+		if err != nil {
+			slog.Error("Cannot update the schedule. The password cannot be encrypted", "Schedule", id, "Error", err)
+			return model.Schedule{}, err
+		}
+	}
+
+	schedule := *current
+	applyScheduleRequest(&schedule, request, time.Now())
+	schedule.Connection.Password = password
+
+	err = ss.store.Save(schedule)
+	if err != nil {
+		slog.Error("Cannot save the schedule", "Schedule", id, "Error", err)
+		return model.Schedule{}, err
+	}
+	*current = schedule
+
+	slog.Info("Schedule updated", "Schedule", id, "Name", schedule.Name, "Cron", schedule.Cron)
+	ss.notify()
+
+	return ss.view(current), nil
+}
+
+// Deletes the schedule. A run which is going is not cancelled
+func (ss *ScheduleService) Delete(id string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if _, ok := ss.schedules[id]; !ok {
+		return repository.ErrScheduleNotFound
+	}
+
+	err := ss.store.Delete(id)
+	if err != nil {
+		slog.Error("Cannot delete the schedule", "Schedule", id, "Error", err)
+		return err
+	}
+	delete(ss.schedules, id)
+
+	slog.Info("Schedule deleted", "Schedule", id)
+	ss.notify()
+
+	return nil
+}
+
+// Pauses the schedule, which does not run until it is resumed. A run which is going is not cancelled
+func (ss *ScheduleService) Pause(id string) (model.Schedule, error) {
+	return ss.setPaused(id, true)
+}
+
+// Resumes the schedule. Its next run is the next time of its cron expressions: the runs missed while it was paused are not run
+func (ss *ScheduleService) Resume(id string) (model.Schedule, error) {
+	return ss.setPaused(id, false)
+}
+
+func (ss *ScheduleService) setPaused(id string, paused bool) (model.Schedule, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	current, ok := ss.schedules[id]
+	if !ok {
+		return model.Schedule{}, repository.ErrScheduleNotFound
+	}
+
+	schedule := *current
+	schedule.Paused = paused
+	schedule.UpdatedAt = time.Now()
+	schedule.NextRunAt = nil
+	if !paused {
+		schedule.NextRunAt = nextRun(schedule.Cron, schedule.UpdatedAt)
+	}
+
+	err := ss.store.Save(schedule)
+	if err != nil {
+		slog.Error("Cannot save the schedule", "Schedule", id, "Error", err)
+		return model.Schedule{}, err
+	}
+	*current = schedule
+
+	slog.Info("Schedule paused or resumed", "Schedule", id, "Paused", paused)
+	ss.notify()
+
+	return ss.view(current), nil
+}
+
+// Starts the runs of the schedules which are due, and calculates their next run. A schedule whose previous run is still going skips the run
+func (ss *ScheduleService) runDue(now time.Time) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	for _, schedule := range ss.schedules {
+		if schedule.Paused || schedule.NextRunAt == nil || schedule.NextRunAt.After(now) {
+			continue
+		}
+
+		schedule.NextRunAt = nextRun(schedule.Cron, now)
+
+		if jobID, ok := ss.running[schedule.ID]; ok {
+			slog.Warn("Schedule run skipped. The previous run is still going", "Schedule", schedule.ID, "Job", jobID)
+			schedule.LastSkippedAt = &now
+			schedule.LastSkipReason = fmt.Sprintf("The previous run (job %v) was still going", jobID)
+			ss.save(schedule)
+			continue
+		}
+
+		ss.running[schedule.ID] = ""
+		schedule.LastRunAt = &now
+		schedule.LastJobID = ""
+		schedule.LastState = model.JobQueued
+		schedule.LastError = ""
+		ss.save(schedule)
+
+		go ss.run(*schedule)
+	}
+}
+
+// Runs the backup of the schedule: it connects to the server, selects the databases and starts the backup job, waiting for it to finish.
+// The connection is closed by the job goroutine once it exits, not when the job is marked as finished
+func (ss *ScheduleService) run(schedule model.Schedule) {
+	slog.Info("Starting scheduled backup...", "Schedule", schedule.ID, "Name", schedule.Name)
+
+	conn, err := ss.connect(schedule)
+	if err != nil {
+		ss.finishRun(schedule.ID, "", err)
+		return
+	}
+
+	rp := repository.NewDatabaseRepository(conn)
+
+	backupDbList, err := scheduledDatabases(rp, schedule)
+	if err != nil {
+		conn.Close()
+		ss.finishRun(schedule.ID, "", err)
+		return
+	}
+
+	options := ss.databases.withDefaultBackupOptions(schedule.BackupType, schedule.Path, schedule.Options)
+	template := model.Job{Type: model.JobBackup, CreatedBy: schedule.CreatedBy, Server: schedule.Connection.Server(), ScheduleID: schedule.ID}
+
+	job, err := ss.databases.startBackup(rp, func() { conn.Close() }, template, backupDbList, schedule.Path, schedule.Target, schedule.ShipTo, schedule.BackupType, options, schedule.ConcurrentOpe)
+	if err != nil {
+		conn.Close()
+		ss.finishRun(schedule.ID, "", err)
+		return
+	}
+	ss.startedRun(schedule.ID, job.ID)
+
+	<-ss.databases.jobs.Done(job.ID)
+	ss.enforceRetention(schedule, job.ID)
+	ss.finishRun(schedule.ID, job.ID, nil)
+}
+
+// Connects to the server with the connection of the schedule, whose password is stored encrypted
+func (ss *ScheduleService) connect(schedule model.Schedule) (*sql.DB, error) {
+	connInfo := schedule.Connection
+	password, err := ss.decryptPassword(connInfo.Password)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot decrypt the password of the connection. Was schedules.secret changed? Details: %v", err)
+	}
+	connInfo.Password = password
+
+	conn, err := db.ConnDb(connInfo, ss.databases.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("Connection failed. Details: %v", err)
+	}
+
+	return conn, nil
+}
+
+// Applies the retention policy of the schedule to its path, connected to the server of the schedule, which tells the copy-only full backups apart
+func (ss *ScheduleService) applyRetention(schedule model.Schedule, dryRun bool) (model.Retention, error) {
+	conn, err := ss.connect(schedule)
+	if err != nil {
+		return model.Retention{}, err
+	}
+	defer conn.Close()
+
+	rp := repository.NewDatabaseRepository(conn)
+	return ss.databases.applyRetention(&rp, schedule.Path, *schedule.Retention, schedule.MatchDatabase, dryRun, schedule.CreatedBy, schedule.ID)
+}
+
+// Applies the retention policy of the schedule, if it has one, to its path once the run finished. A run which backed up no database
+// (failed or cancelled) does not delete anything, since the old backups may be the only ones left
+func (ss *ScheduleService) enforceRetention(schedule model.Schedule, jobID string) {
+	if schedule.Retention == nil {
+		return
+	}
+
+	job, err := ss.databases.jobs.Operation(jobID)
+	if err != nil || (job.State != model.JobSucceeded && job.State != model.JobPartial) {
+		slog.Warn("Retention of the schedule skipped. The run did not back up any database", "Schedule", schedule.ID, "Job", jobID, "State", job.State)
+		return
+	}
+
+	retention, err := ss.applyRetention(schedule, false)
+	if err == nil && len(retention.Failed) > 0 {
+		err = fmt.Errorf("%v backup sets could not be deleted. See audit.log", len(retention.Failed))
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	current, ok := ss.schedules[schedule.ID]
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	current.LastRetentionAt = &now
+	current.LastRetentionDeleted = len(retention.Deleted)
+	current.LastRetentionError = ""
+	if err != nil {
+		slog.Error("Retention of the schedule failed", "Schedule", schedule.ID, "Error", err)
+		current.LastRetentionError = err.Error()
+	}
+	ss.save(current)
+}
+
+// Lists the backup sets which the retention policy of the schedule would delete from its path now, without deleting them
+func (ss *ScheduleService) PlanRetention(id string) (model.Retention, error) {
+	schedule, err := ss.Get(id)
+	if err != nil {
+		return model.Retention{}, err
+	}
+	if schedule.Retention == nil {
+		return model.Retention{}, fmt.Errorf("%w: the schedule has no retention policy", ErrInvalidSchedule)
+	}
+
+	return ss.applyRetention(schedule, true)
+}
+
+// Selects the databases of the run. Databases selected by name are checked by the backup (see checkBackupDatabases), while the databases
+// which match the pattern but cannot be backed up (see backupRejection), like tempdb, are left out
+func scheduledDatabases(rp repository.DatabaseRepository, schedule model.Schedule) ([]model.Database, error) {
+	if schedule.DatabasePattern == "" {
+		backupDbList := make([]model.Database, 0, len(schedule.Databases))
+		for _, name := range schedule.Databases {
+			backupDbList = append(backupDbList, model.Database{Name: name})
+		}
+		return backupDbList, nil
+	}
+
+	databases, err := getDatabases(rp, model.DatabaseFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("Cannot get databases. Details: %v", err)
+	}
+
+	var backupDbList []model.Database
+	for _, database := range databases {
+		if schedule.MatchDatabase(database.Name) && backupRejection(database, schedule.BackupType) == nil {
+			backupDbList = append(backupDbList, model.Database{Name: database.Name})
+		}
+	}
+	if len(backupDbList) == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrNoScheduledDatabases, schedule.DatabasePattern)
+	}
+
+	return backupDbList, nil
+}
+
+// Records the job of the run, once it is created
+func (ss *ScheduleService) startedRun(id string, jobID string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.running[id] = jobID
+
+	schedule, ok := ss.schedules[id]
+	if !ok {
+		return
+	}
+
+	schedule.LastJobID = jobID
+	schedule.LastState = model.JobRunning
+	ss.save(schedule)
+}
+
+// Records the end of the run: the final state of its job or, if the run failed before the job was created, the error
+func (ss *ScheduleService) finishRun(id string, jobID string, err error) {
+	var state model.JobState
+	if err == nil {
+		job, opErr := ss.databases.jobs.Operation(jobID)
+		if opErr != nil {
+			err = opErr
+		}
+		state = job.State
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	delete(ss.running, id)
+
+	// The schedule may have been deleted while it ran
+	schedule, ok := ss.schedules[id]
+	if !ok {
+		return
+	}
+
+	if err != nil {
+		slog.Error("Scheduled backup failed", "Schedule", id, "Job", jobID, "Error", err)
+		schedule.LastState = model.JobFailed
+		schedule.LastError = err.Error()
+	} else {
+		slog.Info("Scheduled backup finished", "Schedule", id, "Job", jobID, "State", state)
+		schedule.LastState = state
+	}
+	ss.save(schedule)
+}
+
+// Returns how long the scheduler waits for the next due schedule. Changes of the schedules wake it up earlier (see notify)
+func (ss *ScheduleService) untilNextRun() time.Duration {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	wait := time.Hour
+	for _, schedule := range ss.schedules {
+		if schedule.Paused || schedule.NextRunAt == nil {
+			continue
+		}
+		wait = min(wait, max(time.Until(*schedule.NextRunAt), 0))
+	}
+
+	return wait
+}
+
+// Wakes the scheduler up, so it waits for the next run of the changed schedules
+func (ss *ScheduleService) notify() {
+	select {
+	case ss.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Saves the schedule in the store. It is called with the lock held. A failure is only logged, since the schedule is kept in memory
+func (ss *ScheduleService) save(schedule *model.Schedule) {
+	err := ss.store.Save(*schedule)
+	if err != nil {
+		slog.Error("Cannot save the schedule", "Schedule", schedule.ID, "Error", err)
+	}
+}
+
+// Returns a copy of the schedule as it is returned by the API: without the password of the connection, and reporting if a run is going.
+// It is called with the lock held
+func (ss *ScheduleService) view(schedule *model.Schedule) model.Schedule {
+	view := *schedule
+	view.Connection.Password = ""
+	view.Cron = slices.Clone(schedule.Cron)
+	view.Databases = slices.Clone(schedule.Databases)
+	_, view.Running = ss.running[schedule.ID]
+
+	return view
+}
+
+// Sets the plan of the schedule from the validated request, and calculates its next run
+func applyScheduleRequest(schedule *model.Schedule, request model.SchedulePostRequired, now time.Time) {
+	schedule.Name = request.Name
+	schedule.Cron = request.Cron
+	schedule.Connection = request.Connection
+	schedule.Databases = request.Databases
+	schedule.DatabasePattern = request.DatabasePattern
+	schedule.BackupType = model.BackupType(request.BackupType)
+	schedule.Options = request.Options
+	schedule.Path = request.Path
+	schedule.Target = request.Target
+	schedule.ShipTo = request.ShipTo
+	schedule.ConcurrentOpe = request.ConcurrentOpe
+	schedule.MissedRunPolicy = request.MissedRunPolicy
+	schedule.Retention = request.Retention
+	schedule.Paused = request.Paused
+	schedule.UpdatedAt = now
+
+	schedule.NextRunAt = nil
+	if !schedule.Paused {
+		schedule.NextRunAt = nextRun(schedule.Cron, now)
+	}
+}
+
+// Returns the next run of the cron expressions after the time, or nil if they never run again
+func nextRun(expressions []string, after time.Time) *time.Time {
+	next := model.NextRun(expressions, after)
+	if next.IsZero() {
+		return nil
+	}
+
+	return &next
+}
+
+// Encrypts the password of the connection of a schedule. An empty password, of the integrated authentication, is kept empty, so it does not need the schedules secret
+func (ss *ScheduleService) encryptPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+
+	return ss.encrypt(password)
+}
+
+// Decrypts the password of the connection of a schedule, encrypted by encryptPassword
+func (ss *ScheduleService) decryptPassword(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	return ss.decrypt(value)
+}
+
+// Encrypts the password with AES-GCM, keyed by the schedules secret. The nonce is kept before the ciphertext, encoded as base64
+func (ss *ScheduleService) encrypt(password string) (string, error) {
+	gcm, err := ss.cipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(password), nil)), nil
+}
+
+// Decrypts a password encrypted by encrypt
+func (ss *ScheduleService) decrypt(value string) (string, error) {
+	gcm, err := ss.cipher()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("the encrypted password is too short")
+	}
+
+	password, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(password), nil
+}
+
+func (ss *ScheduleService) cipher() (cipher.AEAD, error) {
+	if ss.secret == nil {
+		return nil, ErrSchedulesSecretNotSet
+	}
+
+	block, err := aes.NewCipher(ss.secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
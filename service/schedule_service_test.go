@@ -0,0 +1,41 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEncryptPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   []byte
+		password string
+		wantErr  error
+	}{
+		{name: "integrated authentication without secret", password: ""},
+		{name: "password without secret", password: "secret", wantErr: ErrSchedulesSecretNotSet},
+		{name: "password", secret: []byte("0123456789abcdef0123456789abcdef"), password: "secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ss := &ScheduleService{secret: tt.secret}
+
+			encrypted, err := ss.encryptPassword(tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("encryptPassword() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tt.password != "" && encrypted == tt.password {
+				t.Errorf("encryptPassword() kept the password in clear text")
+			}
+
+			decrypted, err := ss.decryptPassword(encrypted)
+			if err != nil || decrypted != tt.password {
+				t.Errorf("decryptPassword() = %q, %v, want %q", decrypted, err, tt.password)
+			}
+		})
+	}
+}
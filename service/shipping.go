@@ -0,0 +1,131 @@
+package service
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/repository"
+)
+
+// Parses the validated shipTo and checks if the backup files can be shipped to it (see repository.FileShipper.Check), and if the directories they are written to
+// can be read by MaestroSQL (see repository.FileShipper.CheckSource). Returns nil without a shipTo
+func (ds *DatabaseService) shipDestination(shipTo string, directories []string) (*model.ShipDestination, error) {
+	if shipTo == "" {
+		return nil, nil
+	}
+
+	destination, err := model.ParseShipDestination(shipTo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ds.shipper.Check(destination)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ds.shipper.CheckSource(directories)
+	if err != nil {
+		return nil, err
+	}
+
+	return &destination, nil
+}
+
+// Wraps the observer of the backup job, so the backup files of each database are shipped to the destination once its backup succeeds.
+// The files are shipped by a worker of the job, one database at a time, after the database is reported as finished, so its SQL Server session
+// and its concurrency slot are not held while the files are copied. databases is the number of databases of the job, which bounds the queue
+func (ds *DatabaseService) shippingObserver(jobID string, destination model.ShipDestination, observer repository.OperationObserver, databases int) *shippingObserver {
+	so := &shippingObserver{
+		OperationObserver: observer,
+		ds:                ds,
+		jobID:             jobID,
+		destination:       destination,
+		files:             make(map[string][]string),
+		queue:             make(chan shipment, databases),
+		done:              make(chan struct{}),
+	}
+	go so.work()
+
+	return so
+}
+
+// shippingObserver implements repository.OperationObserver for a backup job with a ship destination. The files of each database are taken from DatabaseStarted
+type shippingObserver struct {
+	repository.OperationObserver
+	ds          *DatabaseService
+	jobID       string
+	destination model.ShipDestination
+
+	mu    sync.Mutex
+	files map[string][]string
+
+	queue chan shipment
+	done  chan struct{}
+}
+
+// shipment is a database whose backup files are queued to be shipped
+type shipment struct {
+	database string
+	files    []string
+}
+
+func (so *shippingObserver) DatabaseStarted(database string, sessionID int, files []string) {
+	so.mu.Lock()
+	so.files[database] = files
+	so.mu.Unlock()
+
+	so.OperationObserver.DatabaseStarted(database, sessionID, files)
+}
+
+func (so *shippingObserver) DatabaseFinished(database string, err error) {
+	so.mu.Lock()
+	files := so.files[database]
+	delete(so.files, database)
+	so.mu.Unlock()
+
+	if err != nil {
+		so.OperationObserver.DatabaseFinished(database, err)
+		return
+	}
+
+	so.ds.jobs.databaseShipping(so.jobID, database)
+	so.OperationObserver.DatabaseFinished(database, err)
+	so.queue <- shipment{database: database, files: files}
+}
+
+// Waits for the queued databases to be shipped. Must be called once the backup of every database finished, before the job finishes
+func (so *shippingObserver) wait() {
+	close(so.queue)
+	<-so.done
+}
+
+// Ships the queued databases, in the order their backups finished
+func (so *shippingObserver) work() {
+	defer close(so.done)
+
+	for shipment := range so.queue {
+		so.ship(shipment.database, shipment.files)
+	}
+}
+
+// Ships the backup files of the database, one at a time, and records them in the job. The shipping stops at the first file which fails,
+// and is stopped when the job is cancelled. A failure is a warning of the job: the backup itself succeeded
+func (so *shippingObserver) ship(database string, files []string) {
+	ctx := so.ds.jobs.Context(so.jobID)
+	shipped := make([]model.ShippedFile, 0, len(files))
+	for _, file := range files {
+		shippedFile, err := so.ds.shipper.Ship(ctx, file, so.destination)
+		if err != nil {
+			slog.Warn("Cannot ship the backup file", "Job", so.jobID, "Database", database, "File", file, "Destination", so.destination.Type, "Error", err)
+			so.ds.jobs.databaseShipped(so.jobID, database, shipped, err)
+			return
+		}
+
+		slog.Info("Backup file shipped", "Job", so.jobID, "Database", database, "File", file, "Destination", shippedFile.Destination, "Size", shippedFile.Size, "SHA256", shippedFile.SHA256)
+		shipped = append(shipped, shippedFile)
+	}
+
+	so.ds.jobs.databaseShipped(so.jobID, database, shipped, nil)
+}
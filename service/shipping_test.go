@@ -0,0 +1,95 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/config"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/repository"
+)
+
+// finishedObserver checks that the backup files of a database were not shipped yet when it is reported as finished
+type finishedObserver struct {
+	repository.OperationObserver
+	t     *testing.T
+	mount string
+	files map[string]string
+}
+
+func (fo finishedObserver) DatabaseFinished(database string, err error) {
+	if _, statErr := os.Stat(filepath.Join(fo.mount, filepath.Base(fo.files[database]))); statErr == nil {
+		fo.t.Errorf("the backup files of %v were shipped before it finished", database)
+	}
+	fo.OperationObserver.DatabaseFinished(database, err)
+}
+
+func TestShippingObserver(t *testing.T) {
+	history, err := repository.NewOperationStore(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+
+	ds := &DatabaseService{jobs: NewJobService(time.Hour, history), shipper: repository.NewFileShipper(config.StorageConfig{})}
+
+	backups, mount := t.TempDir(), t.TempDir()
+	files := map[string]string{
+		"sales":   filepath.Join(backups, "sales_FULL_20250718_010000.bak"),
+		"hr":      filepath.Join(backups, "hr_FULL_20250718_010000.bak"),
+		"missing": filepath.Join(backups, "missing_FULL_20250718_010000.bak"),
+		"failed":  filepath.Join(backups, "failed_FULL_20250718_010000.bak"),
+	}
+	for _, database := range []string{"sales", "hr"} {
+		if err := os.WriteFile(files[database], []byte(database), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	job := ds.jobs.Create(model.Job{Type: model.JobBackup}, []string{"sales", "hr", "missing", "failed"})
+	ds.jobs.Start(job.ID)
+
+	inner := finishedObserver{OperationObserver: ds.jobs.Observer(job.ID), t: t, mount: mount, files: files}
+	observer := ds.shippingObserver(job.ID, model.ShipDestination{Type: model.ShipMount, Path: mount}, inner, 4)
+	for _, database := range []string{"sales", "hr", "missing", "failed"} {
+		observer.DatabaseStarted(database, 51, []string{files[database]})
+
+		var backupErr error
+		if database == "failed" {
+			backupErr = os.ErrDeadlineExceeded
+		}
+		observer.DatabaseFinished(database, backupErr)
+	}
+	observer.wait()
+
+	snapshot, err := ds.jobs.Operation(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, database := range snapshot.Databases {
+		if database.FinishedAt == nil || database.Shipping {
+			t.Errorf("%v: finished = %v, shipping = %v, want finished and not shipping", database.Name, database.FinishedAt != nil, database.Shipping)
+		}
+
+		switch database.Name {
+		case "sales", "hr":
+			if len(database.Shipped) != 1 || database.Shipped[0].Destination != filepath.Join(mount, filepath.Base(files[database.Name])) || database.ShipError != "" {
+				t.Errorf("%v: shipped = %+v, ship error = %q, want the copy in the mount", database.Name, database.Shipped, database.ShipError)
+			}
+		case "missing":
+			if len(database.Shipped) != 0 || database.ShipError == "" {
+				t.Errorf("%v: shipped = %+v, ship error = %q, want the shipping error", database.Name, database.Shipped, database.ShipError)
+			}
+		case "failed":
+			if len(database.Shipped) != 0 || database.ShipError != "" {
+				t.Errorf("%v: shipped = %+v, ship error = %q, want a failed backup not to be shipped", database.Name, database.Shipped, database.ShipError)
+			}
+		}
+	}
+	if len(snapshot.Warnings) != 1 {
+		t.Errorf("warnings = %v, want the shipping error of missing", snapshot.Warnings)
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/RenanMonteiroS/MaestroSQLWeb/db"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/model"
+	"github.com/RenanMonteiroS/MaestroSQLWeb/repository"
+)
+
+// Lists the names of the files of the backup folder: the files of a disk folder, or the objects of a backup URL
+func (ds *DatabaseService) backupFolderFiles(path string) ([]string, error) {
+	if model.IsURLPath(path) {
+		location, err := model.ParseObjectPath(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return ds.storage.ListObjects(location)
+	}
+
+	dir, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fileNames := make([]string, 0, len(dir))
+	for _, file := range dir {
+		if !file.IsDir() {
+			fileNames = append(fileNames, file.Name())
+		}
+	}
+
+	return fileNames, nil
+}
+
+// Checks if the server has the credential of a backup URL: the credential of WITH CREDENTIAL, or the one named after the URL prefix, which the server
+// picks for S3 URLs and Azure URLs with a shared access signature. The backup is not stopped when the credentials cannot be read (VIEW ANY DEFINITION)
+func checkBackupCredential(rp repository.DatabaseRepository, backupPath string, target model.BackupTarget) error {
+	if target.Type != model.StorageURL {
+		return nil
+	}
+
+	credentials, err := rp.GetCredentials()
+	if err != nil {
+		slog.Warn("Cannot check the credential of the backup URL", "Path", backupPath, "Error", err)
+		return nil
+	}
+
+	path := strings.ToLower(backupPath)
+	for _, credential := range credentials {
+		if target.Credential != "" {
+			if credential.Name == target.Credential {
+				return nil
+			}
+			continue
+		}
+
+		name := strings.TrimSuffix(strings.ToLower(credential.Name), "/")
+		if path == name || strings.HasPrefix(path, name+"/") {
+			return nil
+		}
+	}
+
+	if target.Credential != "" {
+		return fmt.Errorf("%w: the credential %v does not exist. Create it in /api/credentials", ErrBackupCredentialNotFound, target.Credential)
+	}
+	return fmt.Errorf("%w: no credential is named after %v or its bucket/container. Create it in /api/credentials", ErrBackupCredentialNotFound, backupPath)
+}
+
+// ListCredentials gets the credentials of the server, used by the backups to URL. Their secrets are never returned
+func (ds *DatabaseService) ListCredentials(key db.ConnKey) ([]model.Credential, error) {
+	rp, err := ds.getRepository(key)
+	if err != nil {
+		slog.Error("Cannot connect to database: ", "Error: ", err)
+		return nil, fmt.Errorf("Connection failed. Try to /connect.\nDetails: %v", err.Error())
+	}
+
+	credentials, err := rp.GetCredentials()
+	if err != nil {
+		slog.Error("Cannot get the credentials: ", "Error: ", err)
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+// SaveCredential creates the credential of the request in the server, or replaces the identity and secret of an existing one. Reports if it was created.
+// It requires the ALTER ANY CREDENTIAL permission
+func (ds *DatabaseService) SaveCredential(key db.ConnKey, request model.CredentialPostRequired) (bool, error) {
+	err := request.Validate()
+	if err != nil {
+		slog.Error("Cannot save the credential. Invalid request", "Credential", request.Name, "Error", err)
+		return false, fmt.Errorf("%w: %w", ErrInvalidCredential, err)
+	}
+
+	rp, err := ds.getRepository(key)
+	if err != nil {
+		slog.Error("Cannot connect to database: ", "Error: ", err)
+		return false, fmt.Errorf("Connection failed. Try to /connect.\nDetails: %v", err.Error())
+	}
+
+	identity, secret := request.CredentialIdentity()
+	created, err := rp.SaveCredential(request.Name, identity, secret)
+	if err != nil {
+		slog.Error("Cannot save the credential: ", "Credential", request.Name, "Error: ", err)
+		return false, err
+	}
+
+	return created, nil
+}
+
+// DropCredential drops the credential from the server. The backups already written keep needing it to be read
+func (ds *DatabaseService) DropCredential(key db.ConnKey, name string) error {
+	rp, err := ds.getRepository(key)
+	if err != nil {
+		slog.Error("Cannot connect to database: ", "Error: ", err)
+		return fmt.Errorf("Connection failed. Try to /connect.\nDetails: %v", err.Error())
+	}
+
+	err = rp.DropCredential(name)
+	if err != nil {
+		slog.Error("Cannot drop the credential: ", "Credential", name, "Error: ", err)
+		return err
+	}
+
+	return nil
+}
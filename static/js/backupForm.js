@@ -1,5 +1,6 @@
 let currentStep = 1;
 const totalSteps = 4;
+const maxConcurrentInspections = 4;
 let authModal;
 let abortController = new AbortController()
 
@@ -229,8 +230,23 @@ async function nextStep() {
                                 title="${window.appConfig.translations.backupsPathTooltipBackup}">
                         </i>
                         <input type="text" class="form-control" id="path" placeholder="C:/Backups">
-                </div>`;
+                </div>
+                <div class="col-md-12 mb-3">
+                        <label for="backupType" class="form-label">${window.appConfig.translations.backupType}</label>
+                        <i class="fas fa-info-circle info-icon" 
+                                data-bs-toggle="tooltip" 
+                                data-bs-placement="right" 
+                                title="${window.appConfig.translations.backupTypeTooltip}">
+                        </i>
+                        <select class="form-control" id="backupType">
+                            <option value="full">${window.appConfig.translations.backupTypeFull}</option>
+                            <option value="differential">${window.appConfig.translations.backupTypeDifferential}</option>
+                            <option value="log">${window.appConfig.translations.backupTypeLog}</option>
+                        </select>
+                </div>
+                ${backupOptionsHTML()}`;
             
+            await loadBackupOptions();
             await loadDatabases();
         } else if (currentStep === 3 && document.querySelector('input[name="operation"]:checked').value === 'restore') {
             const databaseContainer = document.querySelector('#step-3 .row');
@@ -251,11 +267,402 @@ async function nextStep() {
                             <input type="text" class="form-control" id="path" placeholder="C:/Backups/">
                             <button class="btn btn-outline-secondary" type="button" id="list-backups-btn" onclick="listBackups()">${window.appConfig.translations.listBackups}</button>
                         </div>
-                </div>`;
+                </div>
+                ${restoreOptionsHTML()}
+                ${restoreChainHTML()}`;
+        }
+    }
+}
+
+/**
+ * Generates the restore options fields (WITH options of the RESTORE DATABASE statement), inside a collapsible section. They are applied to every selected backup
+ * @returns {string} The HTML of the fields
+*/
+function restoreOptionsHTML() {
+    const translations = window.appConfig.translations;
+    const checkbox = (id, label) => `
+        <div class="col-md-6 mb-2">
+            <input class="form-check-input" type="checkbox" id="${id}">
+            <label class="form-check-label" for="${id}">${label}</label>
+        </div>`;
+
+    return `<div class="col-md-12 mb-3">
+            <a class="btn btn-link btn-sm text-decoration-none p-0" data-bs-toggle="collapse" href="#restore-options" role="button" aria-expanded="false" aria-controls="restore-options">
+                <i class="fas fa-sliders-h me-1"></i>${translations.restoreOptions}
+            </a>
+            <div class="collapse mt-2" id="restore-options">
+                <div class="row">
+                    <div class="col-md-6 mb-2">
+                        <label for="optRecovery" class="form-label">${translations.optionRecovery}</label>
+                        <select class="form-control" id="optRecovery" onchange="document.getElementById('optStandbyFile').disabled = this.value !== 'standby'">
+                            <option value="recovery">RECOVERY</option>
+                            <option value="norecovery">NORECOVERY</option>
+                            <option value="standby">STANDBY</option>
+                        </select>
+                    </div>
+                    <div class="col-md-6 mb-2">
+                        <label for="optStandbyFile" class="form-label">${translations.optionStandbyFile}</label>
+                        <input type="text" class="form-control" id="optStandbyFile" placeholder="C:/Standby/{database}_undo.ldf" disabled>
+                    </div>
+                    <div class="col-md-6 mb-2">
+                        <label for="optBroker" class="form-label">${translations.optionBroker}</label>
+                        <select class="form-control" id="optBroker">
+                            <option value="">-</option>
+                            <option value="enable">ENABLE_BROKER</option>
+                            <option value="new">NEW_BROKER</option>
+                        </select>
+                    </div>
+                    <div class="col-md-6 mb-2">
+                        <label for="optRestoreChecksum" class="form-label">${translations.optionRestoreChecksum}</label>
+                        <select class="form-control" id="optRestoreChecksum">
+                            <option value="">-</option>
+                            <option value="true">CHECKSUM</option>
+                            <option value="false">NO_CHECKSUM</option>
+                        </select>
+                    </div>
+                    ${checkbox('optReplace', translations.optionReplace)}
+                    ${checkbox('optKeepReplication', translations.optionKeepReplication)}
+                    ${checkbox('optKeepCdc', translations.optionKeepCdc)}
+                    ${checkbox('optRestrictedUser', translations.optionRestrictedUser)}
+                </div>
+            </div>
+        </div>`;
+}
+
+/**
+ * Reads the restore options fields for one database. REPLACE is confirmed with the database name, after the user confirms the overwrite in executeOperation
+ * @param {string} database - The restored database name, which replaces {database} in the standby file
+ * @returns {Object} The options of the database in the /api/restore request
+*/
+function getRestoreOptions(database) {
+    const recovery = document.getElementById('optRecovery').value;
+    const broker = document.getElementById('optBroker').value;
+    const checksum = document.getElementById('optRestoreChecksum').value;
+    const replace = document.getElementById('optReplace').checked;
+    const options = {
+        replace: replace,
+        confirmReplace: replace ? database : '',
+        recovery: recovery,
+        keepReplication: document.getElementById('optKeepReplication').checked,
+        keepCdc: document.getElementById('optKeepCdc').checked,
+        enableBroker: broker === 'enable',
+        newBroker: broker === 'new',
+        restrictedUser: document.getElementById('optRestrictedUser').checked,
+    };
+
+    if (recovery === 'standby') {
+        options.standbyFile = document.getElementById('optStandbyFile').value.replaceAll('{database}', database);
+    }
+    if (checksum !== '') {
+        options.checksum = checksum === 'true';
+    }
+
+    return options;
+}
+
+/**
+ * Parses the MOVE overrides of a backup row, written as logical=target pairs separated by semicolons
+ * @param {string} value - The overrides field value
+ * @returns {Object} The target of each file, by its logical name
+*/
+function parseMoveOverrides(value) {
+    const moves = {};
+    value.split(';').map(pair => pair.trim()).filter(pair => pair !== '').forEach(pair => {
+        const separator = pair.indexOf('=');
+        if (separator > 0) {
+            moves[pair.slice(0, separator).trim()] = pair.slice(separator + 1).trim();
+        }
+    });
+
+    return moves;
+}
+
+/**
+ * Generates the point-in-time restore fields, inside a collapsible section. The chain is read from the backups path
+ * @returns {string} The HTML of the fields
+*/
+function restoreChainHTML() {
+    const translations = window.appConfig.translations;
+
+    return `<div class="col-md-12 mb-3">
+            <a class="btn btn-link btn-sm text-decoration-none p-0" data-bs-toggle="collapse" href="#restore-chain" role="button" aria-expanded="false" aria-controls="restore-chain">
+                <i class="fas fa-history me-1"></i>${translations.pointInTimeRestore}
+            </a>
+            <div class="collapse mt-2" id="restore-chain">
+                <div class="row">
+                    <div class="col-md-4 mb-2">
+                        <label for="chainDatabase" class="form-label">${translations.chainDatabase}</label>
+                        <input type="text" class="form-control" id="chainDatabase">
+                    </div>
+                    <div class="col-md-4 mb-2">
+                        <label for="chainTargetName" class="form-label">${translations.chainTargetName}</label>
+                        <input type="text" class="form-control" id="chainTargetName">
+                    </div>
+                    <div class="col-md-4 mb-2">
+                        <label for="chainStopAt" class="form-label">${translations.chainStopAt}</label>
+                        <i class="fas fa-info-circle info-icon" data-bs-toggle="tooltip" data-bs-placement="right" title="${translations.chainStopAtTooltip}"></i>
+                        <input type="datetime-local" class="form-control" id="chainStopAt" step="1">
+                    </div>
+                    <div class="col-md-12 mb-2">
+                        <input class="form-check-input" type="checkbox" id="chainReplace">
+                        <label class="form-check-label" for="chainReplace">${translations.chainReplace}</label>
+                    </div>
+                </div>
+                <button class="btn btn-outline-secondary btn-sm" type="button" id="preview-chain-btn" onclick="previewRestoreChain()">
+                    <i class="fas fa-eye me-1"></i>${translations.previewChain}
+                </button>
+                <button class="btn btn-success btn-sm d-none" type="button" id="execute-chain-btn" onclick="executeRestoreChain()">
+                    <i class="fas fa-play me-1"></i>${translations.executeChain}
+                </button>
+                <div class="mt-3" id="restore-chain-preview"></div>
+                <div class="mt-3" id="restore-chain-progress"></div>
+            </div>
+        </div>`;
+}
+
+/**
+ * Reads the point-in-time restore fields. REPLACE is confirmed with the restored database name, after the user confirms the overwrite in executeRestoreChain
+ * @returns {Object} The body of the /api/restore/chain and /api/restore/chain/plan requests
+*/
+function getRestoreChainRequest() {
+    const stopAt = document.getElementById('chainStopAt').value;
+    const database = document.getElementById('chainDatabase').value;
+    const targetName = document.getElementById('chainTargetName').value;
+    const replace = document.getElementById('chainReplace').checked;
+
+    return {
+        database: database,
+        targetName: targetName,
+        path: document.getElementById('path').value,
+        // datetime-local omits the seconds when they are zero
+        stopAt: stopAt && stopAt.length === 16 ? stopAt + ':00' : stopAt,
+        replace: replace,
+        confirmReplace: replace ? targetName || database : '',
+    };
+}
+
+/**
+ * Makes a POST request to /api/restore/chain/plan, showing the planned restore chain before it is executed
+*/
+async function previewRestoreChain() {
+    const translations = window.appConfig.translations;
+    const request = getRestoreChainRequest();
+    if (!request.path || !request.database) {
+        alert(translations.fillChainError);
+        return;
+    }
+
+    const previewBtn = document.getElementById('preview-chain-btn');
+    const executeBtn = document.getElementById('execute-chain-btn');
+    const preview = document.getElementById('restore-chain-preview');
+    const originalText = previewBtn.innerHTML;
+
+    try {
+        previewBtn.innerHTML = `<i class="fas fa-spinner fa-spin me-1"></i> ${translations.loading}`;
+        previewBtn.disabled = true;
+        executeBtn.classList.add('d-none');
+        document.getElementById('restore-chain-progress').innerHTML = '';
+
+        const response = await fetch('/api/restore/chain/plan', {
+            method: 'POST',
+            headers: getHeaders(),
+            body: JSON.stringify(request)
+        });
+        const result = await response.json();
+
+        if (!response.ok) {
+            if (response.status == 401) {
+                authModal.show();
+            }
+            throw new Error(result.errors?.restoreChain || result.message);
+        }
+
+        const chain = result.data.chain;
+        const stepTypes = { full: translations.backupTypeFull, differential: translations.backupTypeDifferential, log: translations.backupTypeLog };
+        const kind = step => step.backupType === 1 ? 'full' : step.backupType === 5 ? 'differential' : 'log';
+
+        preview.innerHTML = `
+            <table class="table table-sm">
+                <thead>
+                    <tr>
+                        <th>#</th>
+                        <th>${translations.backupFile}</th>
+                        <th>${translations.backupType}</th>
+                        <th>LSN</th>
+                        <th>${translations.chainFinishDate}</th>
+                        <th>${translations.chainRecovery}</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    ${chain.steps.map((step, index) => `
+                        <tr title="${step.statement}">
+                            <td>${index + 1}</td>
+                            <td>${(step.backupPaths || [step.backupPath]).join('<br>')}</td>
+                            <td>${stepTypes[kind(step)]}</td>
+                            <td><small>${step.firstLSN} - ${step.lastLSN}</small></td>
+                            <td>${step.backupFinishDate}</td>
+                            <td>${step.recovery ? (chain.stopAt ? 'RECOVERY, STOPAT = ' + chain.stopAt : 'RECOVERY') : 'NORECOVERY'}</td>
+                        </tr>`).join('')}
+                </tbody>
+            </table>
+            ${(chain.warnings || []).map(warning => `<div class="small text-warning">${warning}</div>`).join('')}
+        `;
+        executeBtn.classList.remove('d-none');
+    } catch (error) {
+        console.error('Error planning the restore chain:', error.message);
+        preview.innerHTML = `<div class="alert alert-danger">${translations.errorPlanningChain.replace("{errorMessage}", error.message)}</div>`;
+    } finally {
+        previewBtn.innerHTML = originalText;
+        previewBtn.disabled = false;
+    }
+}
+
+/**
+ * Makes a POST request to /api/restore/chain, executing the previewed restore chain, and follows its job
+*/
+async function executeRestoreChain() {
+    const translations = window.appConfig.translations;
+    const request = getRestoreChainRequest();
+
+    if (!confirm(translations.confirmRestoreChain.replace("{database}", request.targetName || request.database))) {
+        return;
+    }
+    if (request.replace && !confirm(translations.confirmReplace.replace("{databases}", request.confirmReplace))) {
+        return;
+    }
+
+    const executeBtn = document.getElementById('execute-chain-btn');
+    const originalText = executeBtn.innerHTML;
+
+    try {
+        executeBtn.innerHTML = `<i class="fas fa-spinner fa-spin me-1"></i> ${translations.running}`;
+        executeBtn.disabled = true;
+
+        const response = await fetch('/api/restore/chain', {
+            method: 'POST',
+            headers: getHeaders(),
+            body: JSON.stringify(request)
+        });
+        const result = await response.json();
+
+        if (!response.ok) {
+            throw result;
         }
+
+        const job = await followJob(result.data.jobId, 'restore-chain-progress');
+        populateAndShowResultModal('restore', jobToResult('restore', job));
+    } catch (error) {
+        populateAndShowResultModal('restore', error);
+    } finally {
+        executeBtn.innerHTML = originalText;
+        executeBtn.disabled = false;
     }
 }
 
+/**
+ * Generates the backup options fields (WITH options of the BACKUP statement), inside a collapsible section
+ * @returns {string} The HTML of the fields
+*/
+function backupOptionsHTML() {
+    const translations = window.appConfig.translations;
+    const checkbox = (id, label) => `
+        <div class="col-md-6 mb-2">
+            <input class="form-check-input" type="checkbox" id="${id}">
+            <label class="form-check-label" for="${id}">${label}</label>
+        </div>`;
+    const input = (id, label, type, attrs = '') => `
+        <div class="col-md-6 mb-2">
+            <label for="${id}" class="form-label">${label}</label>
+            <input type="${type}" class="form-control" id="${id}" ${attrs}>
+        </div>`;
+
+    return `<div class="col-md-12 mb-3">
+            <a class="btn btn-link btn-sm text-decoration-none p-0" data-bs-toggle="collapse" href="#backup-options" role="button" aria-expanded="false" aria-controls="backup-options">
+                <i class="fas fa-sliders-h me-1"></i>${translations.backupOptions}
+            </a>
+            <div class="collapse mt-2" id="backup-options">
+                <div class="row">
+                    ${checkbox('optCompression', translations.optionCompression)}
+                    ${checkbox('optChecksum', translations.optionChecksum)}
+                    ${checkbox('optCopyOnly', translations.optionCopyOnly)}
+                    ${checkbox('optContinueAfterError', translations.optionContinueAfterError)}
+                    ${checkbox('optInit', translations.optionInit)}
+                    ${checkbox('optFormat', translations.optionFormat)}
+                    ${checkbox('optVerify', translations.optionVerify)}
+                    ${input('optStats', translations.optionStats, 'number', 'min="1" max="100"')}
+                    ${input('optBufferCount', translations.optionBufferCount, 'number', 'min="1"')}
+                    ${input('optMaxTransferSize', translations.optionMaxTransferSize, 'number', 'min="65536" max="4194304" step="65536"')}
+                    ${input('optBlockSize', translations.optionBlockSize, 'number', 'min="512" max="65536"')}
+                    ${input('optDescription', translations.optionDescription, 'text', 'maxlength="255"')}
+                    ${input('optName', translations.optionName, 'text', 'maxlength="128"')}
+                    ${input('optStripes', translations.optionStripes, 'number', 'min="1" max="64"')}
+                    ${input('optStripeDirectories', translations.optionStripeDirectories, 'text', 'placeholder="D:/Backups, E:/Backups"')}
+                </div>
+            </div>
+        </div>`;
+}
+
+/**
+ * Makes a GET request to /api/backup/options, pre-selecting the default backup options set in the server
+*/
+async function loadBackupOptions() {
+    try {
+        const response = await fetch('/api/backup/options', {
+            method: 'GET',
+            headers: getHeaders()
+        });
+        const result = await response.json();
+
+        if (!response.ok) {
+            throw result;
+        }
+
+        const options = result.data.options;
+        document.getElementById('optCompression').checked = !!options.compression;
+        document.getElementById('optChecksum').checked = !!options.checksum;
+        document.getElementById('optCopyOnly').checked = !!options.copyOnly;
+        document.getElementById('optContinueAfterError').checked = !!options.continueAfterError;
+        document.getElementById('optVerify').checked = !!options.verify;
+        document.getElementById('optStats').value = options.stats || '';
+        document.getElementById('optBufferCount').value = options.bufferCount || '';
+        document.getElementById('optMaxTransferSize').value = options.maxTransferSize || '';
+        document.getElementById('optBlockSize').value = options.blockSize || '';
+    } catch (error) {
+        console.error("Error trying to load the default backup options: ", error);
+    }
+}
+
+/**
+ * Reads the backup options fields. Empty numeric fields are sent as 0, so the server uses its defaults
+ * @returns {Object} The options of the /api/backup request
+*/
+function getBackupOptions() {
+    const number = id => parseInt(document.getElementById(id).value) || 0;
+    const options = {
+        compression: document.getElementById('optCompression').checked,
+        checksum: document.getElementById('optChecksum').checked,
+        continueAfterError: document.getElementById('optContinueAfterError').checked,
+        init: document.getElementById('optInit').checked,
+        format: document.getElementById('optFormat').checked,
+        verify: document.getElementById('optVerify').checked,
+        stats: number('optStats'),
+        bufferCount: number('optBufferCount'),
+        maxTransferSize: number('optMaxTransferSize'),
+        blockSize: number('optBlockSize'),
+        description: document.getElementById('optDescription').value,
+        name: document.getElementById('optName').value,
+        stripes: number('optStripes'),
+        stripeDirectories: document.getElementById('optStripeDirectories').value.split(',').map(dir => dir.trim()).filter(dir => dir !== '')
+    };
+
+    // COPY_ONLY cannot be used with differential backups
+    if (document.getElementById('backupType').value !== 'differential') {
+        options.copyOnly = document.getElementById('optCopyOnly').checked;
+    }
+
+    return options;
+}
+
 /**
  * Makes a POST request to the backend, collecting all the .bak files in the given path and generating the dynamic HTML content
  * @throws {Error} Throws an error then the backend returns a bad HTTP status code
@@ -303,17 +710,28 @@ async function listBackups() {
                         <th><input type="checkbox" id="select-all-backups" onchange="toggleAllBackupSelection(this)" checked></th>
                         <th>${window.appConfig.translations.backupFile}</th>
                         <th>${window.appConfig.translations.databaseName}</th>
+                        <th>${window.appConfig.translations.moveOverrides}
+                            <i class="fas fa-info-circle info-icon" data-bs-toggle="tooltip" data-bs-placement="right" title="${window.appConfig.translations.moveOverridesTooltip}"></i>
+                        </th>
                     </tr>
                 </thead>
                 <tbody>
         `;
 
+        const backupTypes = { full: window.appConfig.translations.backupTypeFull, differential: window.appConfig.translations.backupTypeDifferential, log: window.appConfig.translations.backupTypeLog };
+
+        // Only the full backups are selected for the restore: the differential and log backups are listed to be verified and inspected
         files.forEach(file => {
+            const stripeFiles = file.stripeFiles || [];
+            const stripeInfo = file.stripes ? `<br><small class="${stripeFiles.length < file.stripes ? 'text-warning' : 'text-muted'}" ${stripeFiles.length < file.stripes ? `title="${window.appConfig.translations.stripeSetIncomplete}"` : ''}>
+                    ${window.appConfig.translations.stripeSet.replace("{found}", stripeFiles.length).replace("{count}", file.stripes)}</small>` : '';
+
             tableHTML += `
                 <tr>
-                    <td><input type="checkbox" class="backup-checkbox" value="${file.fileName}" checked></td>
-                    <td>${file.fileName}</td>
+                    <td><input type="checkbox" class="backup-checkbox" value="${file.fileName}" data-stripe-files="${stripeFiles.length === file.stripes ? stripeFiles.join('|') : ''}" ${file.backupType === 'full' ? 'checked' : ''}></td>
+                    <td>${file.fileName} <span class="badge bg-secondary">${backupTypes[file.backupType]}</span>${stripeInfo}<br><small class="text-muted backup-header"></small></td>
                     <td><input type="text" class="form-control" value="${file.defaultDbName}"></td>
+                    <td><input type="text" class="form-control move-overrides" placeholder="logical=E:/Data/file.ndf; ..."></td>
                 </tr>
             `;
         });
@@ -321,9 +739,14 @@ async function listBackups() {
         tableHTML += `
                 </tbody>
             </table>
+            <button class="btn btn-outline-secondary btn-sm" type="button" id="verify-backups-btn" onclick="verifySelectedBackups()">
+                <i class="fas fa-check-double me-1"></i>${window.appConfig.translations.verifySelected}
+            </button>
+            <div class="mt-3" id="verify-progress"></div>
         `;
 
         tableContainer.innerHTML = tableHTML;
+        inspectListedBackups();
 
     } catch (error) {
         console.error('Error listing backups:', error.message);
@@ -335,6 +758,120 @@ async function listBackups() {
     }
 }
 
+/**
+ * Inspects the listed backup files (see inspectBackupFile), with up to maxConcurrentInspections requests at once,
+ * since each one reads the backup file in SQL Server
+ */
+async function inspectListedBackups() {
+    const backupPath = document.getElementById('path').value;
+    const fullPath = backupPath.endsWith('/') || backupPath.endsWith('\\') ? backupPath : backupPath + '/';
+    const queue = Array.from(document.querySelectorAll('#backup-files-table .backup-checkbox'));
+
+    const workers = Array.from({ length: Math.min(maxConcurrentInspections, queue.length) }, async () => {
+        while (queue.length > 0) {
+            await inspectBackupFile(queue.shift(), fullPath);
+        }
+    });
+    await Promise.all(workers);
+}
+
+/**
+ * Makes a POST request to /api/backup-files/inspect for the backup file of the row, showing its header (RESTORE HEADERONLY) under the file name
+ * and defaulting the target name to the database name in the backup, unless it was already changed. The inspection is optional, so errors are only logged
+ */
+async function inspectBackupFile(row, fullPath) {
+    const tableRow = row.closest('tr');
+    const stripeFiles = row.dataset.stripeFiles ? row.dataset.stripeFiles.split('|') : [];
+
+    // The list was replaced while the file waited in the queue
+    if (!tableRow.isConnected) {
+        return;
+    }
+
+    try {
+        const response = await fetch('/api/backup-files/inspect', {
+            method: 'POST',
+            headers: getHeaders(),
+            body: JSON.stringify({ backupPath: fullPath + row.value, backupPaths: stripeFiles.map(stripeFile => fullPath + stripeFile) })
+        });
+        const result = await response.json();
+        if (!response.ok) {
+            throw new Error(result.errors ? Object.values(result.errors).join(', ') : result.message);
+        }
+
+        const inspection = result.data.inspection;
+        const header = inspection.headers[0];
+        const nameInput = tableRow.querySelector('input[type="text"]');
+        if (inspection.databaseName && nameInput.value === nameInput.defaultValue) {
+            nameInput.value = inspection.databaseName;
+            nameInput.defaultValue = inspection.databaseName;
+        }
+
+        const headerInfo = tableRow.querySelector('.backup-header');
+        headerInfo.textContent = window.appConfig.translations.backupHeader
+            .replace("{database}", header.databaseName)
+            .replace("{type}", header.backupTypeName || header.backupType)
+            .replace("{date}", new Date(header.backupFinishDate).toLocaleString())
+            .replace("{version}", header.softwareVersion);
+        headerInfo.title = window.appConfig.translations.backupHeaderTooltip;
+    } catch (error) {
+        console.warn('Cannot inspect the backup file:', row.value, error.message);
+    }
+}
+
+/**
+ * Makes a POST request to /api/verify, checking the selected backup files with RESTORE VERIFYONLY, and follows the verify job
+ * @throws {Error} Throws an error then the backend returns a bad HTTP status code
+*/
+async function verifySelectedBackups() {
+    const selectedRows = Array.from(document.querySelectorAll('#backup-files-table .backup-checkbox:checked'));
+    if (selectedRows.length === 0) {
+        alert(window.appConfig.translations.selectOneBackupVerifyError);
+        return;
+    }
+
+    const verifyBtn = document.getElementById('verify-backups-btn');
+    const originalText = verifyBtn.innerHTML;
+    const backupPath = document.getElementById('path').value;
+    const fullPath = backupPath.endsWith('/') || backupPath.endsWith('\\') ? backupPath : backupPath + '/';
+
+    try {
+        verifyBtn.innerHTML = `<i class="fas fa-spinner fa-spin me-1"></i> ${window.appConfig.translations.running}`;
+        verifyBtn.disabled = true;
+
+        const response = await fetch('/api/verify', {
+            method: 'POST',
+            headers: getHeaders(),
+            body: JSON.stringify({
+                files: selectedRows.map(row => {
+                    const stripeFiles = row.dataset.stripeFiles ? row.dataset.stripeFiles.split('|') : [];
+                    return {
+                        backupPath: fullPath + row.value,
+                        backupPaths: stripeFiles.map(stripeFile => fullPath + stripeFile),
+                    };
+                }),
+                concurrentOpe: parseInt(document.getElementById('maxConnections').value)
+            })
+        });
+        const result = await response.json();
+
+        if (!response.ok) {
+            if (response.status == 401) {
+                authModal.show();
+            }
+            throw result;
+        }
+
+        const job = await followJob(result.data.jobId, 'verify-progress');
+        populateAndShowResultModal('verify', jobToResult('verify', job));
+    } catch (error) {
+        populateAndShowResultModal('verify', error);
+    } finally {
+        verifyBtn.innerHTML = originalText;
+        verifyBtn.disabled = false;
+    }
+}
+
 function toggleAllBackupSelection(source) {
     const checkboxes = document.querySelectorAll('.backup-checkbox');
     checkboxes.forEach(checkbox => {
@@ -484,16 +1021,32 @@ async function loadDatabases() {
                 databasesHTML += '</div><div class="col-md-6">';
             }
             
-            const icon = 'fas fa-database';
+            const icon = db.isSystem ? 'fas fa-cog' : 'fas fa-database';
             const displayName = db.display_name || db.name || db;
             const value = db.name || db;
+
+            // tempdb, snapshots and databases which are not ONLINE are rejected by the backup, so they cannot be selected
+            let rejection = '';
+            if (value === 'tempdb') {
+                rejection = window.appConfig.translations.rejectTempdb;
+            } else if (db.isSnapshot) {
+                rejection = window.appConfig.translations.rejectSnapshot;
+            } else if (db.state && db.state !== 'ONLINE') {
+                rejection = window.appConfig.translations.rejectNotOnline.replace("{state}", db.state);
+            }
+            const details = [db.recoveryModel, db.compatibilityLevel ? `${window.appConfig.translations.compatibilityLevel} ${db.compatibilityLevel}` : '', db.sizeBytes ? formatBytes(db.sizeBytes) : '', db.owner]
+                .filter(detail => detail).join(' · ');
             
             databasesHTML += `
-                <div class="form-check mb-3">
-                    <input class="form-check-input" type="checkbox" id="db-${value}" value="${value}">
+                <div class="form-check mb-3" ${rejection ? `title="${rejection}"` : ''}>
+                    <input class="form-check-input" type="checkbox" id="db-${value}" value="${value}" ${rejection ? 'disabled' : ''}>
                     <label class="form-check-label" for="db-${value}">
                         <i class="${icon} me-2"></i>
                         ${displayName}
+                        ${db.state && db.state !== 'ONLINE' ? `<span class="badge bg-secondary ms-1">${db.state}</span>` : ''}
+                        ${db.isReadOnly ? `<span class="badge bg-info ms-1">${window.appConfig.translations.readOnly}</span>` : ''}
+                        ${db.isSnapshot ? `<span class="badge bg-info ms-1">${window.appConfig.translations.snapshot}</span>` : ''}
+                        ${details ? `<br><small class="text-muted">${details}</small>` : ''}
                     </label>
                 </div>
             `;
@@ -509,6 +1062,8 @@ async function loadDatabases() {
                         <i class="fas fa-times me-1"></i>
                         ${window.appConfig.translations.clearSelection}
                     </button>`
+
+        loadLastBackups();
         
     } catch (error) {
         alert(window.appConfig.translations.errorLoadingDatabases.replace("{errorMessage}", error.message));
@@ -529,7 +1084,7 @@ async function loadDatabases() {
  * Checks all checkboxes in "step 3" related to databases
 */
 function selectAllDatabases() {
-    const checkboxes = document.querySelectorAll('#step-3 input[type="checkbox"]');
+    const checkboxes = document.querySelectorAll('#step-3 input[type="checkbox"]:not(:disabled)');
     checkboxes.forEach(checkbox => checkbox.checked = true);
 }
 
@@ -590,9 +1145,19 @@ function generateSummary() {
 
     if (operation === 'backup') {
         const selectedDatabases = Array.from(document.querySelectorAll('#step-3 input[type="checkbox"]:checked'))
-        .map(cb => cb.nextElementSibling.textContent.trim());
+        .map(cb => cb.value);
+        const backupType = document.getElementById('backupType');
 
         summaryHTML += `<div class="summary-item">
+                <div class="summary-label">
+                    <i class="fas fa-layer-group me-2"></i>
+                    ${window.appConfig.translations.backupType}
+                </div>
+                <div class="summary-value">
+                    ${backupType.options[backupType.selectedIndex].text}
+                </div>
+            </div>
+            <div class="summary-item">
                 <div class="summary-label">
                     <i class="fas fa-database me-2"></i>
                     ${window.appConfig.translations.summarySelectedDatabases.replace("{count}", selectedDatabases.length)}
@@ -600,6 +1165,13 @@ function generateSummary() {
                 <div class="summary-value">
                     ${selectedDatabases.map(db => `<span class="badge bg-primary me-1 mb-1">${db}</span>`).join('')}
                 </div>
+            </div>
+            <div class="summary-item">
+                <div class="summary-label">
+                    <i class="fas fa-clipboard-list me-2"></i>
+                    ${window.appConfig.translations.backupPlan}
+                </div>
+                <div class="summary-value" id="backup-plan"></div>
             </div>`;
     } else if (operation === 'restore') {
         const selectedRows = Array.from(document.querySelectorAll('#backup-files-table .backup-checkbox:checked'));
@@ -616,10 +1188,24 @@ function generateSummary() {
             <div class="summary-value">
                 ${selectedDbs.map(db => `<span class="badge bg-primary me-1 mb-1">${db}</span>`).join('')}
             </div>
+        </div>
+        <div class="summary-item">
+            <div class="summary-label">
+                <i class="fas fa-clipboard-list me-2"></i>
+                ${window.appConfig.translations.restorePlan}
+            </div>
+            <div class="summary-value" id="restore-plan"></div>
         </div>`;
     }
 
     document.getElementById('summary-content').innerHTML = summaryHTML;
+    document.getElementById('job-progress').innerHTML = '';
+
+    if (operation === 'backup') {
+        loadBackupPlan();
+    } else if (operation === 'restore') {
+        loadRestorePlan();
+    }
 } 
 
 // Populates the content of modalResult
@@ -631,12 +1217,15 @@ function populateAndShowResultModal(operation, result) {
     const opCapitalized = operation.charAt(0).toUpperCase() + operation.slice(1);
     modalTitle.textContent = window.appConfig.translations.resultModalOperationSummary.replace("{operation}", opCapitalized);
     
-    const completed = Array.isArray(result.data?.backupDone) || Array.isArray(result.data?.restoreDone) ? (operation === 'backup' ? result.data?.backupDone.map(db => db.name) : result.data?.restoreDone.map(db => db.database.name)) : null;
-    const errors = (operation === 'backup' ? result.errors?.backupErrors : result.errors?.restoreErrors) || result.errors || null;
+    const completed = Array.isArray(result.data?.backupDone) || Array.isArray(result.data?.restoreDone) || Array.isArray(result.data?.verifyDone) ?
+        (operation === 'backup' ? result.data?.backupDone.map(db => db.name) : operation === 'verify' ? result.data?.verifyDone : result.data?.restoreDone.map(db => db.database.name)) : null;
+    const errors = (operation === 'backup' ? result.errors?.backupErrors : operation === 'verify' ? result.errors?.verifyErrors : result.errors?.restoreErrors) || result.errors || null;
     const backupPath = result.data?.backupPath;      
     const totalTime = result.data?.totalTime;
-    const totalOpe = (result.data?.totalBackup || result.data?.totalRestore) || null
-    const totalOpeError = (result.errors?.totalBackupErrors || result.errors?.totalRestoreErrors) || null
+    const totalOpe = (result.data?.totalBackup || result.data?.totalRestore || result.data?.totalVerify) || null
+    const totalOpeError = (result.errors?.totalBackupErrors || result.errors?.totalRestoreErrors || result.errors?.totalVerifyErrors) || null
+    const verified = result.data?.verified;
+    const verifyFailed = result.data?.verifyFailed;
 
     let statusTitle 
     if (errors && completed) {
@@ -699,6 +1288,19 @@ function populateAndShowResultModal(operation, result) {
         }
     }
 
+    // The verification result of the backups, which does not change the backup result
+    if ((verified && verified.length > 0) || (verifyFailed && verifyFailed.length > 0)) {
+        contentHTML += `
+            <div class="result-section">
+                <h6 class="result-title"><i class="fas fa-check-double me-2"></i> ${window.appConfig.translations.resultModalVerification} </h6>
+                <ul class="result-list">
+                    ${(verified || []).map(db => `<li class="success">${db}: ${window.appConfig.translations.verifyPassed}</li>`).join('')}
+                    ${(verifyFailed || []).map(db => `<li class="error"><strong>${db.database}:</strong> ${db.error}</li>`).join('')}
+                </ul>
+            </div>
+        `;
+    }
+
     if (backupPath) {
         contentHTML += `
             <div class="result-section">
@@ -742,13 +1344,14 @@ async function executeOperation() {
 
     const btn = document.getElementById('execute-btn');
     const prevBtn = document.getElementById('prev-btn');
-    //const cancelBtn = document.getElementById('cancel-btn');
+    const cancelBtn = document.getElementById('cancel-btn');
     const originalText = btn.innerHTML;
     
     btn.innerHTML = `<i class="fas fa-spinner fa-spin me-1"></i> ${window.appConfig.translations.running}`;
     btn.disabled = true;
     prevBtn.classList.add('d-none');
-    //cancelBtn.classList.remove('d-none');
+    cancelBtn.classList.remove('d-none');
+    cancelBtn.disabled = true;
 
     try {
         let response;
@@ -756,31 +1359,17 @@ async function executeOperation() {
         let body;
 
         if (operation === 'backup') {
-            const requestData = {
-                databases: 
-                    selectedDatabases.map(db => {
-                        return {"name": db}
-                    }),
-                path: document.getElementById('path').value,
-                concurrentOpe: parseInt(document.getElementById('maxConnections').value)
-            };
-            body = JSON.stringify(requestData);
+            body = JSON.stringify(getBackupRequest());
         } else if (operation === 'restore') {
-            const selectedRows = Array.from(document.querySelectorAll('#backup-files-table .backup-checkbox:checked'));
-            const restoreData = {}
-            restoreData.databases = selectedRows.map(row => {
-                const tableRow = row.closest('tr');
-                const backupFileName = row.value;
-                const dbName = tableRow.querySelector('input[type="text"').value;
-                const backupPath = document.getElementById('path').value;
-                const fullPath = backupPath.endsWith('/') || backupPath.endsWith('\\') ? backupPath : backupPath + '/';
-
-                return {
-                    name: dbName,
-                    backupPath: fullPath + backupFileName,
-                };
-            })
-            restoreData.concurrentOpe = parseInt(document.getElementById('maxConnections').value);
+            const restoreData = getRestoreRequest();
+
+            // REPLACE overwrites existing databases, so it needs an explicit confirmation of their names
+            if (document.getElementById('optReplace').checked) {
+                const names = restoreData.databases.map(db => db.name).join(', ');
+                if (!confirm(window.appConfig.translations.confirmReplace.replace("{databases}", names))) {
+                    throw { errors: { options: window.appConfig.translations.replaceNotConfirmed } };
+                }
+            }
             console.log("restoreData: ", JSON.stringify(restoreData));
             body = JSON.stringify(restoreData);
             console.log(body);
@@ -798,7 +1387,11 @@ async function executeOperation() {
             throw result;
         }
 
-        populateAndShowResultModal(operation, result);
+        cancelBtn.onclick = () => cancelJob(result.data.jobId);
+        cancelBtn.disabled = false;
+
+        const job = await followJob(result.data.jobId);
+        populateAndShowResultModal(operation, jobToResult(operation, job));
         
     } catch (error) {
         populateAndShowResultModal(operation, error);
@@ -806,10 +1399,469 @@ async function executeOperation() {
         btn.innerHTML = originalText;
         btn.disabled = false;
         prevBtn.classList.remove('d-none');
-        //cancelBtn.classList.add('d-none');
+        cancelBtn.classList.add('d-none');
+        cancelBtn.onclick = null;
+    }
+}
+
+/**
+ * Builds the body of the /api/backup and /api/backup/plan requests, from the selected databases, the backup path, type and options
+ * @returns {Object} The databases to be backed up, the backup path, type and options and the concurrent operations
+*/
+function getBackupRequest() {
+    const selectedDatabases = Array.from(document.querySelectorAll('#step-3 input[type="checkbox"]:checked'))
+        .map(cb => cb.value);
+
+    return {
+        databases: selectedDatabases.map(db => {
+            return {"name": db}
+        }),
+        path: document.getElementById('path').value,
+        backupType: document.getElementById('backupType').value,
+        options: getBackupOptions(),
+        concurrentOpe: parseInt(document.getElementById('maxConnections').value)
+    };
+}
+
+/**
+ * Makes a POST request to /api/backup/plan and shows, in the summary, what the backup will do: the T-SQL statements, the backup files,
+ * the estimated sizes against the free space of the volumes, the rejected databases and the warnings. Nothing is executed on the server
+*/
+async function loadBackupPlan() {
+    const translations = window.appConfig.translations;
+    const container = document.getElementById('backup-plan');
+    container.innerHTML = `<i class="fas fa-spinner fa-spin me-1"></i> ${translations.loading}`;
+
+    try {
+        const response = await fetch('/api/backup/plan', {
+            method: 'POST',
+            headers: getHeaders(),
+            body: JSON.stringify(getBackupRequest())
+        });
+        const result = await response.json();
+
+        if (!response.ok) {
+            if (response.status == 401) {
+                authModal.show();
+            }
+            throw new Error(result.errors?.options || result.errors?.backupType || result.errors?.backup || result.message);
+        }
+
+        const plan = result.data.plan;
+
+        container.innerHTML = `
+            <div class="small mb-2">
+                <strong>${translations.planEstimatedSize}:</strong> ${formatBytes(plan.estimatedBytes)}
+                ${(plan.volumes || []).map(volume => `<br><strong>${volume.mountPoint}:</strong> ${translations.planVolumeSpace.replace("{required}", formatBytes(volume.requiredBytes)).replace("{available}", formatBytes(volume.availableBytes))}`).join('')}
+            </div>
+            ${(plan.warnings || []).map(warning => `<div class="small text-warning">${warning}</div>`).join('')}
+            <table class="table table-sm small mt-2 mb-2">
+                <thead>
+                    <tr>
+                        <th>${translations.planDatabase}</th>
+                        <th>${translations.planFiles}</th>
+                        <th>${translations.planEstimatedSize}</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    ${plan.databases.map(database => `
+                        <tr title="${database.statement}">
+                            <td>${database.database}</td>
+                            <td>${database.files.join('<br>')}</td>
+                            <td>${formatBytes(database.estimatedBytes)}${(database.warnings || []).map(warning => `<div class="text-warning">${warning}</div>`).join('')}</td>
+                        </tr>`).join('')}
+                </tbody>
+            </table>
+            ${plan.databases.length > 0 ? `<pre class="small bg-light p-2 mb-1">${plan.databases[0].statement}</pre>` : ''}
+            ${(plan.rejected || []).length > 0 ? `<div class="small"><strong>${translations.planRejected.replace("{count}", plan.rejected.length)}</strong></div>` : ''}
+            ${(plan.rejected || []).map(rejected => `<div class="small text-danger">${rejected.database}: ${rejected.error}</div>`).join('')}
+        `;
+    } catch (error) {
+        console.error('Error planning the backup:', error.message);
+        container.innerHTML = `<div class="alert alert-danger">${translations.errorPlanningBackup.replace("{errorMessage}", error.message)}</div>`;
+    }
+}
+
+/**
+ * Builds the body of the /api/restore and /api/restore/plan requests, from the selected backup files, their target names, file moves and the restore options
+ * @returns {Object} The databases to be restored and the concurrent operations
+*/
+function getRestoreRequest() {
+    const selectedRows = Array.from(document.querySelectorAll('#backup-files-table .backup-checkbox:checked'));
+    const databases = selectedRows.map(row => {
+        const tableRow = row.closest('tr');
+        const backupFileName = row.value;
+        const dbName = tableRow.querySelector('input[type="text"').value;
+        const options = getRestoreOptions(dbName);
+        const moves = parseMoveOverrides(tableRow.querySelector('.move-overrides').value);
+        if (Object.keys(moves).length > 0) {
+            options.move = moves;
+        }
+        const backupPath = document.getElementById('path').value;
+        const fullPath = backupPath.endsWith('/') || backupPath.endsWith('\\') ? backupPath : backupPath + '/';
+
+        // A complete stripe set is sent as a whole. An incomplete one is searched by the server from the first stripe
+        const stripeFiles = row.dataset.stripeFiles ? row.dataset.stripeFiles.split('|') : [];
+
+        return {
+            name: dbName,
+            backupPath: fullPath + backupFileName,
+            backupPaths: stripeFiles.map(stripeFile => fullPath + stripeFile),
+            options: options,
+        };
+    });
+
+    return {
+        databases: databases,
+        concurrentOpe: parseInt(document.getElementById('maxConnections').value),
+    };
+}
+
+/**
+ * Formats a size in bytes with a binary unit, like 1.5 GB
+ * @param {number} size - The size in bytes
+ * @returns {string} The formatted size
+*/
+/**
+ * Makes a GET request to /api/history/backups and shows, next to each database of the selection step, how long ago its last backup (of any type) finished.
+ * The dates are compared with the server time, since msdb records them in the server local time. The history is optional, so errors are only logged
+ */
+async function loadLastBackups() {
+    try {
+        const response = await fetch(`/api/history/backups?pageSize=1`, {
+            method: 'GET',
+            headers: getHeaders(),
+        });
+        const data = await response.json();
+        if (!response.ok) {
+            throw new Error(data.message);
+        }
+
+        const history = data.data.history;
+        const serverTime = new Date(history.serverTime);
+        const lastBackups = new Map(history.lastBackups.map(last => [last.database, last]));
+        const typeLabels = {
+            full: window.appConfig.translations.historyTypeFull,
+            differential: window.appConfig.translations.historyTypeDifferential,
+            log: window.appConfig.translations.historyTypeLog,
+        };
+
+        document.querySelectorAll('#step-3 input[type="checkbox"]').forEach(checkbox => {
+            const last = lastBackups.get(checkbox.value);
+            const backups = last ? [last.full, last.differential, last.log].filter(backup => backup) : [];
+            const latest = backups.reduce((latest, backup) => !latest || new Date(backup.finishDate) > new Date(latest.finishDate) ? backup : latest, null);
+
+            const text = latest
+                ? window.appConfig.translations.lastBackup
+                    .replace("{time}", formatRelativeTime(new Date(latest.finishDate), serverTime))
+                    .replace("{type}", typeLabels[latest.backupType])
+                : window.appConfig.translations.noBackupRecorded;
+
+            const label = document.querySelector(`label[for="${CSS.escape(checkbox.id)}"]`);
+            label.insertAdjacentHTML('beforeend', `<br><small class="text-muted"><i class="fas fa-history me-1"></i>${text}</small>`);
+        });
+    } catch (error) {
+        console.error(`Cannot load the backup history: ${error.message}`);
+    }
+}
+
+/**
+ * Formats how long before now the date is, like "3 days ago", in the language of the translations
+ */
+function formatRelativeTime(date, now) {
+    const format = new Intl.RelativeTimeFormat(window.appConfig.translations.locale, { numeric: 'auto' });
+    const seconds = Math.round((date - now) / 1000);
+    const units = [['year', 31536000], ['month', 2592000], ['day', 86400], ['hour', 3600], ['minute', 60]];
+
+    for (const [unit, length] of units) {
+        if (Math.abs(seconds) >= length) {
+            return format.format(Math.round(seconds / length), unit);
+        }
+    }
+
+    return format.format(seconds, 'second');
+}
+
+function formatBytes(size) {
+    const units = ['B', 'KB', 'MB', 'GB', 'TB'];
+    let unit = 0;
+    while (size >= 1024 && unit < units.length - 1) {
+        size /= 1024;
+        unit++;
+    }
+
+    return unit === 0 ? `${size} B` : `${size.toFixed(1)} ${units[unit]}`;
+}
+
+/**
+ * Makes a POST request to /api/restore/plan and shows, in the summary, what the restore will do: the T-SQL statements, the file moves,
+ * if the databases already exist, the required disk space against the free space of the volumes and the warnings. Nothing is executed on the server
+*/
+async function loadRestorePlan() {
+    const translations = window.appConfig.translations;
+    const container = document.getElementById('restore-plan');
+    container.innerHTML = `<i class="fas fa-spinner fa-spin me-1"></i> ${translations.loading}`;
+
+    try {
+        const response = await fetch('/api/restore/plan', {
+            method: 'POST',
+            headers: getHeaders(),
+            body: JSON.stringify(getRestoreRequest())
+        });
+        const result = await response.json();
+
+        if (!response.ok) {
+            if (response.status == 401) {
+                authModal.show();
+            }
+            throw new Error(result.errors?.options || result.errors?.restore || result.message);
+        }
+
+        const plans = result.data.plans || [];
+        const errors = result.data.errors || [];
+
+        container.innerHTML = plans.map(plan => `
+            <div class="border rounded p-2 mb-2">
+                <strong>${plan.database}</strong>
+                <span class="badge ${plan.exists ? 'bg-warning' : 'bg-success'} ms-1">${plan.exists ? translations.planDatabaseExists : translations.planNewDatabase}</span>
+                <div class="small mt-1">
+                    <strong>${translations.planDataPath}:</strong> ${plan.dataPath}<br>
+                    <strong>${translations.planLogPath}:</strong> ${plan.logPath}<br>
+                    <strong>${translations.planRequiredSpace}:</strong> ${formatBytes(plan.requiredBytes)}
+                    ${(plan.volumes || []).map(volume => `<br><strong>${volume.mountPoint}:</strong> ${translations.planVolumeSpace.replace("{required}", formatBytes(volume.requiredBytes)).replace("{available}", formatBytes(volume.availableBytes))}`).join('')}
+                </div>
+                <table class="table table-sm small mt-2 mb-2">
+                    <thead>
+                        <tr>
+                            <th>${translations.planLogicalName}</th>
+                            <th>${translations.planFrom}</th>
+                            <th>${translations.planTo}</th>
+                            <th>${translations.planSize}</th>
+                        </tr>
+                    </thead>
+                    <tbody>
+                        ${(plan.moves || []).map(move => `
+                            <tr>
+                                <td>${move.logicalName} <small class="text-muted">(${move.fileType})</small></td>
+                                <td>${move.from}</td>
+                                <td>${move.to}${move.override ? ' <i class="fas fa-pen text-muted"></i>' : ''}</td>
+                                <td>${formatBytes(move.size || 0)}</td>
+                            </tr>`).join('')}
+                    </tbody>
+                </table>
+                <pre class="small bg-light p-2 mb-1">${plan.statement}</pre>
+                ${(plan.warnings || []).map(warning => `<div class="small text-warning">${warning}</div>`).join('')}
+            </div>`).join('')
+            + errors.map(error => `<div class="small text-danger">${error.database}: ${error.error}</div>`).join('');
+    } catch (error) {
+        console.error('Error planning the restore:', error.message);
+        container.innerHTML = `<div class="alert alert-danger">${translations.errorPlanningRestore.replace("{errorMessage}", error.message)}</div>`;
+    }
+}
+
+/**
+ * Polls GET /api/jobs/{id} until the job reaches a final state (succeeded, failed, partial or cancelled).
+ * @param {string} jobId - The job ID returned by /api/backup, /api/restore or /api/verify
+ * @param {number} interval - The polling interval, in milliseconds
+ * @param {string} target - The ID of the element where the progress is rendered
+ * @returns {Object} The finished job
+ * @throws {Object} The API response, if the job cannot be read
+*/
+async function waitForJob(jobId, interval = 2000, target = 'job-progress') {
+    const finalStates = ['succeeded', 'failed', 'partial', 'cancelled'];
+
+    while (true) {
+        const response = await fetch(`/api/jobs/${jobId}`, {
+            method: 'GET',
+            headers: getHeaders()
+        });
+        const result = await response.json();
+
+        if (!response.ok) {
+            throw result;
+        }
+
+        renderJobProgress(result.data.job, target);
+
+        if (finalStates.includes(result.data.job.state)) {
+            return result.data.job;
+        }
+
+        await new Promise(resolve => setTimeout(resolve, interval));
     }
 }
 
+/**
+ * Follows a job through the Server-Sent Events stream (GET /api/jobs/{id}/events), rendering the progress of each database.
+ * If the stream cannot be used, it falls back to polling with waitForJob.
+ * @param {string} jobId - The job ID returned by /api/backup, /api/restore or /api/verify
+ * @param {string} target - The ID of the element where the progress is rendered
+ * @returns {Promise<Object>} The finished job
+*/
+function followJob(jobId, target = 'job-progress') {
+    const finalStates = ['succeeded', 'failed', 'partial', 'cancelled'];
+
+    return new Promise((resolve, reject) => {
+        if (!window.EventSource) {
+            waitForJob(jobId, 2000, target).then(resolve, reject);
+            return;
+        }
+
+        const source = new EventSource(`/api/jobs/${jobId}/events`);
+        let finished = false;
+
+        source.addEventListener('job', event => {
+            const job = JSON.parse(event.data);
+            renderJobProgress(job, target);
+
+            if (finalStates.includes(job.state)) {
+                finished = true;
+                source.close();
+                resolve(job);
+            }
+        });
+
+        source.onerror = () => {
+            if (finished) {
+                return;
+            }
+            source.close();
+            waitForJob(jobId, 2000, target).then(resolve, reject);
+        };
+    });
+}
+
+/**
+ * Renders a progress bar for each database of the job, below the summary
+ * @param {Object} job - The job returned by the API
+ * @param {string} target - The ID of the element where the progress is rendered
+*/
+function renderJobProgress(job, target = 'job-progress') {
+    const translations = window.appConfig.translations;
+    const stateLabels = {
+        queued: translations.jobStateQueued,
+        running: translations.jobStateRunning,
+        succeeded: translations.jobStateSucceeded,
+        failed: translations.jobStateFailed,
+        cancelled: translations.jobStateCancelled,
+    };
+    const stateClasses = {
+        queued: 'bg-secondary',
+        running: 'progress-bar-striped progress-bar-animated',
+        succeeded: 'bg-success',
+        failed: 'bg-danger',
+        cancelled: 'bg-warning',
+    };
+
+    const databasesHTML = job.databases.map(db => {
+        const percent = db.state === 'failed' || db.state === 'cancelled' ? 100 : Math.round(db.percentComplete || 0);
+        const remaining = db.state === 'running' && db.estimatedRemaining ? ` · ${translations.estimatedRemaining.replace("{time}", db.estimatedRemaining)}` : '';
+        const cancelButton = (db.state === 'queued' || db.state === 'running') && !job.cancelledAt ?
+            `<button type="button" class="btn btn-link btn-sm text-danger p-0 ms-2" title="${translations.cancel}" onclick="cancelJob('${job.id}', '${db.name}')"><i class="fas fa-times"></i></button>` : '';
+        const partialFile = db.partialFiles ? `<div class="small text-warning">${translations.partialBackupFile.replace("{file}", db.partialFiles.join(', '))}</div>` : '';
+        const verification = db.verified === true ? `<div class="small text-success"><i class="fas fa-check-double me-1"></i>${translations.verifyPassed}</div>` :
+            db.verified === false ? `<div class="small text-danger"><i class="fas fa-times me-1"></i>${translations.verifyFailed.replace("{error}", db.verifyError)}</div>` : '';
+
+        return `
+            <div class="mb-2">
+                <div class="d-flex justify-content-between small">
+                    <span>${db.name}</span>
+                    <span>${stateLabels[db.state] || db.state}${remaining}${cancelButton}</span>
+                </div>
+                <div class="progress" role="progressbar" aria-valuenow="${percent}" aria-valuemin="0" aria-valuemax="100">
+                    <div class="progress-bar ${stateClasses[db.state] || ''}" style="width: ${percent}%">${db.state === 'failed' || db.state === 'cancelled' ? '' : percent + '%'}</div>
+                </div>
+                ${partialFile}
+                ${verification}
+            </div>
+        `;
+    }).join('');
+
+    document.getElementById(target).innerHTML = `
+        <div class="summary-item">
+            <div class="summary-label">
+                <i class="fas fa-tasks me-2"></i>
+                ${translations.jobProgress}
+            </div>
+            <div class="summary-value">
+                ${databasesHTML}
+            </div>
+        </div>
+    `;
+}
+
+/**
+ * Cancels the job (POST /api/jobs/{id}/cancel), or only one of its databases. The progress stream shows the result of the cancellation
+ * @param {string} jobId - The job ID returned by /api/backup or /api/restore
+ * @param {string} database - The database to be cancelled. If it is not set, the whole job is cancelled
+*/
+async function cancelJob(jobId, database) {
+    const translations = window.appConfig.translations;
+    const confirmMsg = database ? translations.confirmCancelDatabase.replace("{database}", database) : translations.confirmCancelJob;
+
+    if (!confirm(confirmMsg)) {
+        return;
+    }
+
+    try {
+        const response = await fetch(`/api/jobs/${jobId}/cancel`, {
+            method: 'POST',
+            headers: getHeaders(),
+            body: JSON.stringify(database ? { databases: [database] } : {})
+        });
+        const result = await response.json();
+
+        if (!response.ok) {
+            throw result;
+        }
+
+        renderJobProgress(result.data.job);
+    } catch (error) {
+        console.error("Error trying to cancel the job: ", error);
+        alert(error.message || error);
+    }
+}
+
+/**
+ * Converts a finished job into the result format expected by populateAndShowResultModal
+ * @param {string} operation - backup, restore or verify
+ * @param {Object} job - The finished job
+ * @returns {Object} An object with "data" and "errors", like the API responses
+*/
+function jobToResult(operation, job) {
+    const done = job.databases.filter(db => db.state === 'succeeded').map(db => ({ name: db.name, database: { name: db.name } }));
+    const errors = job.errors && job.errors.length > 0 ? job.errors : null;
+    const result = { data: { totalTime: job.totalTime } };
+
+    if (done.length > 0) {
+        if (operation === 'backup') {
+            result.data.backupDone = done;
+            result.data.totalBackup = done.length;
+        } else if (operation === 'verify') {
+            result.data.verifyDone = done.map(db => db.name);
+            result.data.totalVerify = done.length;
+        } else {
+            result.data.restoreDone = done;
+            result.data.totalRestore = done.length;
+        }
+    }
+
+    if (operation === 'backup') {
+        result.data.backupPath = job.path;
+        result.data.verified = job.databases.filter(db => db.verified === true).map(db => db.name);
+        result.data.verifyFailed = job.databases.filter(db => db.verified === false).map(db => ({ database: db.name, error: db.verifyError }));
+    }
+
+    if (errors) {
+        result.errors = operation === 'backup' ?
+            { backupErrors: errors, totalBackupErrors: errors.length } : operation === 'verify' ?
+            { verifyErrors: errors, totalVerifyErrors: errors.length } :
+            { restoreErrors: errors, totalRestoreErrors: errors.length };
+    }
+
+    return result;
+}
+
 /**
  * Emptys the session
 */